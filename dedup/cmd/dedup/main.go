@@ -2,15 +2,285 @@ package main
 
 import (
 	"dedup/pkg/dedup"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 )
 
 func main() {
-	if err := dedup.Dedup(
-		dedup.NewNotifier(os.Stdout),
-		os.Args[1],
-	); err != nil {
+	notify := dedup.NewNotifier(os.Stdout)
+
+	var err error
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "restore":
+			err = restore(notify, os.Args[2:])
+		case "purge":
+			err = purge(notify, os.Args[2:])
+		case "similar":
+			err = similar(notify, os.Args[2:])
+		case "verify":
+			err = verify(notify, os.Args[2:])
+		default:
+			err = run(notify, os.Args[1:])
+		}
+	} else {
+		err = run(notify, nil)
+	}
+
+	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+func run(notify dedup.Notifier, args []string) error {
+	opts := dedup.Options{Notifier: notify}
+	flags := flag.NewFlagSet("dedup", flag.ExitOnError)
+	flags.Usage = usage(flags, "dedup [FLAGS] DIRECTORY...")
+	walkFlags(flags, &opts)
+	flags.StringVar(
+		(*string)(&opts.Hash),
+		"hash",
+		string(dedup.Adler32),
+		"the checksum algorithm: adler32, crc32, sha1, or sha256",
+	)
+	flags.BoolVar(
+		&opts.DryRun,
+		"dry-run",
+		false,
+		"report what would be done without modifying any files",
+	)
+	mode := flags.String(
+		"mode",
+		"link",
+		"what to do with duplicates: link, quarantine, or delete",
+	)
+	quarantine := flags.String(
+		"quarantine",
+		"",
+		"the quarantine directory (required for -mode quarantine)",
+	)
+	retention := flags.Duration(
+		"retention",
+		0,
+		"with -mode quarantine, purge quarantined files older than this "+
+			"after deduplicating (0 keeps them indefinitely)",
+	)
+	journalPath := flags.String(
+		"journal",
+		"",
+		"append a record of each disposed duplicate to this file",
+	)
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	opts.Roots = flags.Args()
+
+	if *retention != 0 && *mode != "quarantine" {
+		// other modes dispose of duplicates immediately, so there is
+		// nothing to retain
+		return fmt.Errorf("-retention requires -mode quarantine")
+	}
+
+	switch *mode {
+	case "link":
+		opts.Strategy = dedup.Link{}
+	case "delete":
+		opts.Strategy = dedup.Delete{}
+	case "quarantine":
+		if *quarantine == "" {
+			return fmt.Errorf("-mode quarantine requires -quarantine")
+		}
+		q, err := dedup.NewQuarantine(dedup.OS{}, *quarantine, opts.Roots...)
+		if err != nil {
+			return err
+		}
+		opts.Strategy = q
+	default:
+		return fmt.Errorf("unsupported mode: %s", *mode)
+	}
+
+	// the journal is opened once the flags are known to be valid, so that
+	// an invalid invocation doesn't create it
+	if *journalPath != "" {
+		journal, err := os.OpenFile(
+			*journalPath,
+			os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+			0644,
+		)
+		if err != nil {
+			return fmt.Errorf("opening journal: %w", err)
+		}
+		defer journal.Close()
+		opts.Journal = journal
+	}
+
+	if err := dedup.Dedup(&opts); err != nil {
+		return err
+	}
+
+	if *mode == "quarantine" && *retention > 0 {
+		return dedup.Purge(&opts, *quarantine, *retention)
+	}
+	return nil
+}
+
+func similar(notify dedup.Notifier, args []string) error {
+	opts := dedup.Options{Notifier: notify}
+	var similarity dedup.SimilarityOptions
+	flags := flag.NewFlagSet("similar", flag.ExitOnError)
+	flags.Usage = usage(flags, "dedup similar [FLAGS] DIRECTORY...")
+	walkFlags(flags, &opts)
+	flags.Float64Var(
+		&similarity.Threshold,
+		"threshold",
+		0.5,
+		"the minimum fraction of the larger file two files must share",
+	)
+	flags.IntVar(
+		&similarity.AverageChunkSize,
+		"chunk-size",
+		8*1024,
+		"the average content-defined chunk size in bytes",
+	)
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	opts.Roots = flags.Args()
+
+	_, err := dedup.FindSimilar(&opts, similarity)
+	return err
+}
+
+func verify(notify dedup.Notifier, args []string) error {
+	opts := dedup.Options{Notifier: notify}
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags.Usage = usage(flags, "dedup verify [FLAGS] DIRECTORY...")
+	walkFlags(flags, &opts)
+	var journals []string
+	flags.Var(
+		(*stringsFlag)(&journals),
+		"journal",
+		"cross-check against this run journal or quarantine manifest "+
+			"(repeatable)",
+	)
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	opts.Roots = flags.Args()
+
+	var entries []dedup.JournalEntry
+	for _, path := range journals {
+		journal, err := dedup.ReadJournal(dedup.OS{}, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, journal...)
+	}
+
+	report, err := dedup.Verify(&opts, entries)
+	if err != nil {
+		return err
+	}
+	if len(report.Issues) > 0 {
+		return fmt.Errorf("verification found %d issues", len(report.Issues))
+	}
+	return nil
+}
+
+func restore(notify dedup.Notifier, args []string) error {
+	opts := dedup.Options{Notifier: notify}
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	flags.Usage = usage(flags, "dedup restore [-dry-run] QUARANTINE")
+	flags.BoolVar(
+		&opts.DryRun,
+		"dry-run",
+		false,
+		"report what would be done without modifying any files",
+	)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	return dedup.Restore(&opts, flags.Arg(0))
+}
+
+func purge(notify dedup.Notifier, args []string) error {
+	opts := dedup.Options{Notifier: notify}
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	flags.Usage = usage(
+		flags,
+		"dedup purge [-dry-run] [-retention DURATION] QUARANTINE",
+	)
+	flags.BoolVar(
+		&opts.DryRun,
+		"dry-run",
+		false,
+		"report what would be done without modifying any files",
+	)
+	retention := flags.Duration(
+		"retention",
+		30*24*time.Hour,
+		"delete quarantined files older than this",
+	)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	return dedup.Purge(&opts, flags.Arg(0), *retention)
+}
+
+// walkFlags registers the flags which control which files are scanned and
+// how many are processed at once.
+func walkFlags(flags *flag.FlagSet, opts *dedup.Options) {
+	flags.Var(
+		(*stringsFlag)(&opts.Include),
+		"include",
+		"only process files matching this glob (repeatable)",
+	)
+	flags.Var(
+		(*stringsFlag)(&opts.Exclude),
+		"exclude",
+		"skip files and directories matching this glob (repeatable)",
+	)
+	flags.Int64Var(
+		&opts.MinSize,
+		"min-size",
+		0,
+		"skip files smaller than this many bytes",
+	)
+	flags.IntVar(
+		&opts.Concurrency,
+		"concurrency",
+		1,
+		"the number of files to process at once",
+	)
+}
+
+func usage(flags *flag.FlagSet, synopsis string) func() {
+	return func() {
+		fmt.Fprintf(flags.Output(), "USAGE: %s\n", synopsis)
+		flags.PrintDefaults()
+	}
+}
+
+// stringsFlag is a repeatable string flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
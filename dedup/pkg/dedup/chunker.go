@@ -0,0 +1,129 @@
+package dedup
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// Chunker splits a stream into content-defined chunks using the FastCDC
+// algorithm: a gear rolling hash is computed over the stream and a chunk
+// boundary is declared wherever the hash matches a mask. Because boundaries
+// depend only on nearby content, an insertion or deletion in one part of a
+// file only changes the chunks around it.
+type Chunker struct {
+	r        io.Reader
+	buf      []byte
+	start    int
+	end      int
+	eof      bool
+	minSize  int
+	avgSize  int
+	maxSize  int
+	maskHard uint64
+	maskEasy uint64
+}
+
+// NewChunker creates a [Chunker] which produces chunks of `avgSize` bytes on
+// average, with a minimum of a quarter and a maximum of eight times that
+// size. `avgSize` is rounded up to a power of two.
+func NewChunker(r io.Reader, avgSize int) *Chunker {
+	shift := bits.Len(uint(max(avgSize, 64) - 1))
+	avgSize = 1 << shift
+	return &Chunker{
+		r:        r,
+		buf:      make([]byte, avgSize*8*2),
+		minSize:  avgSize / 4,
+		avgSize:  avgSize,
+		maxSize:  avgSize * 8,
+		maskHard: topBits(shift + 1),
+		maskEasy: topBits(shift - 1),
+	}
+}
+
+// Next returns the next chunk. The returned slice is only valid until the
+// next call to Next. At the end of the stream, Next returns [io.EOF].
+func (c *Chunker) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+
+	n := c.cut(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
+
+// fill reads from the underlying reader until the buffer holds at least
+// `maxSize` bytes or the stream is exhausted.
+func (c *Chunker) fill() error {
+	if c.eof || c.end-c.start >= c.maxSize {
+		return nil
+	}
+
+	c.end = copy(c.buf, c.buf[c.start:c.end])
+	c.start = 0
+	for !c.eof && c.end < len(c.buf) {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if errors.Is(err, io.EOF) {
+			c.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cut returns the length of the chunk at the start of `data`. Below the
+// average size a harder mask is used and above it an easier one, which
+// concentrates chunk sizes around the average ("normalized chunking").
+func (c *Chunker) cut(data []byte) int {
+	n := min(len(data), c.maxSize)
+	if n <= c.minSize {
+		return n
+	}
+	normal := min(n, c.avgSize)
+
+	var fp uint64
+	i := c.minSize
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskHard == 0 {
+			return i
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskEasy == 0 {
+			return i
+		}
+	}
+	return n
+}
+
+// topBits returns a mask of the `n` most significant bits. The high bits of
+// the gear hash depend on the most bytes, so masking them gives the widest
+// effective window.
+func topBits(n int) uint64 {
+	return ^uint64(0) << (64 - n)
+}
+
+// gear is the table of random values mixed into the rolling hash for each
+// byte. It is generated deterministically so chunk boundaries are stable
+// across runs.
+var gear = func() (table [256]uint64) {
+	// splitmix64
+	state := uint64(0x6a09e667f3bcc908)
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return
+}()
@@ -1,31 +1,36 @@
 package dedup
 
 import (
+	"bytes"
+	"cmp"
 	xslices "dedup/pkg/slices"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"hash/adler32"
+	"hash"
 	"io"
-	"os"
 	"slices"
+	"time"
 )
 
-func Dedup(notify Notifier, directory string) error {
-	files := NewFileIter(directory)
-
-	notify.ScanningDirectory(directory)
-	inos := make(map[uint64]struct{})
-	var uniqueInos []File
-	for file, err, ok := files.Next(); ok; file, err, ok = files.Next() {
-		if err != nil {
-			return err
-		}
+// Dedup finds duplicate files beneath `opts.Roots` and disposes of them
+// according to `opts.Strategy`.
+func Dedup(opts *Options) error {
+	if len(opts.Roots) < 1 {
+		return errNoRoots
+	}
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return err
+	}
 
-		if _, exists := inos[file.Ino]; exists {
-			continue
-		}
-		inos[file.Ino] = struct{}{}
-		uniqueInos = append(uniqueInos, file)
+	notify := opts.Notifier
+	for _, root := range opts.Roots {
+		notify.ScanningDirectory(root)
+	}
+	uniqueInos, err := collectUniqueInoFiles(opts)
+	if err != nil {
+		return err
 	}
 
 	notify.CollectedUniqueInoFiles(len(uniqueInos))
@@ -36,11 +41,14 @@ func Dedup(notify Notifier, directory string) error {
 		if l.Size > r.Size {
 			return -1
 		}
-		return 0
+		return cmp.Compare(l.Dev, r.Dev)
 	})
 
+	// hard links can't span devices, so when linking, files on different
+	// devices are never considered duplicates of one another
+	sameDevice := linksFiles(opts.Strategy)
 	sizeGroups := xslices.GroupBy(uniqueInos, func(l, r *File) bool {
-		return l.Size == r.Size
+		return l.Size == r.Size && (!sameDevice || l.Dev == r.Dev)
 	})
 
 	nonUniqueSizes := slices.DeleteFunc(sizeGroups, func(group []File) bool {
@@ -51,7 +59,7 @@ func Dedup(notify Notifier, directory string) error {
 
 	for i, sizeGroup := range nonUniqueSizes {
 		notify.ProcessingSizeGroup(nonUniqueSizes, i)
-		if err := ProcessSizeGroup(notify, sizeGroup); err != nil {
+		if err := ProcessSizeGroup(opts, sizeGroup); err != nil {
 			return err
 		}
 	}
@@ -59,13 +67,40 @@ func Dedup(notify Notifier, directory string) error {
 	return nil
 }
 
-const debug = false
+// inode uniquely identifies a file across devices.
+type inode struct {
+	dev uint64
+	ino uint64
+}
 
-func ProcessSizeGroup(notify Notifier, sizeGroup []File) error {
-	for i := range sizeGroup {
-		if err := sizeGroup[i].ChecksumBoundingBlocks(); err != nil {
-			return err
+// collectUniqueInoFiles returns the files beneath `opts.Roots`, including
+// only one path for each inode.
+func collectUniqueInoFiles(opts *Options) ([]File, error) {
+	files := NewFileIter(opts)
+	inos := make(map[inode]struct{})
+	var uniqueInos []File
+	for file, err, ok := files.Next(); ok; file, err, ok = files.Next() {
+		if err != nil {
+			return nil, err
 		}
+
+		ino := inode{dev: file.Dev, ino: file.Ino}
+		if _, exists := inos[ino]; exists {
+			continue
+		}
+		inos[ino] = struct{}{}
+		uniqueInos = append(uniqueInos, file)
+	}
+	return uniqueInos, nil
+}
+
+const debug = false
+
+func ProcessSizeGroup(opts *Options, sizeGroup []File) error {
+	if err := forEach(opts.Concurrency, len(sizeGroup), func(i int) error {
+		return sizeGroup[i].ChecksumBoundingBlocks(opts.FS)
+	}); err != nil {
+		return err
 	}
 
 	slices.SortFunc(sizeGroup, func(l, r File) int {
@@ -110,7 +145,7 @@ func ProcessSizeGroup(notify Notifier, sizeGroup []File) error {
 		byChecksums,
 		func(files []File) bool { return len(files) < 2 },
 	)
-	notify.IgnoringUniqueChecksums(
+	opts.Notifier.IgnoringUniqueChecksums(
 		sizeGroup[0].Size,
 		len(byChecksums)-len(nonUnique),
 		len(nonUnique),
@@ -126,7 +161,7 @@ func ProcessSizeGroup(notify Notifier, sizeGroup []File) error {
 		for i := range files {
 			group.Paths[i] = files[i].Path
 		}
-		if err := DedupGroup(notify, &group); err != nil {
+		if err := DedupGroup(opts, &group); err != nil {
 			return err
 		}
 	}
@@ -134,30 +169,61 @@ func ProcessSizeGroup(notify Notifier, sizeGroup []File) error {
 	return nil
 }
 
-func DedupGroup(notify Notifier, group *Group) error {
+// DedupGroup checksums each file in `group` and disposes of those which are
+// identical to the group's canonical (first) file according to
+// `opts.Strategy`.
+func DedupGroup(opts *Options, group *Group) error {
+	notify := opts.Notifier
 	notify.ProcessingGroup(group)
-	notify.ChecksummingFile(group.Paths[0])
 	if err := ensureUniquePath(
 		group.Paths,
 		func(p *string) string { return *p },
 	); err != nil {
 		return err
 	}
-	firstChecksum, err := ChecksumFile(group.Paths[0])
-	if err != nil {
+
+	checksums := make([]string, len(group.Paths))
+	if err := forEach(opts.Concurrency, len(group.Paths), func(i int) error {
+		notify.ChecksummingFile(group.Paths[i])
+		checksum, err := ChecksumFile(opts.FS, opts.Hash, group.Paths[i])
+		checksums[i] = checksum
+		return err
+	}); err != nil {
 		return err
 	}
 
-	for _, path := range group.Paths[1:] {
-		notify.ChecksummingFile(path)
-		checksum, err := ChecksumFile(path)
-		if err != nil {
-			return err
-		}
-
-		if checksum == firstChecksum {
-			notify.RemovingDuplicateFile(group.Size, path)
-			if err := ToLink(path, group.Paths[0]); err != nil {
+	for i, path := range group.Paths[1:] {
+		if checksums[i+1] == checksums[0] {
+			// every strategy discards the duplicate's contents, so unless
+			// the hash rules out collisions, make sure they really are the
+			// same as the canonical file's
+			if !opts.Hash.collisionResistant() {
+				same, err := sameContents(opts.FS, group.Paths[0], path)
+				if err != nil {
+					return err
+				}
+				if !same {
+					notify.IgnoringChecksumCollision(path, group.Paths[0])
+					continue
+				}
+			}
+			if err := opts.Strategy.Dedup(
+				opts,
+				path,
+				group.Paths[0],
+				group.Size,
+			); err != nil {
+				return err
+			}
+			if err := journal(opts, &JournalEntry{
+				Time:      time.Now(),
+				Action:    strategyAction(opts.Strategy),
+				Path:      path,
+				Canonical: group.Paths[0],
+				Size:      group.Size,
+				Hash:      opts.Hash,
+				Checksum:  checksums[0],
+			}); err != nil {
 				return err
 			}
 		}
@@ -166,13 +232,8 @@ func DedupGroup(notify Notifier, group *Group) error {
 	return nil
 }
 
-const dryRun = false
-
-func ToLink(linkFile, linkedFile string) (err error) {
-	if dryRun {
-		return nil
-	}
-
+// ToLink replaces `linkFile` with a hard link to `linkedFile`.
+func ToLink(fsys FS, linkFile, linkedFile string) (err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf(
@@ -185,44 +246,109 @@ func ToLink(linkFile, linkedFile string) (err error) {
 	}()
 
 	backup := linkFile + ".dedup-backup"
-	if err := os.Rename(linkFile, backup); err != nil {
+	if err := fsys.Rename(linkFile, backup); err != nil {
 		return fmt.Errorf("creating backup link: %w", err)
 	}
 
-	if err := os.Link(linkedFile, linkFile); err != nil {
+	if err := fsys.Link(linkedFile, linkFile); err != nil {
+		// put the original file back rather than leaving it at the backup
+		// path
+		if restoreErr := fsys.Rename(backup, linkFile); restoreErr != nil {
+			err = errors.Join(
+				err,
+				fmt.Errorf("restoring backup link: %w", restoreErr),
+			)
+		}
 		return fmt.Errorf("creating new link: %w", err)
 	}
 
-	if err := os.Remove(backup); err != nil {
+	if err := fsys.Remove(backup); err != nil {
 		return fmt.Errorf("removing backup link: %w", err)
 	}
 	return nil
 }
 
-func ChecksumFile(path string) (checksum uint32, err error) {
+// ChecksumFile returns the hex-encoded checksum of the file at `path` using
+// the `algorithm` hash.
+func ChecksumFile(
+	fsys FS,
+	algorithm Hash,
+	path string,
+) (checksum string, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("checksumming file `%s`: %w", path, err)
 		}
 	}()
 
-	var file *os.File
-	if file, err = os.Open(path); err != nil {
+	var h hash.Hash
+	if h, err = algorithm.New(); err != nil {
+		return
+	}
+
+	var file io.ReadSeekCloser
+	if file, err = fsys.Open(path); err != nil {
 		err = fmt.Errorf("opening file: %w", err)
 		return
 	}
 	defer func() { err = errors.Join(err, file.Close()) }()
 
-	hash := adler32.New()
-	if _, err = io.Copy(hash, file); err != nil {
+	if _, err = io.Copy(h, file); err != nil {
 		err = fmt.Errorf("hashing file contents: %w", err)
 		return
 	}
 
-	checksum = hash.Sum32()
+	checksum = hex.EncodeToString(h.Sum(nil))
 	return
 }
 
+// sameContents returns true if the files at `a` and `b` have identical
+// contents.
+func sameContents(fsys FS, a, b string) (same bool, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("comparing files `%s` and `%s`: %w", a, b, err)
+		}
+	}()
+
+	var fileA, fileB io.ReadSeekCloser
+	if fileA, err = fsys.Open(a); err != nil {
+		return
+	}
+	defer func() { err = errors.Join(err, fileA.Close()) }()
+	if fileB, err = fsys.Open(b); err != nil {
+		return
+	}
+	defer func() { err = errors.Join(err, fileB.Close()) }()
+
+	var bufA, bufB [32 * 1024]byte
+	for {
+		var nA, nB int
+		if nA, err = readBlock(fileA, bufA[:]); err != nil {
+			return
+		}
+		if nB, err = readBlock(fileB, bufB[:]); err != nil {
+			return
+		}
+		if nA != nB || !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false, nil
+		}
+		if nA < len(bufA) {
+			return true, nil
+		}
+	}
+}
+
+// readBlock fills `buf` from `r`, returning fewer than `len(buf)` bytes only
+// at the end of the file.
+func readBlock(r io.Reader, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	return n, err
+}
+
 func ensureUniquePath[T any](group []T, pathfn func(*T) string) error {
 	if debug {
 		seen := make(map[string]struct{})
@@ -0,0 +1,644 @@
+package dedup
+
+import (
+	"bytes"
+	"errors"
+	"hash/adler32"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestFS returns a [MemFS] containing `files`, keyed by path.
+func newTestFS(t *testing.T, files map[string]string) *MemFS {
+	t.Helper()
+	fsys := NewMemFS()
+	for path, data := range files {
+		if err := fsys.WriteFile(path, []byte(data)); err != nil {
+			t.Fatalf("writing `%s`: %v", path, err)
+		}
+	}
+	return fsys
+}
+
+func assertContents(t *testing.T, fsys *MemFS, path, wanted string) {
+	t.Helper()
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading `%s`: %v", path, err)
+	}
+	if string(data) != wanted {
+		t.Fatalf("`%s`: wanted %q; found %q", path, wanted, data)
+	}
+}
+
+func assertMissing(t *testing.T, fsys FS, path string) {
+	t.Helper()
+	if _, err := fsys.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("`%s`: wanted fs.ErrNotExist; found %v", path, err)
+	}
+}
+
+func lstat(t *testing.T, fsys FS, path string) Stat {
+	t.Helper()
+	stat, err := fsys.Lstat(path)
+	if err != nil {
+		t.Fatalf("stat `%s`: %v", path, err)
+	}
+	return stat
+}
+
+var testFiles = map[string]string{
+	"/data/a":      "duplicate contents",
+	"/data/sub/b":  "duplicate contents",
+	"/data/c":      "distinct contents!",
+	"/data/unique": "a file with a unique size",
+}
+
+func TestDedupLink(t *testing.T) {
+	fsys := newTestFS(t, testFiles)
+	if err := Dedup(&Options{Roots: []string{"/data"}, FS: fsys}); err != nil {
+		t.Fatalf("Dedup(): unexpected err: %v", err)
+	}
+
+	a, b, c := lstat(t, fsys, "/data/a"), lstat(t, fsys, "/data/sub/b"),
+		lstat(t, fsys, "/data/c")
+	if a.Ino != b.Ino {
+		t.Fatalf("wanted `/data/sub/b` to be linked to `/data/a`")
+	}
+	if a.Nlink != 2 {
+		t.Fatalf("Nlink: wanted 2; found %d", a.Nlink)
+	}
+	if c.Ino == a.Ino {
+		t.Fatalf("wanted `/data/c` not to be linked to `/data/a`")
+	}
+	assertContents(t, fsys, "/data/sub/b", "duplicate contents")
+	assertMissing(t, fsys, "/data/sub/b.dedup-backup")
+}
+
+func TestDedupDryRun(t *testing.T) {
+	fsys := newTestFS(t, testFiles)
+	if err := Dedup(&Options{
+		Roots:    []string{"/data"},
+		FS:       fsys,
+		Strategy: Delete{},
+		DryRun:   true,
+	}); err != nil {
+		t.Fatalf("Dedup(): unexpected err: %v", err)
+	}
+	assertContents(t, fsys, "/data/sub/b", "duplicate contents")
+}
+
+func TestDedupDelete(t *testing.T) {
+	fsys := newTestFS(t, testFiles)
+	if err := Dedup(&Options{
+		Roots:    []string{"/data"},
+		FS:       fsys,
+		Strategy: Delete{},
+	}); err != nil {
+		t.Fatalf("Dedup(): unexpected err: %v", err)
+	}
+
+	assertContents(t, fsys, "/data/a", "duplicate contents")
+	assertMissing(t, fsys, "/data/sub/b")
+	assertContents(t, fsys, "/data/c", "distinct contents!")
+}
+
+func TestDedupQuarantine(t *testing.T) {
+	fsys := newTestFS(t, testFiles)
+	q, err := NewQuarantine(fsys, "/quarantine", "/data")
+	if err != nil {
+		t.Fatalf("NewQuarantine(): unexpected err: %v", err)
+	}
+	opts := Options{Roots: []string{"/data"}, FS: fsys, Strategy: q}
+	if err := Dedup(&opts); err != nil {
+		t.Fatalf("Dedup(): unexpected err: %v", err)
+	}
+
+	assertMissing(t, fsys, "/data/sub/b")
+	assertContents(t, fsys, "/quarantine/files/sub/b", "duplicate contents")
+	entries, err := ReadManifest(fsys, "/quarantine")
+	if err != nil {
+		t.Fatalf("ReadManifest(): unexpected err: %v", err)
+	}
+	if len(entries) != 1 ||
+		entries[0].Path != "/data/sub/b" ||
+		entries[0].Canonical != "/data/a" ||
+		entries[0].Quarantined != "/quarantine/files/sub/b" {
+		t.Fatalf("ReadManifest(): unexpected entries: %+v", entries)
+	}
+
+	// restoring moves the file back and empties the manifest
+	if err := Restore(&opts, "/quarantine"); err != nil {
+		t.Fatalf("Restore(): unexpected err: %v", err)
+	}
+	assertContents(t, fsys, "/data/sub/b", "duplicate contents")
+	assertMissing(t, fsys, "/quarantine/files/sub/b")
+	if entries, err := ReadManifest(fsys, "/quarantine"); err != nil ||
+		len(entries) != 0 {
+		t.Fatalf(
+			"ReadManifest(): wanted no entries; found %v, %v",
+			entries,
+			err,
+		)
+	}
+
+	// purging deletes files which have aged out
+	if err := Dedup(&opts); err != nil {
+		t.Fatalf("Dedup(): unexpected err: %v", err)
+	}
+	if err := Purge(&opts, "/quarantine", time.Hour); err != nil {
+		t.Fatalf("Purge(): unexpected err: %v", err)
+	}
+	assertContents(t, fsys, "/quarantine/files/sub/b", "duplicate contents")
+	if err := Purge(&opts, "/quarantine", 0); err != nil {
+		t.Fatalf("Purge(): unexpected err: %v", err)
+	}
+	assertMissing(t, fsys, "/quarantine/files/sub/b")
+	assertMissing(t, fsys, "/quarantine/files/sub")
+}
+
+func TestDedupChecksumCollision(t *testing.T) {
+	// adding 1, -2 and 1 to three consecutive bytes leaves both of adler32's
+	// sums unchanged
+	const a, b = "colliding contents: abc", "colliding contents: b`d"
+	if adler32.Checksum([]byte(a)) != adler32.Checksum([]byte(b)) {
+		t.Fatalf("wanted %q and %q to have the same checksum", a, b)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		strategy func(fsys FS) (Strategy, error)
+	}{
+		{
+			name:     "link",
+			strategy: func(FS) (Strategy, error) { return Link{}, nil },
+		},
+		{
+			name:     "delete",
+			strategy: func(FS) (Strategy, error) { return Delete{}, nil },
+		},
+		{
+			name: "quarantine",
+			strategy: func(fsys FS) (Strategy, error) {
+				return NewQuarantine(fsys, "/quarantine", "/data")
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := newTestFS(t, map[string]string{"/data/a": a, "/data/b": b})
+			strategy, err := tc.strategy(fsys)
+			if err != nil {
+				t.Fatalf("creating strategy: %v", err)
+			}
+			if err := Dedup(&Options{
+				Roots:    []string{"/data"},
+				FS:       fsys,
+				Strategy: strategy,
+			}); err != nil {
+				t.Fatalf("Dedup(): unexpected err: %v", err)
+			}
+
+			assertContents(t, fsys, "/data/a", a)
+			assertContents(t, fsys, "/data/b", b)
+			if lstat(t, fsys, "/data/a").Ino == lstat(t, fsys, "/data/b").Ino {
+				t.Fatalf("wanted `/data/b` not to be linked to `/data/a`")
+			}
+		})
+	}
+}
+
+func TestDedupJournalAndVerify(t *testing.T) {
+	fsys := newTestFS(t, testFiles)
+	var journal bytes.Buffer
+	opts := Options{Roots: []string{"/data"}, FS: fsys, Journal: &journal}
+	if err := Dedup(&opts); err != nil {
+		t.Fatalf("Dedup(): unexpected err: %v", err)
+	}
+	if err := fsys.WriteFile("/journal.jsonl", journal.Bytes()); err != nil {
+		t.Fatalf("writing journal: %v", err)
+	}
+	entries, err := ReadJournal(fsys, "/journal.jsonl")
+	if err != nil {
+		t.Fatalf("ReadJournal(): unexpected err: %v", err)
+	}
+	if len(entries) != 1 ||
+		entries[0].Action != "link" ||
+		entries[0].Path != "/data/sub/b" ||
+		entries[0].Canonical != "/data/a" ||
+		entries[0].Checksum == "" {
+		t.Fatalf("ReadJournal(): unexpected entries: %+v", entries)
+	}
+
+	report, err := Verify(&opts, entries)
+	if err != nil {
+		t.Fatalf("Verify(): unexpected err: %v", err)
+	}
+	if len(report.Groups) != 1 ||
+		strings.Join(report.Groups[0].Paths, ",") != "/data/a,/data/sub/b" {
+		t.Fatalf("Verify(): unexpected groups: %+v", report.Groups)
+	}
+	if len(report.Issues) != 0 {
+		t.Fatalf("Verify(): unexpected issues: %+v", report.Issues)
+	}
+
+	// replace the link with a copy and change the canonical file
+	if err := fsys.Remove("/data/sub/b"); err != nil {
+		t.Fatalf("removing link: %v", err)
+	}
+	err = fsys.WriteFile("/data/sub/b", []byte("duplicate contents"))
+	if err != nil {
+		t.Fatalf("writing copy: %v", err)
+	}
+	err = fsys.WriteFile("/data/a", []byte("changed contents!!"))
+	if err != nil {
+		t.Fatalf("changing canonical file: %v", err)
+	}
+	if report, err = Verify(&opts, entries); err != nil {
+		t.Fatalf("Verify(): unexpected err: %v", err)
+	}
+	var kinds []string
+	for _, issue := range report.Issues {
+		kinds = append(kinds, string(issue.Kind)+" "+issue.Path)
+	}
+	if wanted := "unlinked /data/sub/b,changed /data/a"; strings.Join(
+		kinds,
+		",",
+	) != wanted {
+		t.Fatalf("Verify(): wanted issues %s; found %v", wanted, kinds)
+	}
+
+	// missing files are reported rather than failing verification
+	if err := fsys.Remove("/data/a"); err != nil {
+		t.Fatalf("removing canonical file: %v", err)
+	}
+	if report, err = Verify(&opts, entries); err != nil {
+		t.Fatalf("Verify(): unexpected err: %v", err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != IssueMissing {
+		t.Fatalf("Verify(): unexpected issues: %+v", report.Issues)
+	}
+}
+
+func TestDedupJournalPaths(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fs      func(t *testing.T) FS
+		wantAbs bool
+	}{
+		{
+			// host paths are made absolute, so the journal can be verified
+			// from any working directory
+			name: "os",
+			fs: func(t *testing.T) FS {
+				chdir(t, t.TempDir())
+				return OS{}
+			},
+			wantAbs: true,
+		},
+		{
+			// other filesystems' paths are recorded as they were found
+			name: "memfs",
+			fs:   func(t *testing.T) FS { return NewMemFS() },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := tc.fs(t)
+			if err := fsys.MkdirAll("data", 0755); err != nil {
+				t.Fatalf("creating `data`: %v", err)
+			}
+			for _, path := range []string{"data/a", "data/b"} {
+				writeFile(t, fsys, path, "duplicate")
+			}
+			var journal bytes.Buffer
+			if err := Dedup(&Options{
+				Roots:   []string{"data"},
+				FS:      fsys,
+				Journal: &journal,
+			}); err != nil {
+				t.Fatalf("Dedup(): unexpected err: %v", err)
+			}
+
+			writeFile(t, fsys, "journal.jsonl", journal.String())
+			entries, err := ReadJournal(fsys, "journal.jsonl")
+			if err != nil {
+				t.Fatalf("ReadJournal(): unexpected err: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("ReadJournal(): unexpected entries: %+v", entries)
+			}
+			for _, path := range []string{
+				entries[0].Path,
+				entries[0].Canonical,
+			} {
+				if filepath.IsAbs(path) != tc.wantAbs {
+					t.Fatalf(
+						"`%s`: wanted absolute path: %t",
+						path,
+						tc.wantAbs,
+					)
+				}
+			}
+			if !tc.wantAbs && entries[0].Canonical != "data/a" {
+				t.Fatalf(
+					"wanted canonical path `data/a`; found `%s`",
+					entries[0].Canonical,
+				)
+			}
+		})
+	}
+}
+
+func TestQuarantinePaths(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fs      func(t *testing.T) FS
+		wantAbs bool
+	}{
+		{
+			// host paths are made absolute, like the journal's
+			name: "os",
+			fs: func(t *testing.T) FS {
+				chdir(t, t.TempDir())
+				return OS{}
+			},
+			wantAbs: true,
+		},
+		{
+			// other filesystems' paths don't depend on the host's working
+			// directory
+			name: "memfs",
+			fs:   func(t *testing.T) FS { return NewMemFS() },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := tc.fs(t)
+			if err := fsys.MkdirAll("data", 0755); err != nil {
+				t.Fatalf("creating `data`: %v", err)
+			}
+			for _, path := range []string{"data/a", "data/b"} {
+				writeFile(t, fsys, path, "duplicate")
+			}
+			q, err := NewQuarantine(fsys, "quarantine", "data")
+			if err != nil {
+				t.Fatalf("NewQuarantine(): unexpected err: %v", err)
+			}
+			opts := Options{Roots: []string{"data"}, FS: fsys, Strategy: q}
+			if err := Dedup(&opts); err != nil {
+				t.Fatalf("Dedup(): unexpected err: %v", err)
+			}
+
+			entries, err := ReadManifest(fsys, "quarantine")
+			if err != nil {
+				t.Fatalf("ReadManifest(): unexpected err: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("ReadManifest(): unexpected entries: %+v", entries)
+			}
+			for _, path := range []string{
+				entries[0].Path,
+				entries[0].Canonical,
+				entries[0].Quarantined,
+			} {
+				if filepath.IsAbs(path) != tc.wantAbs {
+					t.Fatalf(
+						"`%s`: wanted absolute path: %t",
+						path,
+						tc.wantAbs,
+					)
+				}
+			}
+			quarantined := filepath.Join("quarantine", quarantineFiles, "b")
+			if !tc.wantAbs && entries[0].Quarantined != quarantined {
+				t.Fatalf(
+					"wanted quarantined path `%s`; found `%s`",
+					quarantined,
+					entries[0].Quarantined,
+				)
+			}
+
+			if err := Purge(&opts, "quarantine", 0); err != nil {
+				t.Fatalf("Purge(): unexpected err: %v", err)
+			}
+			_, err = fsys.Lstat(entries[0].Quarantined)
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("wanted the quarantined file purged; found %v", err)
+			}
+		})
+	}
+}
+
+// chdir changes the working directory to `dir` for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getting working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("changing directory to `%s`: %v", dir, err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatalf("restoring working directory: %v", err)
+		}
+	})
+}
+
+// writeFile creates the file at `path` in `fsys` with `data`.
+func writeFile(t *testing.T, fsys FS, path, data string) {
+	t.Helper()
+	file, err := fsys.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err == nil {
+		_, err = io.WriteString(file, data)
+		err = errors.Join(err, file.Close())
+	}
+	if err != nil {
+		t.Fatalf("writing `%s`: %v", path, err)
+	}
+}
+
+// failingFS is a [MemFS] whose `Link` and `OpenFile` fail for paths matching
+// `fail`.
+type failingFS struct {
+	*MemFS
+	fail func(op, path string) error
+}
+
+func (fsys failingFS) Link(oldpath, newpath string) error {
+	if err := fsys.fail("link", newpath); err != nil {
+		return err
+	}
+	return fsys.MemFS.Link(oldpath, newpath)
+}
+
+func (fsys failingFS) OpenFile(
+	path string,
+	flag int,
+	perm fs.FileMode,
+) (io.WriteCloser, error) {
+	if err := fsys.fail("open", path); err != nil {
+		return nil, err
+	}
+	return fsys.MemFS.OpenFile(path, flag, perm)
+}
+
+func TestDedupLinkFailureRestoresBackup(t *testing.T) {
+	fsys := failingFS{
+		MemFS: newTestFS(t, testFiles),
+		fail: func(op, path string) error {
+			if op == "link" {
+				return pathError(op, path, syscall.EXDEV)
+			}
+			return nil
+		},
+	}
+	err := Dedup(&Options{Roots: []string{"/data"}, FS: fsys})
+	if !errors.Is(err, syscall.EXDEV) {
+		t.Fatalf("Dedup(): wanted EXDEV; found %v", err)
+	}
+	assertContents(t, fsys.MemFS, "/data/sub/b", "duplicate contents")
+	assertMissing(t, fsys, "/data/sub/b.dedup-backup")
+}
+
+func TestQuarantineManifestFailure(t *testing.T) {
+	fsys := failingFS{
+		MemFS: newTestFS(t, testFiles),
+		fail: func(op, path string) error {
+			if op == "open" && filepath.Base(path) == quarantineManifest {
+				return pathError(op, path, syscall.EACCES)
+			}
+			return nil
+		},
+	}
+	q, err := NewQuarantine(fsys, "/quarantine", "/data")
+	if err != nil {
+		t.Fatalf("NewQuarantine(): unexpected err: %v", err)
+	}
+	err = Dedup(&Options{Roots: []string{"/data"}, FS: fsys, Strategy: q})
+	if !errors.Is(err, syscall.EACCES) {
+		t.Fatalf("Dedup(): wanted EACCES; found %v", err)
+	}
+	assertContents(t, fsys.MemFS, "/data/sub/b", "duplicate contents")
+	assertMissing(t, fsys, "/quarantine/files/sub/b")
+}
+
+// deviceFS is a [MemFS] whose files are on the device numbered by their
+// top-level directory in `devices`.
+type deviceFS struct {
+	*MemFS
+	devices map[string]uint64
+}
+
+func (fsys deviceFS) Lstat(path string) (Stat, error) {
+	stat, err := fsys.MemFS.Lstat(path)
+	top := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+	stat.Dev = fsys.devices[top]
+	return stat, err
+}
+
+func TestDedupLinkSkipsOtherDevices(t *testing.T) {
+	fsys := deviceFS{
+		MemFS: newTestFS(t, map[string]string{
+			"/one/a": "duplicate contents",
+			"/two/b": "duplicate contents",
+		}),
+		devices: map[string]uint64{"one": 1, "two": 2},
+	}
+	opts := Options{Roots: []string{"/one", "/two"}, FS: fsys}
+	if err := Dedup(&opts); err != nil {
+		t.Fatalf("Dedup(): unexpected err: %v", err)
+	}
+	if lstat(t, fsys, "/one/a").Ino == lstat(t, fsys, "/two/b").Ino {
+		t.Fatalf("wanted files on different devices not to be linked")
+	}
+
+	// other strategies dedup across devices
+	opts.Strategy = Delete{}
+	if err := Dedup(&opts); err != nil {
+		t.Fatalf("Dedup(): unexpected err: %v", err)
+	}
+	assertContents(t, fsys.MemFS, "/one/a", "duplicate contents")
+	assertMissing(t, fsys, "/two/b")
+}
+
+func TestMemFSRenameToSameFile(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		newpath string
+	}{
+		{name: "same path", newpath: "/data/a"},
+		{name: "uncleaned path", newpath: "/data/./a"},
+		{name: "hard link", newpath: "/data/link"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := newTestFS(t, map[string]string{"/data/a": "contents"})
+			if err := fsys.Link("/data/a", "/data/link"); err != nil {
+				t.Fatalf("Link(): unexpected err: %v", err)
+			}
+			if err := fsys.Rename("/data/a", tc.newpath); err != nil {
+				t.Fatalf("Rename(): unexpected err: %v", err)
+			}
+
+			for _, path := range []string{"/data/a", "/data/link"} {
+				assertContents(t, fsys, path, "contents")
+				if nlink := lstat(t, fsys, path).Nlink; nlink != 2 {
+					t.Fatalf("`%s`: wanted Nlink 2; found %d", path, nlink)
+				}
+			}
+		})
+	}
+}
+
+func TestFindSimilar(t *testing.T) {
+	random := rand.New(rand.NewPCG(1, 2))
+	randomBytes := func(n int) []byte {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(random.Uint32())
+		}
+		return data
+	}
+
+	original := randomBytes(64 * 1024)
+	edited := append(bytes.Clone(original[:32*1024]), randomBytes(100)...)
+	edited = append(edited, original[32*1024:]...)
+	fsys := newTestFS(t, map[string]string{
+		"/data/original": string(original),
+		"/data/edited":   string(edited),
+		"/data/other":    string(randomBytes(64 * 1024)),
+	})
+
+	clusters, err := FindSimilar(
+		&Options{Roots: []string{"/data"}, FS: fsys},
+		SimilarityOptions{AverageChunkSize: 1024},
+	)
+	if err != nil {
+		t.Fatalf("FindSimilar(): unexpected err: %v", err)
+	}
+	if len(clusters) != 1 {
+		t.Fatalf("FindSimilar(): wanted 1 cluster; found %+v", clusters)
+	}
+	cluster := clusters[0]
+	paths := slices.Clone(cluster.Paths)
+	slices.Sort(paths)
+	if !slices.Equal(paths, []string{"/data/edited", "/data/original"}) {
+		t.Fatalf("FindSimilar(): unexpected paths: %v", cluster.Paths)
+	}
+	if len(cluster.Pairs) != 1 || cluster.Pairs[0].Similarity < 0.9 {
+		t.Fatalf("FindSimilar(): unexpected pairs: %+v", cluster.Pairs)
+	}
+	if savings := cluster.Savings(); savings < 60*1024 {
+		t.Fatalf("Savings(): wanted at least 60KiB; found %d", savings)
+	}
+
+	// nothing is modified
+	assertContents(t, fsys, "/data/edited", string(edited))
+}
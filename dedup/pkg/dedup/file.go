@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"hash/adler32"
 	"io"
-	"os"
 )
 
 // File is the metadata for a file.
@@ -16,9 +15,15 @@ type File struct {
 	// Size is the size of the file.
 	Size int64
 
+	// Dev identifies the device containing the file.
+	Dev uint64
+
 	// Ino identifies the file's inode.
 	Ino uint64
 
+	// Nlink is the number of hard links to the file's inode.
+	Nlink uint64
+
 	// FirstBlockChecksum is the checksum of the first block in the file.
 	FirstBlockChecksum uint32
 
@@ -28,7 +33,7 @@ type File struct {
 
 // ChecksumBoundingBlocks computes the first and final block checksums for the
 // file.
-func (f *File) ChecksumBoundingBlocks() (err error) {
+func (f *File) ChecksumBoundingBlocks(fsys FS) (err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf(
@@ -39,8 +44,8 @@ func (f *File) ChecksumBoundingBlocks() (err error) {
 		}
 	}()
 
-	var file *os.File
-	if file, err = os.Open(f.Path); err != nil {
+	var file io.ReadSeekCloser
+	if file, err = fsys.Open(f.Path); err != nil {
 		return
 	}
 	defer func() { err = errors.Join(err, file.Close()) }()
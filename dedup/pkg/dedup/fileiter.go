@@ -3,20 +3,24 @@ package dedup
 import (
 	"fmt"
 	"io/fs"
-	"os"
 	"path/filepath"
-	"syscall"
 )
 
+// FileIter walks the regular files beneath a set of root directories,
+// skipping those excluded by the [Options] filters.
 type FileIter struct {
+	opts        *Options
 	directory   string
 	directories []string
 	entries     []fs.DirEntry
 	cursor      int
 }
 
-func NewFileIter(directory string) (iter FileIter) {
-	iter.directories = []string{directory}
+// NewFileIter creates a [FileIter] over `opts.Roots`. `opts` must already
+// have its defaults applied.
+func NewFileIter(opts *Options) (iter FileIter) {
+	iter.opts = opts
+	iter.directories = append(iter.directories, opts.Roots...)
 	return
 }
 
@@ -31,13 +35,15 @@ func (iter *FileIter) Next() (file File, err error, ok bool) {
 			)
 
 			if iter.entries[iter.cursor].IsDir() {
-				iter.directories = append(iter.directories, path)
+				if !iter.opts.skipDir(path) {
+					iter.directories = append(iter.directories, path)
+				}
 				iter.cursor++
 				continue
 			}
 
-			var info fs.FileInfo
-			info, err = iter.entries[iter.cursor].Info()
+			var stat Stat
+			stat, err = iter.opts.FS.Lstat(path)
 			if err != nil {
 				err = fmt.Errorf(
 					"fetching info for file `%s`: %w",
@@ -48,11 +54,18 @@ func (iter *FileIter) Next() (file File, err error, ok bool) {
 				return
 			}
 
+			// skip symlinks, devices, etc as well as filtered files
+			iter.cursor++
+			if !stat.Mode.IsRegular() || iter.opts.skipFile(path, &stat) {
+				continue
+			}
+
 			file.Path = path
-			file.Ino = info.Sys().(*syscall.Stat_t).Ino
-			file.Size = info.Size()
+			file.Dev = stat.Dev
+			file.Ino = stat.Ino
+			file.Nlink = stat.Nlink
+			file.Size = stat.Size
 			ok = true
-			iter.cursor++
 			return
 		}
 
@@ -69,7 +82,7 @@ func (iter *FileIter) Next() (file File, err error, ok bool) {
 		iter.directories = iter.directories[1:]
 
 		// read the next directory
-		if iter.entries, err = os.ReadDir(
+		if iter.entries, err = iter.opts.FS.ReadDir(
 			iter.directory,
 		); err != nil {
 			err = fmt.Errorf(
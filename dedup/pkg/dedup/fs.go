@@ -0,0 +1,127 @@
+package dedup
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// FS is the filesystem that dedup reads and modifies. [OS] is the host
+// filesystem and [MemFS] is an in-memory filesystem for tests and for
+// embedding dedup in other tools.
+type FS interface {
+	// ReadDir reads the entries of the directory at `path`.
+	ReadDir(path string) ([]fs.DirEntry, error)
+
+	// Lstat returns metadata for the file at `path` without following
+	// symbolic links.
+	Lstat(path string) (Stat, error)
+
+	// Open opens the file at `path` for reading.
+	Open(path string) (io.ReadSeekCloser, error)
+
+	// OpenFile opens the file at `path` for writing. `flag` is a combination
+	// of the `os.O_*` flags.
+	OpenFile(path string, flag int, perm fs.FileMode) (io.WriteCloser, error)
+
+	// MkdirAll creates the directory at `path` along with any missing
+	// parents.
+	MkdirAll(path string, perm fs.FileMode) error
+
+	// Rename moves `oldpath` to `newpath`, replacing any file at `newpath`.
+	Rename(oldpath, newpath string) error
+
+	// Link creates `newpath` as a hard link to `oldpath`.
+	Link(oldpath, newpath string) error
+
+	// Remove removes the file or empty directory at `path`.
+	Remove(path string) error
+}
+
+// Stat is the file metadata that dedup relies upon.
+type Stat struct {
+	// Mode is the file's mode and permission bits.
+	Mode fs.FileMode
+
+	// Size is the size of the file.
+	Size int64
+
+	// Dev identifies the device containing the file.
+	Dev uint64
+
+	// Ino identifies the file's inode.
+	Ino uint64
+
+	// Nlink is the number of hard links to the file's inode.
+	Nlink uint64
+
+	// ModTime is the file's modification time.
+	ModTime time.Time
+}
+
+// OS is the host operating system's filesystem.
+type OS struct{}
+
+// ReadDir implements [FS].
+func (OS) ReadDir(path string) ([]fs.DirEntry, error) { return os.ReadDir(path) }
+
+// Lstat implements [FS].
+func (OS) Lstat(path string) (stat Stat, err error) {
+	var info fs.FileInfo
+	if info, err = os.Lstat(path); err != nil {
+		return
+	}
+
+	stat.Mode = info.Mode()
+	stat.Size = info.Size()
+	stat.ModTime = info.ModTime()
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		stat.Dev = uint64(sys.Dev)
+		stat.Ino = sys.Ino
+		stat.Nlink = uint64(sys.Nlink)
+	}
+	return
+}
+
+// Open implements [FS].
+func (OS) Open(path string) (io.ReadSeekCloser, error) { return os.Open(path) }
+
+// OpenFile implements [FS].
+func (OS) OpenFile(
+	path string,
+	flag int,
+	perm fs.FileMode,
+) (io.WriteCloser, error) {
+	return os.OpenFile(path, flag, perm)
+}
+
+// MkdirAll implements [FS].
+func (OS) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+// Rename implements [FS].
+func (OS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// Link implements [FS].
+func (OS) Link(oldpath, newpath string) error { return os.Link(oldpath, newpath) }
+
+// Remove implements [FS].
+func (OS) Remove(path string) error { return os.Remove(path) }
+
+// absPath makes `path` absolute if `fsys` is the host filesystem, whose
+// relative paths depend on the working directory. Paths on other filesystems
+// are returned as-is, since the host's working directory has no bearing on
+// them.
+func absPath(fsys FS, path string) (string, error) {
+	switch fsys.(type) {
+	case OS, *OS:
+		return filepath.Abs(path)
+	}
+	return path, nil
+}
@@ -0,0 +1,54 @@
+package dedup
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+)
+
+// Hash identifies the algorithm used to checksum whole files when confirming
+// that they are duplicates.
+type Hash string
+
+const (
+	// Adler32 is fast but weak; it is the default for compatibility with
+	// earlier versions of dedup. Files whose checksums match are compared
+	// byte-for-byte before they are deduplicated, as they are with every
+	// algorithm other than [SHA256].
+	Adler32 Hash = "adler32"
+
+	// CRC32 is the IEEE CRC-32 checksum.
+	CRC32 Hash = "crc32"
+
+	// SHA1 is the SHA-1 hash.
+	SHA1 Hash = "sha1"
+
+	// SHA256 is the SHA-256 hash. It is the slowest of the supported
+	// algorithms, but collisions are not a practical concern.
+	SHA256 Hash = "sha256"
+)
+
+// collisionResistant returns true if files with matching checksums can be
+// assumed to be identical without comparing their contents.
+func (h Hash) collisionResistant() bool {
+	return h == SHA256
+}
+
+// New creates a new [hash.Hash] for the algorithm.
+func (h Hash) New() (hash.Hash, error) {
+	switch h {
+	case Adler32:
+		return adler32.New(), nil
+	case CRC32:
+		return crc32.NewIEEE(), nil
+	case SHA1:
+		return sha1.New(), nil
+	case SHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", h)
+	}
+}
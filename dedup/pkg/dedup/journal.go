@@ -0,0 +1,113 @@
+package dedup
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// JournalEntry records a duplicate that was disposed of during a run, along
+// with the checksum its contents had at the time. A quarantine manifest (see
+// [ManifestEntry]) is also a valid journal, albeit one without checksums.
+type JournalEntry struct {
+	// Time is the time at which the duplicate was disposed of.
+	Time time.Time `json:"time"`
+
+	// Action is the strategy that was applied: `link`, `delete`, or
+	// `quarantine`. It is empty for entries read from a quarantine manifest.
+	Action string `json:"action,omitempty"`
+
+	// Path is the path of the duplicate file.
+	Path string `json:"path"`
+
+	// Canonical is the path of the file that `Path` duplicated.
+	Canonical string `json:"canonical"`
+
+	// Quarantined is the path a quarantined duplicate was moved to.
+	Quarantined string `json:"quarantined,omitempty"`
+
+	// Size is the size of the file.
+	Size int64 `json:"size"`
+
+	// Hash is the algorithm used to compute `Checksum`.
+	Hash Hash `json:"hash,omitempty"`
+
+	// Checksum is the checksum of the canonical file's contents.
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// ReadJournal reads the journal (or quarantine manifest) at `path`.
+func ReadJournal(fsys FS, path string) (entries []JournalEntry, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("reading journal `%s`: %w", path, err)
+		}
+	}()
+
+	var file io.ReadSeekCloser
+	if file, err = fsys.Open(path); err != nil {
+		return
+	}
+	defer func() { err = errors.Join(err, file.Close()) }()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) < 1 {
+			continue
+		}
+		var entry JournalEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			err = fmt.Errorf("line %d: %w", line, err)
+			return
+		}
+		entries = append(entries, entry)
+	}
+	err = scanner.Err()
+	return
+}
+
+// journal records `entry` in `opts.Journal`, if any. Paths on the host
+// filesystem are made absolute (like those in a quarantine manifest) so that
+// the journal can be verified from any working directory. Paths on other
+// filesystems are recorded as the filesystem reported them, since the host's
+// working directory has no bearing on them.
+func journal(opts *Options, entry *JournalEntry) error {
+	if opts.Journal == nil || opts.DryRun {
+		return nil
+	}
+
+	var err error
+	if entry.Path, err = absPath(opts.FS, entry.Path); err != nil {
+		return fmt.Errorf("writing journal entry for `%s`: %w", entry.Path, err)
+	}
+	if entry.Canonical, err = absPath(opts.FS, entry.Canonical); err != nil {
+		return fmt.Errorf("writing journal entry for `%s`: %w", entry.Path, err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("writing journal entry for `%s`: %w", entry.Path, err)
+	}
+	if _, err := opts.Journal.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing journal entry for `%s`: %w", entry.Path, err)
+	}
+	return nil
+}
+
+// strategyAction returns the journal action name for `strategy`.
+func strategyAction(strategy Strategy) string {
+	switch strategy.(type) {
+	case Link, *Link:
+		return "link"
+	case Delete, *Delete:
+		return "delete"
+	case *Quarantine:
+		return "quarantine"
+	default:
+		return fmt.Sprintf("%T", strategy)
+	}
+}
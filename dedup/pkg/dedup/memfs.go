@@ -0,0 +1,375 @@
+package dedup
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MemFS is an in-memory [FS]. Paths are cleaned with [filepath.Clean] before
+// use, so callers should consistently use either absolute or relative paths.
+// A MemFS is safe for concurrent use.
+type MemFS struct {
+	mutex   sync.Mutex
+	nodes   map[string]*memInode
+	nextIno uint64
+}
+
+// memInode is a file or directory in a [MemFS]. Hard links share a single
+// memInode.
+type memInode struct {
+	ino     uint64
+	dir     bool
+	nlink   uint64
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemFS creates an empty [MemFS] containing only the root and current
+// directories.
+func NewMemFS() *MemFS {
+	fsys := MemFS{nodes: map[string]*memInode{}}
+	fsys.nodes["/"] = fsys.newInode(true)
+	fsys.nodes["."] = fsys.newInode(true)
+	return &fsys
+}
+
+// WriteFile creates (or truncates) the file at `path` with `data`, creating
+// any missing parent directories.
+func (fsys *MemFS) WriteFile(path string, data []byte) error {
+	if err := fsys.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	w, err := fsys.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// ReadFile returns the contents of the file at `path`.
+func (fsys *MemFS) ReadFile(path string) ([]byte, error) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	node, err := fsys.file("open", path)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Clone(node.data), nil
+}
+
+// ReadDir implements [FS]. Since a MemFS is a flat map of paths, it scans
+// every file and directory in the filesystem to find the entries of `path`,
+// which is fine for tests but slow for large trees.
+func (fsys *MemFS) ReadDir(path string) ([]fs.DirEntry, error) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	path = filepath.Clean(path)
+	node, exists := fsys.nodes[path]
+	if !exists {
+		return nil, pathError("readdir", path, fs.ErrNotExist)
+	}
+	if !node.dir {
+		return nil, pathError("readdir", path, errNotDir)
+	}
+
+	var entries []fs.DirEntry
+	for child, node := range fsys.nodes {
+		if child != path && filepath.Dir(child) == path {
+			entries = append(entries, fs.FileInfoToDirEntry(memFileInfo{
+				name:  filepath.Base(child),
+				inode: node,
+			}))
+		}
+	}
+	slices.SortFunc(entries, func(l, r fs.DirEntry) int {
+		return strings.Compare(l.Name(), r.Name())
+	})
+	return entries, nil
+}
+
+// Lstat implements [FS].
+func (fsys *MemFS) Lstat(path string) (Stat, error) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	path = filepath.Clean(path)
+	node, exists := fsys.nodes[path]
+	if !exists {
+		return Stat{}, pathError("lstat", path, fs.ErrNotExist)
+	}
+	info := memFileInfo{name: filepath.Base(path), inode: node}
+	return Stat{
+		Mode:    info.Mode(),
+		Size:    info.Size(),
+		Ino:     node.ino,
+		Nlink:   node.nlink,
+		ModTime: node.modTime,
+	}, nil
+}
+
+// Open implements [FS]. The returned reader observes the file's contents at
+// the time it was opened.
+func (fsys *MemFS) Open(path string) (io.ReadSeekCloser, error) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	node, err := fsys.file("open", path)
+	if err != nil {
+		return nil, err
+	}
+	return memReader{bytes.NewReader(bytes.Clone(node.data))}, nil
+}
+
+// OpenFile implements [FS]. It supports the `os.O_CREATE`, `os.O_EXCL`,
+// `os.O_TRUNC`, and `os.O_APPEND` flags.
+func (fsys *MemFS) OpenFile(
+	path string,
+	flag int,
+	perm fs.FileMode,
+) (io.WriteCloser, error) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	path = filepath.Clean(path)
+	node, exists := fsys.nodes[path]
+	switch {
+	case exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, pathError("open", path, fs.ErrExist)
+	case exists && node.dir:
+		return nil, pathError("open", path, errIsDir)
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, pathError("open", path, fs.ErrNotExist)
+	case !exists:
+		if err := fsys.parentDir("open", path); err != nil {
+			return nil, err
+		}
+		node = fsys.newInode(false)
+		fsys.nodes[path] = node
+	}
+
+	if flag&os.O_TRUNC != 0 {
+		node.data = nil
+		node.modTime = time.Now()
+	}
+	return &memWriter{
+		fsys:   fsys,
+		inode:  node,
+		append: flag&os.O_APPEND != 0,
+	}, nil
+}
+
+// MkdirAll implements [FS].
+func (fsys *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	path = filepath.Clean(path)
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if node, exists := fsys.nodes[dir]; exists {
+			if !node.dir {
+				return pathError("mkdir", dir, errNotDir)
+			}
+			break
+		}
+		fsys.nodes[dir] = fsys.newInode(true)
+	}
+	return nil
+}
+
+// Rename implements [FS].
+func (fsys *MemFS) Rename(oldpath, newpath string) error {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	node, exists := fsys.nodes[oldpath]
+	if !exists {
+		return pathError("rename", oldpath, fs.ErrNotExist)
+	}
+	if err := fsys.parentDir("rename", newpath); err != nil {
+		return err
+	}
+	if existing, exists := fsys.nodes[newpath]; exists {
+		if existing == node {
+			// like rename(2), renaming a file to itself (or to another of
+			// its links) does nothing
+			return nil
+		}
+		if existing.dir || node.dir {
+			return pathError("rename", newpath, fs.ErrExist)
+		}
+		existing.nlink--
+	}
+
+	if node.dir {
+		prefix := oldpath + string(filepath.Separator)
+		moved := map[string]*memInode{}
+		for path, child := range fsys.nodes {
+			if strings.HasPrefix(path, prefix) {
+				delete(fsys.nodes, path)
+				moved[filepath.Join(newpath, path[len(prefix):])] = child
+			}
+		}
+		maps.Copy(fsys.nodes, moved)
+	}
+	delete(fsys.nodes, oldpath)
+	fsys.nodes[newpath] = node
+	return nil
+}
+
+// Link implements [FS].
+func (fsys *MemFS) Link(oldpath, newpath string) error {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	node, err := fsys.file("link", oldpath)
+	if err != nil {
+		return err
+	}
+	newpath = filepath.Clean(newpath)
+	if _, exists := fsys.nodes[newpath]; exists {
+		return pathError("link", newpath, fs.ErrExist)
+	}
+	if err := fsys.parentDir("link", newpath); err != nil {
+		return err
+	}
+	node.nlink++
+	fsys.nodes[newpath] = node
+	return nil
+}
+
+// Remove implements [FS].
+func (fsys *MemFS) Remove(path string) error {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	path = filepath.Clean(path)
+	node, exists := fsys.nodes[path]
+	if !exists {
+		return pathError("remove", path, fs.ErrNotExist)
+	}
+	if node.dir {
+		for child := range fsys.nodes {
+			if child != path && filepath.Dir(child) == path {
+				return pathError("remove", path, errNotEmpty)
+			}
+		}
+	}
+	node.nlink--
+	delete(fsys.nodes, path)
+	return nil
+}
+
+func (fsys *MemFS) newInode(dir bool) *memInode {
+	fsys.nextIno++
+	return &memInode{
+		ino:     fsys.nextIno,
+		dir:     dir,
+		nlink:   1,
+		modTime: time.Now(),
+	}
+}
+
+// file returns the regular file at `path`. The caller must hold the mutex.
+func (fsys *MemFS) file(op, path string) (*memInode, error) {
+	path = filepath.Clean(path)
+	node, exists := fsys.nodes[path]
+	if !exists {
+		return nil, pathError(op, path, fs.ErrNotExist)
+	}
+	if node.dir {
+		return nil, pathError(op, path, errIsDir)
+	}
+	return node, nil
+}
+
+// parentDir returns an error if the parent of `path` is not a directory. The
+// caller must hold the mutex.
+func (fsys *MemFS) parentDir(op, path string) error {
+	parent, exists := fsys.nodes[filepath.Dir(path)]
+	if !exists {
+		return pathError(op, path, fs.ErrNotExist)
+	}
+	if !parent.dir {
+		return pathError(op, path, errNotDir)
+	}
+	return nil
+}
+
+func pathError(op, path string, err error) error {
+	return &fs.PathError{Op: op, Path: path, Err: err}
+}
+
+var (
+	errIsDir    = syscall.EISDIR
+	errNotDir   = syscall.ENOTDIR
+	errNotEmpty = syscall.ENOTEMPTY
+)
+
+type memReader struct{ *bytes.Reader }
+
+func (memReader) Close() error { return nil }
+
+type memWriter struct {
+	fsys   *MemFS
+	inode  *memInode
+	offset int
+	append bool
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	w.fsys.mutex.Lock()
+	defer w.fsys.mutex.Unlock()
+
+	if w.append {
+		w.offset = len(w.inode.data)
+	}
+	if end := w.offset + len(p); end > len(w.inode.data) {
+		w.inode.data = append(
+			w.inode.data,
+			make([]byte, end-len(w.inode.data))...,
+		)
+	}
+	copy(w.inode.data[w.offset:], p)
+	w.offset += len(p)
+	w.inode.modTime = time.Now()
+	return len(p), nil
+}
+
+func (w *memWriter) Close() error { return nil }
+
+type memFileInfo struct {
+	name  string
+	inode *memInode
+}
+
+func (info memFileInfo) Name() string { return info.name }
+
+func (info memFileInfo) Size() int64 { return int64(len(info.inode.data)) }
+
+func (info memFileInfo) Mode() fs.FileMode {
+	if info.inode.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (info memFileInfo) ModTime() time.Time { return info.inode.modTime }
+
+func (info memFileInfo) IsDir() bool { return info.inode.dir }
+
+func (info memFileInfo) Sys() any { return nil }
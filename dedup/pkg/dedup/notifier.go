@@ -3,23 +3,28 @@ package dedup
 import (
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
 )
 
+// Notifier reports the progress of a deduplication run. It is safe for
+// concurrent use, and the zero value discards all notifications.
 type Notifier struct {
-	w io.Writer
+	w     io.Writer
+	mutex *sync.Mutex
 }
 
 func NewNotifier(w io.Writer) (n Notifier) {
 	n.w = w
+	n.mutex = new(sync.Mutex)
 	return
 }
 
 func (n Notifier) ScanningDirectory(directory string) {
-	fmt.Fprintf(
-		n.w,
+	n.printf(
+		nil,
 		"%s scanning directory: %s\n",
 		nowStr(),
 		directory,
@@ -27,8 +32,8 @@ func (n Notifier) ScanningDirectory(directory string) {
 }
 
 func (n Notifier) CollectedUniqueInoFiles(count int) {
-	green.Fprintf(
-		n.w,
+	n.printf(
+		green,
 		"✅ %s collected %d files with distinct inos\n",
 		nowStr(),
 		count,
@@ -36,8 +41,8 @@ func (n Notifier) CollectedUniqueInoFiles(count int) {
 }
 
 func (n Notifier) IgnoringUniqueSizes(ignored int) {
-	green.Fprintf(
-		n.w,
+	n.printf(
+		green,
 		"✅ %s ignoring %d files with unique sizes\n",
 		nowStr(),
 		ignored,
@@ -45,8 +50,8 @@ func (n Notifier) IgnoringUniqueSizes(ignored int) {
 }
 
 func (n Notifier) ProcessingSizeGroup(groups [][]File, index int) {
-	bold.Fprintf(
-		n.w,
+	n.printf(
+		bold,
 		"\n%s processing size group %d/%d (%d files @ %s each)\n",
 		nowStr(),
 		index+1,
@@ -60,8 +65,8 @@ func (n Notifier) IgnoringUniqueChecksums(size int64, ignored, remaining int) {
 	if ignored < 1 {
 		return
 	}
-	green.Fprintf(
-		n.w,
+	n.printf(
+		green,
 		"%s  ignoring %d files with unique checksums (%d groups remaining)\n",
 		nowStr(),
 		ignored,
@@ -70,8 +75,8 @@ func (n Notifier) IgnoringUniqueChecksums(size int64, ignored, remaining int) {
 }
 
 func (n Notifier) ProcessingGroup(group *Group) {
-	bold.Fprintf(
-		n.w,
+	n.printf(
+		bold,
 		"%s  processing group (%d files @ %s each)\n",
 		nowStr(),
 		len(group.Paths),
@@ -80,12 +85,12 @@ func (n Notifier) ProcessingGroup(group *Group) {
 }
 
 func (n Notifier) ChecksummingFile(path string) {
-	fmt.Fprintf(n.w, "%s    checksumming file [%s]\n", nowStr(), path)
+	n.printf(nil, "%s    checksumming file [%s]\n", nowStr(), path)
 }
 
 func (n Notifier) RemovingDuplicateFile(size int64, path string) {
-	green.Fprintf(
-		n.w,
+	n.printf(
+		green,
 		"%s    removing duplicate file (size: %s) [%s]\n",
 		nowStr(),
 		human(size),
@@ -93,6 +98,145 @@ func (n Notifier) RemovingDuplicateFile(size int64, path string) {
 	)
 }
 
+func (n Notifier) IgnoringChecksumCollision(path, canonical string) {
+	n.printf(
+		nil,
+		"%s    ignoring file with matching checksum but different "+
+			"contents [%s] (canonical: [%s])\n",
+		nowStr(),
+		path,
+		canonical,
+	)
+}
+
+func (n Notifier) DeletingDuplicateFile(size int64, path string) {
+	n.printf(
+		green,
+		"%s    deleting duplicate file (size: %s) [%s]\n",
+		nowStr(),
+		human(size),
+		path,
+	)
+}
+
+func (n Notifier) QuarantiningDuplicateFile(size int64, path, dest string) {
+	n.printf(
+		green,
+		"%s    quarantining duplicate file (size: %s) [%s] -> [%s]\n",
+		nowStr(),
+		human(size),
+		path,
+		dest,
+	)
+}
+
+func (n Notifier) RestoringFile(path string) {
+	n.printf(nil, "%s restoring quarantined file [%s]\n", nowStr(), path)
+}
+
+func (n Notifier) PurgingQuarantinedFile(path string) {
+	n.printf(nil, "%s purging quarantined file [%s]\n", nowStr(), path)
+}
+
+func (n Notifier) ChunkingFile(path string) {
+	n.printf(nil, "%s chunking file [%s]\n", nowStr(), path)
+}
+
+func (n Notifier) FoundSimilarClusters(count int) {
+	n.printf(
+		green,
+		"✅ %s found %d clusters of similar files\n",
+		nowStr(),
+		count,
+	)
+}
+
+func (n Notifier) SimilarCluster(cluster *SimilarCluster) {
+	n.printf(
+		bold,
+		"\n%s similar cluster (%d files, %s total, ~%s saved by block "+
+			"dedup)\n",
+		nowStr(),
+		len(cluster.Paths),
+		human(cluster.TotalBytes),
+		human(cluster.Savings()),
+	)
+	for i := range cluster.Pairs {
+		pair := &cluster.Pairs[i]
+		n.printf(
+			nil,
+			"  %3.0f%% (%s shared) [%s] [%s]\n",
+			pair.Similarity*100,
+			human(pair.SharedBytes),
+			pair.A,
+			pair.B,
+		)
+	}
+}
+
+func (n Notifier) CollectedLinkGroups(count int) {
+	n.printf(
+		green,
+		"✅ %s collected %d groups of hard-linked files\n",
+		nowStr(),
+		count,
+	)
+}
+
+func (n Notifier) LinkGroup(group *LinkGroup) {
+	n.printf(
+		bold,
+		"\n%s link group (%d/%d links @ %s each, ino %d)\n",
+		nowStr(),
+		len(group.Paths),
+		group.Nlink,
+		human(group.Size),
+		group.Ino,
+	)
+	for _, path := range group.Paths {
+		n.printf(nil, "  %s\n", path)
+	}
+}
+
+func (n Notifier) VerifyIssue(issue *Issue) {
+	n.printf(
+		red,
+		"⛔️ %s %s: %s [%s]\n",
+		nowStr(),
+		issue.Kind,
+		issue.Message,
+		issue.Path,
+	)
+}
+
+func (n Notifier) VerifiedJournal(entries, issues int) {
+	c := green
+	if issues > 0 {
+		c = red
+	}
+	n.printf(
+		c,
+		"%s verified %d journal entries (%d issues)\n",
+		nowStr(),
+		entries,
+		issues,
+	)
+}
+
+func (n Notifier) printf(c *color.Color, format string, args ...any) {
+	if n.w == nil {
+		return
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if c == nil {
+		fmt.Fprintf(n.w, format, args...)
+		return
+	}
+	c.Fprintf(n.w, format, args...)
+}
+
 func human(n int64) string {
 	// Metric suffixes
 	const (
@@ -136,4 +280,5 @@ func nowStr() string {
 var (
 	bold  = color.New(color.Bold)
 	green = color.New(color.FgGreen)
+	red   = color.New(color.FgRed)
 )
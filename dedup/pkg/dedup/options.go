@@ -0,0 +1,123 @@
+package dedup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// Options configures a deduplication run. The zero value of each field other
+// than `Roots` selects a sensible default.
+type Options struct {
+	// Roots are the directories to deduplicate. Duplicates are detected
+	// across all roots.
+	Roots []string
+
+	// Include, if non-empty, restricts deduplication to files whose name or
+	// path matches at least one of these [filepath.Match] patterns.
+	Include []string
+
+	// Exclude skips files and directories whose name or path matches any of
+	// these [filepath.Match] patterns. Excluded directories are not
+	// traversed.
+	Exclude []string
+
+	// MinSize skips files smaller than this many bytes.
+	MinSize int64
+
+	// Filter, if set, is consulted for each regular file which passes the
+	// other filters; files for which it returns false are skipped.
+	Filter func(path string, stat *Stat) bool
+
+	// Hash is the algorithm used to find files which are identical.
+	// Defaults to [Adler32]. Unless it is [SHA256], files whose checksums
+	// match are also compared byte-for-byte before they are disposed of.
+	Hash Hash
+
+	// Strategy decides what happens to duplicates. Defaults to [Link].
+	Strategy Strategy
+
+	// Concurrency is the maximum number of files to checksum at once.
+	// Defaults to 1.
+	Concurrency int
+
+	// DryRun reports what would be done without modifying any files.
+	DryRun bool
+
+	// Journal, if set, receives a [JournalEntry] (as a line of JSON) for
+	// each duplicate that is disposed of, which [Verify] can later audit.
+	Journal io.Writer
+
+	// FS is the filesystem to operate on. Defaults to [OS].
+	FS FS
+
+	// Notifier receives progress notifications. The zero value discards
+	// them.
+	Notifier Notifier
+}
+
+// withDefaults validates `opts` and returns a copy with defaults applied.
+func (opts *Options) withDefaults() (*Options, error) {
+	out := *opts
+	if out.Hash == "" {
+		out.Hash = Adler32
+	}
+	if _, err := out.Hash.New(); err != nil {
+		return nil, fmt.Errorf("validating options: %w", err)
+	}
+	if out.Strategy == nil {
+		out.Strategy = Link{}
+	}
+	if out.Concurrency < 1 {
+		out.Concurrency = 1
+	}
+	if out.FS == nil {
+		out.FS = OS{}
+	}
+
+	for _, pattern := range append(out.Include, out.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf(
+				"validating options: pattern `%s`: %w",
+				pattern,
+				err,
+			)
+		}
+	}
+	return &out, nil
+}
+
+// skipDir returns true if the directory at `path` should not be traversed.
+func (opts *Options) skipDir(path string) bool {
+	return matchAny(opts.Exclude, path)
+}
+
+// skipFile returns true if the regular file at `path` should not be
+// deduplicated.
+func (opts *Options) skipFile(path string, stat *Stat) bool {
+	if stat.Size < opts.MinSize || matchAny(opts.Exclude, path) {
+		return true
+	}
+	if len(opts.Include) > 0 && !matchAny(opts.Include, path) {
+		return true
+	}
+	return opts.Filter != nil && !opts.Filter(path, stat)
+}
+
+// matchAny returns true if `path` or its base name matches any of
+// `patterns`. Patterns are validated by [Options.withDefaults].
+func matchAny(patterns []string, path string) bool {
+	base := filepath.Base(path)
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, base); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+	}
+	return false
+}
+
+var errNoRoots = errors.New("validating options: no roots")
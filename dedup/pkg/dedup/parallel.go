@@ -0,0 +1,42 @@
+package dedup
+
+import "sync"
+
+// forEach calls `fn` for each index in `[0, count)` using at most
+// `concurrency` goroutines, returning the error from the lowest index which
+// failed.
+func forEach(concurrency, count int, fn func(i int) error) error {
+	if concurrency < 2 || count < 2 {
+		for i := 0; i < count; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, count)
+	indices := make(chan int)
+	for range min(concurrency, count) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,468 @@
+package dedup
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Quarantine is a [Strategy] which moves duplicate files into a quarantine
+// directory rather than linking them. Duplicates are stored beneath the
+// quarantine directory's `files` subdirectory at the same path they occupied
+// relative to their deduplicated root, and each move is recorded in the
+// quarantine's manifest so the files can later be restored (see [Restore]) or
+// permanently deleted once they have aged out (see [Purge]).
+type Quarantine struct {
+	// Directory is the quarantine directory.
+	Directory string
+
+	// Roots are the directories being deduplicated. Quarantined paths are
+	// relative to the root which contains them.
+	Roots []string
+}
+
+// NewQuarantine creates a quarantine [Strategy] for duplicates found beneath
+// `roots` on `fsys` ([OS] if nil). The quarantine directory must not be
+// inside any of the roots, otherwise quarantined files would be rescanned by
+// subsequent runs.
+func NewQuarantine(
+	fsys FS,
+	directory string,
+	roots ...string,
+) (*Quarantine, error) {
+	if fsys == nil {
+		fsys = OS{}
+	}
+	absDirectory, err := absPath(fsys, directory)
+	if err != nil {
+		return nil, fmt.Errorf("creating quarantine `%s`: %w", directory, err)
+	}
+
+	q := Quarantine{Directory: absDirectory}
+	for _, root := range roots {
+		absRoot, err := absPath(fsys, root)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"creating quarantine `%s`: %w",
+				directory,
+				err,
+			)
+		}
+		if isWithin(absRoot, absDirectory) {
+			return nil, fmt.Errorf(
+				"creating quarantine `%s`: quarantine directory must not be "+
+					"inside the deduplicated directory `%s`",
+				directory,
+				root,
+			)
+		}
+		q.Roots = append(q.Roots, absRoot)
+	}
+	return &q, nil
+}
+
+// ManifestEntry records a single file moved into quarantine.
+type ManifestEntry struct {
+	// Time is the time at which the file was quarantined.
+	Time time.Time `json:"time"`
+
+	// Path is the original path of the duplicate file.
+	Path string `json:"path"`
+
+	// Canonical is the path of the file that `Path` duplicated.
+	Canonical string `json:"canonical"`
+
+	// Quarantined is the path the file was moved to.
+	Quarantined string `json:"quarantined"`
+
+	// Size is the size of the file.
+	Size int64 `json:"size"`
+}
+
+// Dedup implements [Strategy].
+func (q *Quarantine) Dedup(
+	opts *Options,
+	duplicate string,
+	canonical string,
+	size int64,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf(
+				"quarantining duplicate file `%s` of file `%s`: %w",
+				duplicate,
+				canonical,
+				err,
+			)
+		}
+	}()
+
+	absDuplicate, err := absPath(opts.FS, duplicate)
+	if err != nil {
+		return err
+	}
+	absCanonical, err := absPath(opts.FS, canonical)
+	if err != nil {
+		return err
+	}
+	rel, err := q.relative(absDuplicate)
+	if err != nil {
+		return err
+	}
+
+	destination, err := availablePath(
+		opts.FS,
+		filepath.Join(q.Directory, quarantineFiles, rel),
+	)
+	if err != nil {
+		return err
+	}
+
+	opts.Notifier.QuarantiningDuplicateFile(size, duplicate, destination)
+	if opts.DryRun {
+		return nil
+	}
+
+	if err := opts.FS.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return fmt.Errorf("creating quarantine directory: %w", err)
+	}
+	if err := move(opts.FS, duplicate, destination); err != nil {
+		return err
+	}
+
+	if err := appendManifest(opts.FS, q.Directory, &ManifestEntry{
+		Time:        time.Now(),
+		Path:        absDuplicate,
+		Canonical:   absCanonical,
+		Quarantined: destination,
+		Size:        size,
+	}); err != nil {
+		// a quarantined file without a manifest entry could be neither
+		// restored nor purged, so put it back
+		if moveErr := move(opts.FS, destination, duplicate); moveErr != nil {
+			return errors.Join(
+				err,
+				fmt.Errorf("moving file back out of quarantine: %w", moveErr),
+			)
+		}
+		return err
+	}
+	return nil
+}
+
+// relative returns `path` relative to the root which contains it.
+func (q *Quarantine) relative(path string) (string, error) {
+	for _, root := range q.Roots {
+		if rel, err := filepath.Rel(root, path); err == nil &&
+			filepath.IsLocal(rel) {
+			return rel, nil
+		}
+	}
+	return "", fmt.Errorf("file is not inside any quarantine root")
+}
+
+// Restore moves every file in the quarantine `directory` back to its original
+// path. Entries which cannot be restored (e.g., because a file now exists at
+// the original path) are reported in the returned error and kept in the
+// manifest.
+func Restore(opts *Options, directory string) error {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return err
+	}
+
+	entries, err := ReadManifest(opts.FS, directory)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	var remaining []ManifestEntry
+	for i := range entries {
+		opts.Notifier.RestoringFile(entries[i].Path)
+		if err := restoreEntry(opts, &entries[i]); err != nil {
+			errs = append(errs, err)
+			remaining = append(remaining, entries[i])
+		}
+	}
+
+	return errors.Join(
+		append(errs, writeManifest(opts, directory, remaining))...,
+	)
+}
+
+func restoreEntry(opts *Options, entry *ManifestEntry) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf(
+				"restoring quarantined file `%s` to `%s`: %w",
+				entry.Quarantined,
+				entry.Path,
+				err,
+			)
+		}
+	}()
+
+	if opts.DryRun {
+		return nil
+	}
+
+	if _, err := opts.FS.Lstat(entry.Path); err == nil {
+		return fmt.Errorf("destination file exists")
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err := opts.FS.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+		return fmt.Errorf("creating parent directory: %w", err)
+	}
+	return move(opts.FS, entry.Quarantined, entry.Path)
+}
+
+// Purge permanently deletes files which have been in the quarantine
+// `directory` for longer than `retention`.
+func Purge(opts *Options, directory string, retention time.Duration) error {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return err
+	}
+
+	entries, err := ReadManifest(opts.FS, directory)
+	if err != nil {
+		return err
+	}
+
+	files, err := absPath(opts.FS, filepath.Join(directory, quarantineFiles))
+	if err != nil {
+		return fmt.Errorf("purging quarantine `%s`: %w", directory, err)
+	}
+
+	var errs []error
+	var remaining []ManifestEntry
+	cutoff := time.Now().Add(-retention)
+	for i := range entries {
+		if entries[i].Time.After(cutoff) {
+			remaining = append(remaining, entries[i])
+			continue
+		}
+
+		opts.Notifier.PurgingQuarantinedFile(entries[i].Quarantined)
+		if opts.DryRun {
+			remaining = append(remaining, entries[i])
+			continue
+		}
+		if err := opts.FS.Remove(entries[i].Quarantined); err != nil &&
+			!errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf(
+				"purging quarantined file `%s`: %w",
+				entries[i].Quarantined,
+				err,
+			))
+			remaining = append(remaining, entries[i])
+			continue
+		}
+		removeEmptyParents(opts.FS, files, entries[i].Quarantined)
+	}
+
+	return errors.Join(
+		append(errs, writeManifest(opts, directory, remaining))...,
+	)
+}
+
+// ReadManifest reads the manifest entries for the quarantine `directory`. A
+// quarantine without a manifest has no entries.
+func ReadManifest(
+	fsys FS,
+	directory string,
+) (entries []ManifestEntry, err error) {
+	path := filepath.Join(directory, quarantineManifest)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("reading manifest `%s`: %w", path, err)
+		}
+	}()
+
+	file, err := fsys.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	defer func() { err = errors.Join(err, file.Close()) }()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) < 1 {
+			continue
+		}
+		var entry ManifestEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			err = fmt.Errorf("line %d: %w", line, err)
+			return
+		}
+		entries = append(entries, entry)
+	}
+	err = scanner.Err()
+	return
+}
+
+func appendManifest(
+	fsys FS,
+	directory string,
+	entry *ManifestEntry,
+) (err error) {
+	path := filepath.Join(directory, quarantineManifest)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("appending to manifest `%s`: %w", path, err)
+		}
+	}()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	var file io.WriteCloser
+	if file, err = fsys.OpenFile(
+		path,
+		os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		0644,
+	); err != nil {
+		return
+	}
+	defer func() { err = errors.Join(err, file.Close()) }()
+
+	_, err = file.Write(append(data, '\n'))
+	return
+}
+
+// writeManifest atomically replaces the manifest for the quarantine
+// `directory` with `entries`.
+func writeManifest(
+	opts *Options,
+	directory string,
+	entries []ManifestEntry,
+) (err error) {
+	path := filepath.Join(directory, quarantineManifest)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("writing manifest `%s`: %w", path, err)
+		}
+	}()
+
+	if opts.DryRun {
+		return nil
+	}
+
+	var file io.WriteCloser
+	if file, err = opts.FS.OpenFile(
+		path+".tmp",
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		0644,
+	); err != nil {
+		return
+	}
+
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	for i := range entries {
+		if err = encoder.Encode(&entries[i]); err != nil {
+			return errors.Join(err, file.Close())
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return errors.Join(err, file.Close())
+	}
+	if err = file.Close(); err != nil {
+		return
+	}
+	return opts.FS.Rename(path+".tmp", path)
+}
+
+// availablePath returns `path` if nothing exists there, otherwise the first
+// of `path.1`, `path.2`, etc. which does not exist.
+func availablePath(fsys FS, path string) (string, error) {
+	candidate := path
+	for i := 1; ; i++ {
+		if _, err := fsys.Lstat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+		candidate = path + "." + strconv.Itoa(i)
+	}
+}
+
+// move renames `src` to `dst`, falling back to copying and removing the
+// source if the two paths are on different filesystems.
+func move(fsys FS, src, dst string) error {
+	err := fsys.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := copyFile(fsys, src, dst); err != nil {
+		return fmt.Errorf("moving `%s` to `%s`: %w", src, dst, err)
+	}
+	return fsys.Remove(src)
+}
+
+func copyFile(fsys FS, src, dst string) (err error) {
+	var stat Stat
+	if stat, err = fsys.Lstat(src); err != nil {
+		return
+	}
+
+	var in io.ReadSeekCloser
+	if in, err = fsys.Open(src); err != nil {
+		return
+	}
+	defer func() { err = errors.Join(err, in.Close()) }()
+
+	var out io.WriteCloser
+	if out, err = fsys.OpenFile(
+		dst,
+		os.O_WRONLY|os.O_CREATE|os.O_EXCL,
+		stat.Mode.Perm(),
+	); err != nil {
+		return
+	}
+	defer func() { err = errors.Join(err, out.Close()) }()
+
+	_, err = io.Copy(out, in)
+	return
+}
+
+// removeEmptyParents removes the empty directories between `path` and
+// `root` (exclusive).
+func removeEmptyParents(fsys FS, root, path string) {
+	dir := filepath.Dir(path)
+	for dir != root && isWithin(root, dir) {
+		if fsys.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// isWithin returns true if `path` is `root` or is beneath it.
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+const (
+	quarantineFiles    = "files"
+	quarantineManifest = "manifest.jsonl"
+)
@@ -0,0 +1,279 @@
+package dedup
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// SimilarityOptions configures [FindSimilar].
+type SimilarityOptions struct {
+	// Threshold is the minimum fraction of the larger file's bytes which two
+	// files must share to be reported as similar. Defaults to 0.5.
+	Threshold float64
+
+	// AverageChunkSize is the average size of the content-defined chunks
+	// that files are split into. Smaller chunks detect smaller shared
+	// regions at the cost of more memory. Defaults to 8KiB.
+	AverageChunkSize int
+
+	// MaxFanout is the number of files a chunk may appear in before it is
+	// ignored for the purpose of pairing files. This keeps ubiquitous chunks
+	// (e.g., runs of zeroes) from making pairing quadratic in the number of
+	// files. Defaults to 64.
+	MaxFanout int
+}
+
+// SimilarPair is a pair of files which share a significant fraction of their
+// chunks.
+type SimilarPair struct {
+	// A and B are the paths of the files.
+	A, B string
+
+	// SharedBytes is the number of bytes in chunks common to both files.
+	SharedBytes int64
+
+	// Similarity is `SharedBytes` as a fraction of the larger file's size.
+	Similarity float64
+}
+
+// SimilarCluster is a set of files connected by [SimilarPair]s.
+type SimilarCluster struct {
+	// Paths are the paths of the files in the cluster.
+	Paths []string
+
+	// Pairs are the similar pairs which connect the cluster's files.
+	Pairs []SimilarPair
+
+	// TotalBytes is the combined size of the files in the cluster.
+	TotalBytes int64
+
+	// UniqueBytes is the combined size of the distinct chunks in the
+	// cluster, i.e., the space the files would occupy under block-level
+	// deduplication.
+	UniqueBytes int64
+}
+
+// Savings is the estimated number of bytes block-level deduplication would
+// save for the cluster.
+func (c *SimilarCluster) Savings() int64 { return c.TotalBytes - c.UniqueBytes }
+
+// FindSimilar splits each file beneath `opts.Roots` into content-defined
+// chunks and reports clusters of files which share a high fraction of their
+// chunks, ordered by estimated savings. Unlike [Dedup], it only reports and
+// never modifies files, so `opts.Strategy` and `opts.DryRun` are ignored.
+func FindSimilar(
+	opts *Options,
+	similarity SimilarityOptions,
+) ([]SimilarCluster, error) {
+	if len(opts.Roots) < 1 {
+		return nil, errNoRoots
+	}
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	if similarity.Threshold <= 0 {
+		similarity.Threshold = 0.5
+	}
+	if similarity.AverageChunkSize < 1 {
+		similarity.AverageChunkSize = 8 * 1024
+	}
+	if similarity.MaxFanout < 1 {
+		similarity.MaxFanout = 64
+	}
+
+	notify := opts.Notifier
+	for _, root := range opts.Roots {
+		notify.ScanningDirectory(root)
+	}
+	files, err := collectUniqueInoFiles(opts)
+	if err != nil {
+		return nil, err
+	}
+	files = slices.DeleteFunc(files, func(f File) bool { return f.Size < 1 })
+	notify.CollectedUniqueInoFiles(len(files))
+
+	chunked := make([]chunkedFile, len(files))
+	if err := forEach(opts.Concurrency, len(files), func(i int) error {
+		notify.ChunkingFile(files[i].Path)
+		chunks, err := chunkFile(
+			opts.FS,
+			files[i].Path,
+			similarity.AverageChunkSize,
+		)
+		chunked[i] = chunkedFile{File: files[i], chunks: chunks}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	pairs := similarPairs(chunked, &similarity)
+	clusters := similarClusters(chunked, pairs)
+	notify.FoundSimilarClusters(len(clusters))
+	for i := range clusters {
+		notify.SimilarCluster(&clusters[i])
+	}
+	return clusters, nil
+}
+
+// chunkedFile is a file along with its chunks.
+type chunkedFile struct {
+	File
+
+	// chunks maps each chunk's hash to the chunk's size and the number of
+	// times it occurs in the file.
+	chunks map[uint64]chunkCount
+}
+
+type chunkCount struct {
+	size  int64
+	count int64
+}
+
+// filePair identifies a pair of files by their indices, with `a < b`.
+type filePair struct{ a, b int }
+
+func similarPairs(
+	files []chunkedFile,
+	similarity *SimilarityOptions,
+) (pairs []SimilarPair) {
+	index := map[uint64][]int{}
+	for i := range files {
+		for hash := range files[i].chunks {
+			index[hash] = append(index[hash], i)
+		}
+	}
+
+	shared := map[filePair]int64{}
+	for hash, indices := range index {
+		if len(indices) < 2 || len(indices) > similarity.MaxFanout {
+			continue
+		}
+		for x, a := range indices {
+			for _, b := range indices[x+1:] {
+				ca, cb := files[a].chunks[hash], files[b].chunks[hash]
+				shared[filePair{a, b}] += ca.size * min(ca.count, cb.count)
+			}
+		}
+	}
+
+	for pair, bytes := range shared {
+		a, b := &files[pair.a], &files[pair.b]
+		ratio := float64(bytes) / float64(max(a.Size, b.Size))
+		if ratio >= similarity.Threshold {
+			pairs = append(pairs, SimilarPair{
+				A:           a.Path,
+				B:           b.Path,
+				SharedBytes: bytes,
+				Similarity:  ratio,
+			})
+		}
+	}
+	return
+}
+
+func similarClusters(
+	files []chunkedFile,
+	pairs []SimilarPair,
+) []SimilarCluster {
+	indices := make(map[string]int, len(files))
+	for i := range files {
+		indices[files[i].Path] = i
+	}
+
+	// union-find over the files connected by similar pairs
+	parents := make([]int, len(files))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+	for i := range pairs {
+		parents[find(indices[pairs[i].A])] = find(indices[pairs[i].B])
+	}
+
+	byRoot := map[int]*SimilarCluster{}
+	for i := range pairs {
+		root := find(indices[pairs[i].A])
+		cluster, exists := byRoot[root]
+		if !exists {
+			cluster = new(SimilarCluster)
+			byRoot[root] = cluster
+		}
+		cluster.Pairs = append(cluster.Pairs, pairs[i])
+	}
+
+	clusters := make([]SimilarCluster, 0, len(byRoot))
+	for root, cluster := range byRoot {
+		unique := map[uint64]int64{}
+		for i := range files {
+			if find(i) != root {
+				continue
+			}
+			cluster.Paths = append(cluster.Paths, files[i].Path)
+			cluster.TotalBytes += files[i].Size
+			for hash, chunk := range files[i].chunks {
+				unique[hash] = chunk.size
+			}
+		}
+		for _, size := range unique {
+			cluster.UniqueBytes += size
+		}
+		slices.SortFunc(cluster.Pairs, func(l, r SimilarPair) int {
+			return cmp.Compare(r.Similarity, l.Similarity)
+		})
+		clusters = append(clusters, *cluster)
+	}
+
+	slices.SortFunc(clusters, func(l, r SimilarCluster) int {
+		return cmp.Compare(r.Savings(), l.Savings())
+	})
+	return clusters
+}
+
+func chunkFile(
+	fsys FS,
+	path string,
+	avgSize int,
+) (chunks map[uint64]chunkCount, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("chunking file `%s`: %w", path, err)
+		}
+	}()
+
+	var file io.ReadSeekCloser
+	if file, err = fsys.Open(path); err != nil {
+		return
+	}
+	defer func() { err = errors.Join(err, file.Close()) }()
+
+	chunks = map[uint64]chunkCount{}
+	chunker := NewChunker(file, avgSize)
+	for {
+		var chunk []byte
+		if chunk, err = chunker.Next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			return
+		}
+
+		sum := sha256.Sum256(chunk)
+		hash := binary.LittleEndian.Uint64(sum[:])
+		count := chunks[hash]
+		count.size = int64(len(chunk))
+		count.count++
+		chunks[hash] = count
+	}
+}
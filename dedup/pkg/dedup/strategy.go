@@ -0,0 +1,62 @@
+package dedup
+
+import (
+	"fmt"
+)
+
+// Strategy decides what happens to a file once it has been proven to be a
+// duplicate of the canonical file in its group.
+type Strategy interface {
+	// Dedup disposes of `duplicate`, whose contents are identical to those
+	// of `canonical`. Implementations must operate on `opts.FS`, report
+	// their progress to `opts.Notifier`, and leave the filesystem untouched
+	// if `opts.DryRun` is set.
+	Dedup(opts *Options, duplicate, canonical string, size int64) error
+}
+
+// Link is a [Strategy] which replaces duplicate files with hard links to the
+// canonical file.
+type Link struct{}
+
+// Dedup implements [Strategy].
+func (Link) Dedup(opts *Options, duplicate, canonical string, size int64) error {
+	opts.Notifier.RemovingDuplicateFile(size, duplicate)
+	if opts.DryRun {
+		return nil
+	}
+	return ToLink(opts.FS, duplicate, canonical)
+}
+
+// linksFiles returns true if `strategy` replaces duplicates with hard links,
+// which requires that duplicates are on the same device as their canonical
+// file.
+func linksFiles(strategy Strategy) bool {
+	switch strategy.(type) {
+	case Link, *Link:
+		return true
+	default:
+		return false
+	}
+}
+
+// Delete is a [Strategy] which removes duplicate files outright. Unlike
+// [Quarantine], deleted files cannot be restored.
+type Delete struct{}
+
+// Dedup implements [Strategy].
+func (Delete) Dedup(opts *Options, duplicate, canonical string, size int64) error {
+	opts.Notifier.DeletingDuplicateFile(size, duplicate)
+	if opts.DryRun {
+		return nil
+	}
+
+	if err := opts.FS.Remove(duplicate); err != nil {
+		return fmt.Errorf(
+			"deleting duplicate file `%s` of file `%s`: %w",
+			duplicate,
+			canonical,
+			err,
+		)
+	}
+	return nil
+}
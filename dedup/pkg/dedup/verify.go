@@ -0,0 +1,270 @@
+package dedup
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+)
+
+// LinkGroup is a set of paths which share a single inode.
+type LinkGroup struct {
+	// Dev identifies the device containing the inode.
+	Dev uint64
+
+	// Ino identifies the inode.
+	Ino uint64
+
+	// Size is the size of the inode's contents.
+	Size int64
+
+	// Nlink is the inode's total number of hard links. If it exceeds
+	// `len(Paths)`, some of the links are outside of the verified roots.
+	Nlink uint64
+
+	// Paths are the paths beneath the verified roots which link to the
+	// inode.
+	Paths []string
+}
+
+// IssueKind classifies a problem found by [Verify].
+type IssueKind string
+
+const (
+	// IssueMissing indicates that a file recorded in the journal no longer
+	// exists.
+	IssueMissing IssueKind = "missing"
+
+	// IssueUnlinked indicates that a file which was linked to its canonical
+	// file no longer shares the canonical file's inode.
+	IssueUnlinked IssueKind = "unlinked"
+
+	// IssueChanged indicates that a canonical file's contents no longer
+	// match the checksum recorded when it was deduplicated.
+	IssueChanged IssueKind = "changed"
+)
+
+// Issue is a problem found by [Verify].
+type Issue struct {
+	// Kind classifies the issue.
+	Kind IssueKind
+
+	// Path is the path of the affected file.
+	Path string
+
+	// Entry is the journal entry which the issue was found while
+	// cross-checking.
+	Entry *JournalEntry
+
+	// Message describes the issue.
+	Message string
+}
+
+// VerifyReport is the result of [Verify].
+type VerifyReport struct {
+	// Groups are the link groups found beneath the verified roots.
+	Groups []LinkGroup
+
+	// Issues are the problems found while cross-checking the journal.
+	Issues []Issue
+}
+
+// Verify walks `opts.Roots` and reports each group of paths which share an
+// inode. If `entries` are provided (see [ReadJournal]), each entry is also
+// cross-checked: the canonical and quarantined files must still exist, linked
+// duplicates must still share the canonical file's inode, and the canonical
+// file's contents must match the checksum recorded when it was deduplicated.
+func Verify(opts *Options, entries []JournalEntry) (*VerifyReport, error) {
+	if len(opts.Roots) < 1 {
+		return nil, errNoRoots
+	}
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	notify := opts.Notifier
+	for _, root := range opts.Roots {
+		notify.ScanningDirectory(root)
+	}
+
+	var report VerifyReport
+	if report.Groups, err = linkGroups(opts); err != nil {
+		return nil, err
+	}
+	notify.CollectedLinkGroups(len(report.Groups))
+	for i := range report.Groups {
+		notify.LinkGroup(&report.Groups[i])
+	}
+
+	v := verifier{
+		opts:      opts,
+		checksums: map[checksumKey]string{},
+		changed:   map[changedKey]struct{}{},
+	}
+	for i := range entries {
+		if err := v.verify(&entries[i]); err != nil {
+			return nil, err
+		}
+	}
+	report.Issues = v.issues
+	notify.VerifiedJournal(len(entries), len(report.Issues))
+	return &report, nil
+}
+
+func linkGroups(opts *Options) ([]LinkGroup, error) {
+	files := NewFileIter(opts)
+	groups := map[inode]*LinkGroup{}
+	for file, err, ok := files.Next(); ok; file, err, ok = files.Next() {
+		if err != nil {
+			return nil, err
+		}
+		if file.Nlink < 2 {
+			continue
+		}
+
+		ino := inode{dev: file.Dev, ino: file.Ino}
+		group, exists := groups[ino]
+		if !exists {
+			group = &LinkGroup{
+				Dev:   file.Dev,
+				Ino:   file.Ino,
+				Size:  file.Size,
+				Nlink: file.Nlink,
+			}
+			groups[ino] = group
+		}
+		group.Paths = append(group.Paths, file.Path)
+	}
+
+	out := make([]LinkGroup, 0, len(groups))
+	for _, group := range groups {
+		slices.Sort(group.Paths)
+		out = append(out, *group)
+	}
+	slices.SortFunc(out, func(l, r LinkGroup) int {
+		return cmp.Compare(l.Paths[0], r.Paths[0])
+	})
+	return out, nil
+}
+
+type verifier struct {
+	opts      *Options
+	checksums map[checksumKey]string
+	changed   map[changedKey]struct{}
+	issues    []Issue
+}
+
+type checksumKey struct {
+	inode
+	hash Hash
+}
+
+// changedKey identifies a changed inode so that it is only reported once,
+// regardless of how many journal entries reference it.
+type changedKey struct {
+	checksumKey
+	recorded string
+}
+
+func (v *verifier) verify(entry *JournalEntry) error {
+	canonical, exists, err := v.stat(entry, entry.Canonical)
+	if err != nil || !exists {
+		return err
+	}
+
+	if entry.Action == strategyAction(Link{}) {
+		stat, exists, err := v.stat(entry, entry.Path)
+		if err != nil {
+			return err
+		}
+		if exists && (stat.Dev != canonical.Dev || stat.Ino != canonical.Ino) {
+			v.issue(
+				IssueUnlinked,
+				entry.Path,
+				entry,
+				fmt.Sprintf("no longer linked to `%s`", entry.Canonical),
+			)
+		}
+	}
+
+	if entry.Quarantined != "" {
+		if _, _, err := v.stat(entry, entry.Quarantined); err != nil {
+			return err
+		}
+	}
+
+	if entry.Checksum == "" {
+		return nil
+	}
+	key := checksumKey{
+		inode: inode{dev: canonical.Dev, ino: canonical.Ino},
+		hash:  entry.Hash,
+	}
+	checksum, cached := v.checksums[key]
+	if !cached {
+		v.opts.Notifier.ChecksummingFile(entry.Canonical)
+		if checksum, err = ChecksumFile(
+			v.opts.FS,
+			entry.Hash,
+			entry.Canonical,
+		); err != nil {
+			return err
+		}
+		v.checksums[key] = checksum
+	}
+	if checksum == entry.Checksum {
+		return nil
+	}
+	changed := changedKey{checksumKey: key, recorded: entry.Checksum}
+	if _, reported := v.changed[changed]; !reported {
+		v.changed[changed] = struct{}{}
+		v.issue(
+			IssueChanged,
+			entry.Canonical,
+			entry,
+			fmt.Sprintf(
+				"%s checksum changed from %s to %s",
+				entry.Hash,
+				entry.Checksum,
+				checksum,
+			),
+		)
+	}
+	return nil
+}
+
+// stat returns the metadata for `path`, recording an issue if it doesn't
+// exist.
+func (v *verifier) stat(
+	entry *JournalEntry,
+	path string,
+) (stat Stat, exists bool, err error) {
+	if stat, err = v.opts.FS.Lstat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			v.issue(IssueMissing, path, entry, "file no longer exists")
+			err = nil
+			return
+		}
+		err = fmt.Errorf("verifying `%s`: %w", path, err)
+		return
+	}
+	exists = true
+	return
+}
+
+func (v *verifier) issue(
+	kind IssueKind,
+	path string,
+	entry *JournalEntry,
+	message string,
+) {
+	v.issues = append(v.issues, Issue{
+		Kind:    kind,
+		Path:    path,
+		Entry:   entry,
+		Message: message,
+	})
+	v.opts.Notifier.VerifyIssue(&v.issues[len(v.issues)-1])
+}
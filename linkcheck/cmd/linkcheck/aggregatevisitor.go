@@ -0,0 +1,145 @@
+package main
+
+import (
+	"cmp"
+	"net/url"
+	"slices"
+)
+
+// Aggregate is every link with the same problem with the same target, e.g.,
+// a dead URL in a site's footer is one aggregate rather than a result for
+// every page.
+type Aggregate struct {
+	// TargetURL is the absolute URL of the target (or the reference as-is
+	// if it isn't a valid URL).
+	TargetURL string `json:"targetURL"`
+
+	// Category is the category of the problem (see [DefaultSeverities]).
+	Category string `json:"category"`
+
+	// Message describes the problem.
+	Message string `json:"message"`
+
+	// Error describes why the target failed, if it did.
+	Error *ResultError `json:"error,omitempty"`
+
+	// Severity is the most severe of the links' severities.
+	Severity Severity `json:"severity"`
+
+	// Count is the number of links.
+	Count int `json:"count"`
+
+	// Sources are the links, in the order they were reported.
+	Sources []AggregateSource `json:"sources"`
+
+	// icon is the icon for the problem in pretty output.
+	icon string
+}
+
+// AggregateSource is a link to an [Aggregate]'s target.
+type AggregateSource struct {
+	BaseURL    string `json:"baseURL"`
+	Line       int    `json:"line,omitempty"`
+	Element    string `json:"element,omitempty"`
+	Attribute  string `json:"attribute,omitempty"`
+	TargetText string `json:"targetText"`
+	TargetURL  string `json:"targetURL"`
+}
+
+// AggregatePrinter prints aggregates.
+type AggregatePrinter interface {
+	PrintAggregate(*Aggregate)
+}
+
+// AggregateVisitor groups problematic results by target and problem, and
+// prints the aggregates on `Flush`, most links first. Results without
+// problems are discarded.
+type AggregateVisitor struct {
+	printer    AggregatePrinter
+	aggregates map[aggregateKey]*Aggregate
+}
+
+type aggregateKey struct {
+	targetURL string
+	category  string
+}
+
+func NewAggregateVisitor() *AggregateVisitor {
+	return &AggregateVisitor{aggregates: map[aggregateKey]*Aggregate{}}
+}
+
+func (visitor *AggregateVisitor) SetPrinter(
+	printer AggregatePrinter,
+) *AggregateVisitor {
+	visitor.printer = printer
+	return visitor
+}
+
+func (visitor *AggregateVisitor) Visit(r *Result) {
+	if r.Severity == "" {
+		return
+	}
+
+	key := aggregateKey{targetURL: absoluteTarget(r), category: category(r)}
+	aggregate, exists := visitor.aggregates[key]
+	if !exists {
+		icon, problem := diagnose(r)
+		aggregate = &Aggregate{
+			TargetURL: key.targetURL,
+			Category:  key.category,
+			Message:   problem,
+			Error:     r.Error,
+			Severity:  r.Severity,
+			icon:      icon,
+		}
+		visitor.aggregates[key] = aggregate
+	}
+	if r.Severity.AtLeast(aggregate.Severity) {
+		aggregate.Severity = r.Severity
+	}
+	aggregate.Count++
+	aggregate.Sources = append(aggregate.Sources, AggregateSource{
+		BaseURL:    r.BaseURL,
+		Line:       r.Line,
+		Element:    r.Element,
+		Attribute:  r.Attribute,
+		TargetText: r.TargetText,
+		TargetURL:  r.TargetURL,
+	})
+}
+
+// Flush prints the aggregates.
+func (visitor *AggregateVisitor) Flush() error {
+	aggregates := make([]*Aggregate, 0, len(visitor.aggregates))
+	for _, aggregate := range visitor.aggregates {
+		aggregates = append(aggregates, aggregate)
+	}
+	slices.SortFunc(aggregates, func(l, r *Aggregate) int {
+		return cmp.Or(
+			cmp.Compare(r.Count, l.Count),
+			cmp.Compare(l.TargetURL, r.TargetURL),
+			cmp.Compare(l.Category, r.Category),
+		)
+	})
+
+	if visitor.printer != nil {
+		for _, aggregate := range aggregates {
+			visitor.printer.PrintAggregate(aggregate)
+		}
+	}
+	return nil
+}
+
+// absoluteTarget returns the result's target URL resolved against its base
+// URL, or the target URL as-is if it can't be resolved.
+func absoluteTarget(r *Result) string {
+	base, err := url.Parse(r.BaseURL)
+	if err != nil {
+		return r.TargetURL
+	}
+	target, err := base.Parse(r.TargetURL)
+	if err != nil {
+		return r.TargetURL
+	}
+	return target.String()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// aggregateCollector is an [AggregatePrinter] which records the aggregates
+// it prints.
+type aggregateCollector []*Aggregate
+
+func (c *aggregateCollector) PrintAggregate(aggregate *Aggregate) {
+	*c = append(*c, aggregate)
+}
+
+func TestAggregateVisitor(t *testing.T) {
+	notFound := &ResultError{Code: ErrorHTTPStatus, Message: "404 Not Found"}
+	moved := []Redirect{{StatusCode: 301, URL: "https://example.com/new"}}
+	var aggregates aggregateCollector
+	visitor := NewAggregateVisitor().SetPrinter(&aggregates)
+	for _, r := range []Result{
+		// the same target, however it's written
+		{
+			BaseURL:   "https://example.com/a/",
+			TargetURL: "../missing",
+			Error:     notFound,
+			Severity:  SeverityWarning,
+		},
+		{
+			BaseURL:   "https://example.com/b",
+			TargetURL: "/missing",
+			Error:     notFound,
+			Severity:  SeverityError,
+		},
+		{
+			BaseURL:   "https://example.com/c",
+			Line:      3,
+			TargetURL: "https://example.com/missing",
+			Error:     notFound,
+			Severity:  SeverityInfo,
+		},
+
+		// the same target with a different problem
+		{
+			BaseURL:      "https://example.com/sitemap.xml",
+			TargetURL:    "/missing",
+			SitemapIssue: SitemapUnlisted,
+			Severity:     SeverityWarning,
+		},
+
+		// ties are sorted by target
+		{
+			BaseURL:       "https://example.com/b",
+			TargetURL:     "/moved",
+			RedirectIssue: RedirectPermanent,
+			Redirects:     moved,
+			Severity:      SeverityWarning,
+		},
+		{
+			BaseURL:       "https://example.com/c",
+			TargetURL:     "/moved",
+			RedirectIssue: RedirectPermanent,
+			Redirects:     moved,
+			Severity:      SeverityWarning,
+		},
+		{
+			BaseURL:   "https://example.com/a/",
+			TargetURL: "http://[bad",
+			Error:     &ResultError{Code: ErrorParse, Message: "bad"},
+			Severity:  SeverityError,
+		},
+		{
+			BaseURL:   "https://example.com/b",
+			TargetURL: "http://[bad",
+			Error:     &ResultError{Code: ErrorParse, Message: "bad"},
+			Severity:  SeverityError,
+		},
+
+		// results without problems are discarded
+		{BaseURL: "https://example.com/a/", TargetURL: "/ok"},
+	} {
+		visitor.Visit(&r)
+	}
+	if err := visitor.Flush(); err != nil {
+		t.Fatalf("Flush(): unexpected err: %v", err)
+	}
+
+	type summary struct {
+		targetURL string
+		category  string
+		severity  Severity
+		count     int
+		sources   []string
+	}
+	var got []summary
+	for _, aggregate := range aggregates {
+		s := summary{
+			targetURL: aggregate.TargetURL,
+			category:  aggregate.Category,
+			severity:  aggregate.Severity,
+			count:     aggregate.Count,
+		}
+		for _, source := range aggregate.Sources {
+			s.sources = append(s.sources, source.BaseURL)
+		}
+		got = append(got, s)
+	}
+	wanted := []summary{
+		{
+			targetURL: "https://example.com/missing",
+			category:  "http-status",
+			severity:  SeverityError,
+			count:     3,
+			sources: []string{
+				"https://example.com/a/",
+				"https://example.com/b",
+				"https://example.com/c",
+			},
+		},
+		{
+			targetURL: "http://[bad",
+			category:  "parse-error",
+			severity:  SeverityError,
+			count:     2,
+			sources: []string{
+				"https://example.com/a/",
+				"https://example.com/b",
+			},
+		},
+		{
+			targetURL: "https://example.com/moved",
+			category:  "redirect-permanent",
+			severity:  SeverityWarning,
+			count:     2,
+			sources: []string{
+				"https://example.com/b",
+				"https://example.com/c",
+			},
+		},
+		{
+			targetURL: "https://example.com/missing",
+			category:  "sitemap-unlisted",
+			severity:  SeverityWarning,
+			count:     1,
+			sources:   []string{"https://example.com/sitemap.xml"},
+		},
+	}
+	if !reflect.DeepEqual(got, wanted) {
+		t.Fatalf("wanted aggregates:\n%+v\ngot:\n%+v", wanted, got)
+	}
+
+	// sources keep the links as written
+	missing := aggregates[0]
+	if missing.Error != notFound || missing.Message == "" {
+		t.Fatalf("wanted the error and a message; got %+v", missing)
+	}
+	source := missing.Sources[2]
+	if source.TargetURL != "https://example.com/missing" || source.Line != 3 {
+		t.Fatalf("wanted the link as written; got %+v", source)
+	}
+	if source := missing.Sources[0]; source.TargetURL != "../missing" {
+		t.Fatalf("wanted the link as written; got %+v", source)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// BaselineStatus compares a result against a baseline.
+type BaselineStatus string
+
+const (
+	// BaselineNew indicates a link which is broken now but wasn't in the
+	// baseline.
+	BaselineNew BaselineStatus = "new"
+
+	// BaselineStillBroken indicates a link which was already broken in the
+	// baseline.
+	BaselineStillBroken BaselineStatus = "still-broken"
+
+	// BaselineFixed indicates a link which was broken in the baseline but
+	// isn't anymore.
+	BaselineFixed BaselineStatus = "fixed"
+)
+
+// Baseline is the set of broken links from a previous run.
+type Baseline struct {
+	broken map[baselineKey]Result
+}
+
+type baselineKey struct {
+	baseURL   string
+	targetURL string
+}
+
+// baselineEntry is a result as printed by [JSONResultPrinter], including
+// fields from older versions.
+type baselineEntry struct {
+	Result
+
+	// Older versions printed Go errors, which encoded as `{}`.
+	URLParseError json.RawMessage `json:"urlParseError"`
+	NetworkError  json.RawMessage `json:"networkError"`
+}
+
+// LoadBaseline reads the broken links from a file of JSON results, as printed
+// by `-format json`. Results from older versions, which only printed broken
+// links and had no severity, are supported.
+func LoadBaseline(path string) (baseline *Baseline, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("loading baseline `%s`: %w", path, err)
+		}
+	}()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errors.Join(err, file.Close()) }()
+
+	baseline = &Baseline{broken: map[baselineKey]Result{}}
+	decoder := json.NewDecoder(file)
+	for {
+		var entry baselineEntry
+		if err := decoder.Decode(&entry); err != nil {
+			if err == io.EOF {
+				return baseline, nil
+			}
+			return nil, err
+		}
+		if entry.broken() {
+			baseline.broken[entry.key()] = entry.Result
+		}
+	}
+}
+
+// broken returns true if the entry was a broken link. Entries without a
+// severity predate severities, when only broken links were printed.
+func (entry *baselineEntry) broken() bool {
+	return entry.Severity == "" ||
+		entry.Severity == SeverityError ||
+		entry.Baseline == BaselineNew ||
+		entry.Baseline == BaselineStillBroken
+}
+
+func (r *Result) key() baselineKey {
+	return baselineKey{baseURL: r.BaseURL, targetURL: r.TargetURL}
+}
+
+// BaselineVisitor compares results against a [Baseline] before passing them
+// to its inner visitor. Broken links which are already in the baseline are
+// demoted to warnings (so they don't fail the run) and marked
+// [BaselineStillBroken]; other broken links are marked [BaselineNew]. On
+// `Flush`, links from the baseline which are no longer broken are passed on
+// as [BaselineFixed].
+type BaselineVisitor struct {
+	baseline *Baseline
+	inner    ResultVisitor
+	broken   map[baselineKey]struct{}
+	counts   map[BaselineStatus]int
+
+	// pages are the base URLs of the results visited so far, i.e., the
+	// pages whose links were checked during this run.
+	pages map[string]struct{}
+}
+
+func NewBaselineVisitor(baseline *Baseline) *BaselineVisitor {
+	return &BaselineVisitor{
+		baseline: baseline,
+		broken:   map[baselineKey]struct{}{},
+		counts:   map[BaselineStatus]int{},
+		pages:    map[string]struct{}{},
+	}
+}
+
+func (visitor *BaselineVisitor) SetInner(
+	inner ResultVisitor,
+) *BaselineVisitor {
+	visitor.inner = inner
+	return visitor
+}
+
+func (visitor *BaselineVisitor) Visit(r *Result) {
+	visitor.pages[r.BaseURL] = struct{}{}
+	if r.Severity == SeverityError {
+		key := r.key()
+		if _, exists := visitor.baseline.broken[key]; exists {
+			r.Baseline = BaselineStillBroken
+			r.Severity = SeverityWarning
+		} else {
+			r.Baseline = BaselineNew
+		}
+		if _, exists := visitor.broken[key]; !exists {
+			visitor.broken[key] = struct{}{}
+			visitor.counts[r.Baseline]++
+		}
+	}
+	if visitor.inner != nil {
+		visitor.inner.Visit(r)
+	}
+}
+
+// Flush reports each link from the baseline which is no longer broken. Links
+// on pages which weren't checked during this run (e.g., because the crawl was
+// limited or timed out) may still be broken, so they aren't reported.
+func (visitor *BaselineVisitor) Flush() error {
+	var fixed []Result
+	for key, r := range visitor.baseline.broken {
+		if _, checked := visitor.pages[key.baseURL]; !checked {
+			continue
+		}
+		if _, exists := visitor.broken[key]; !exists {
+			fixed = append(fixed, Result{
+				BaseURL:    r.BaseURL,
+				Element:    r.Element,
+				Attribute:  r.Attribute,
+				TargetText: r.TargetText,
+				TargetURL:  r.TargetURL,
+				Severity:   SeverityInfo,
+				Baseline:   BaselineFixed,
+			})
+		}
+	}
+	slices.SortFunc(fixed, func(l, r Result) int {
+		if c := strings.Compare(l.BaseURL, r.BaseURL); c != 0 {
+			return c
+		}
+		return strings.Compare(l.TargetURL, r.TargetURL)
+	})
+	visitor.counts[BaselineFixed] = len(fixed)
+
+	if visitor.inner != nil {
+		for i := range fixed {
+			visitor.inner.Visit(&fixed[i])
+		}
+	}
+	return nil
+}
+
+// Summary describes how the run compares to the baseline.
+func (visitor *BaselineVisitor) Summary() string {
+	return fmt.Sprintf(
+		"compared to the baseline: %d new, %d still broken, %d fixed",
+		visitor.counts[BaselineNew],
+		visitor.counts[BaselineStillBroken],
+		visitor.counts[BaselineFixed],
+	)
+}
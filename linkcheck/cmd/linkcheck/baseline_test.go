@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// collector is a [ResultVisitor] which records the results it visits.
+type collector []*Result
+
+func (c *collector) Visit(r *Result) { *c = append(*c, r) }
+
+// writeBaseline writes `lines` to a baseline file and loads it.
+func writeBaseline(t *testing.T, lines ...string) *Baseline {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	var data []byte
+	for _, line := range lines {
+		data = append(data, line+"\n"...)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("writing baseline: %v", err)
+	}
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline(): unexpected err: %v", err)
+	}
+	return baseline
+}
+
+func TestLoadBaseline(t *testing.T) {
+	baseline := writeBaseline(
+		t,
+		resultJSON(t, Result{
+			BaseURL:   "/a",
+			TargetURL: "/error",
+			Severity:  SeverityError,
+		}),
+		resultJSON(t, Result{
+			BaseURL:   "/a",
+			TargetURL: "/warning",
+			Severity:  SeverityWarning,
+		}),
+
+		// a demoted result from a run which itself had a baseline
+		resultJSON(t, Result{
+			BaseURL:   "/a",
+			TargetURL: "/still-broken",
+			Severity:  SeverityWarning,
+			Baseline:  BaselineStillBroken,
+		}),
+
+		// older versions only printed broken links, without severities,
+		// and encoded their errors as `{}`
+		`{"baseURL":"/a","targetURL":"/old","networkError":{}}`,
+	)
+
+	for _, tc := range []struct {
+		target string
+		broken bool
+	}{
+		{target: "/error", broken: true},
+		{target: "/warning"},
+		{target: "/still-broken", broken: true},
+		{target: "/old", broken: true},
+	} {
+		key := baselineKey{baseURL: "/a", targetURL: tc.target}
+		if _, broken := baseline.broken[key]; broken != tc.broken {
+			t.Fatalf("`%s`: wanted broken: %t", tc.target, tc.broken)
+		}
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	if _, err := LoadBaseline(missing); err == nil {
+		t.Fatal("LoadBaseline(): wanted an error for a missing file")
+	}
+}
+
+func TestBaselineVisitor(t *testing.T) {
+	baseline := writeBaseline(
+		t,
+		resultJSON(t, Result{
+			BaseURL:   "/a",
+			TargetURL: "/still",
+			Severity:  SeverityError,
+		}),
+		resultJSON(t, Result{
+			BaseURL:   "/a",
+			TargetURL: "/fixed",
+			Severity:  SeverityError,
+		}),
+		resultJSON(t, Result{
+			BaseURL:   "/a",
+			TargetURL: "/removed",
+			Severity:  SeverityError,
+		}),
+
+		// `/unchecked` isn't crawled this time (e.g., because of a page
+		// budget), so its links may well still be broken
+		resultJSON(t, Result{
+			BaseURL:   "/unchecked",
+			TargetURL: "/broken",
+			Severity:  SeverityError,
+		}),
+	)
+
+	var results collector
+	visitor := NewBaselineVisitor(baseline).SetInner(&results)
+	for _, r := range []Result{
+		{BaseURL: "/a", TargetURL: "/still", Severity: SeverityError},
+		{BaseURL: "/a", TargetURL: "/fixed"},
+		{BaseURL: "/a", TargetURL: "/new", Severity: SeverityError},
+
+		// the same broken link twice is only counted once
+		{BaseURL: "/a", TargetURL: "/new", Severity: SeverityError},
+		{BaseURL: "/a", TargetURL: "/moved", Severity: SeverityWarning},
+	} {
+		visitor.Visit(&r)
+	}
+	if err := visitor.Flush(); err != nil {
+		t.Fatalf("Flush(): unexpected err: %v", err)
+	}
+
+	type outcome struct {
+		target   string
+		severity Severity
+		status   BaselineStatus
+	}
+	var outcomes []outcome
+	for _, r := range results {
+		outcomes = append(outcomes, outcome{
+			target:   r.TargetURL,
+			severity: r.Severity,
+			status:   r.Baseline,
+		})
+	}
+	wanted := []outcome{
+		{
+			target:   "/still",
+			severity: SeverityWarning,
+			status:   BaselineStillBroken,
+		},
+		{target: "/fixed"},
+		{target: "/new", severity: SeverityError, status: BaselineNew},
+		{target: "/new", severity: SeverityError, status: BaselineNew},
+		{target: "/moved", severity: SeverityWarning},
+
+		// links which are gone from a checked page are fixed too
+		{target: "/fixed", severity: SeverityInfo, status: BaselineFixed},
+		{target: "/removed", severity: SeverityInfo, status: BaselineFixed},
+	}
+	if len(outcomes) != len(wanted) {
+		t.Fatalf("wanted outcomes %+v; got %+v", wanted, outcomes)
+	}
+	for i := range wanted {
+		if outcomes[i] != wanted[i] {
+			t.Fatalf("wanted outcomes %+v; got %+v", wanted, outcomes)
+		}
+	}
+
+	const summary = "compared to the baseline: 1 new, 1 still broken, 2 fixed"
+	if got := visitor.Summary(); got != summary {
+		t.Fatalf("wanted summary %q; got %q", summary, got)
+	}
+}
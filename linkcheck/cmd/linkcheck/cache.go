@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache persists the outcome of checking external links between runs so that
+// repeated runs (e.g., in CI) don't re-request every external URL. Successes
+// and failures expire separately, so that failures (which may be transient)
+// can be retried sooner. Pages which are part of the site are never cached.
+type Cache struct {
+	// SuccessTTL is how long a successful outcome is reused.
+	SuccessTTL time.Duration
+
+	// FailureTTL is how long a failed outcome is reused.
+	FailureTTL time.Duration
+
+	path    string
+	mutex   sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is the outcome of fetching an external URL.
+type cacheEntry struct {
+	Checked    time.Time    `json:"checked"`
+	StatusCode int          `json:"statusCode,omitempty"`
+	Error      *ResultError `json:"error,omitempty"`
+	Redirects  []Redirect   `json:"redirects,omitempty"`
+
+	// Anchors are the fragment identifiers defined by the page, or nil if
+	// it wasn't parsed as HTML.
+	Anchors []string `json:"anchors,omitempty"`
+	HTML    bool     `json:"html,omitempty"`
+
+	// Soft404 is true if the page was detected as a soft 404.
+	Soft404 bool `json:"soft404,omitempty"`
+
+	// Soft404Checked is true if soft 404s were detected (see
+	// `Crawler.DetectSoft404`) when the outcome was recorded. Otherwise
+	// `Soft404` is meaningless.
+	Soft404Checked bool `json:"soft404Checked,omitempty"`
+}
+
+// LoadCache reads the cache file at `path`. A missing file is an empty
+// cache, which is created by `Save`.
+func LoadCache(
+	path string,
+	successTTL time.Duration,
+	failureTTL time.Duration,
+) (cache *Cache, err error) {
+	cache = &Cache{
+		SuccessTTL: successTTL,
+		FailureTTL: failureTTL,
+		path:       path,
+		entries:    map[string]cacheEntry{},
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return cache, nil
+		}
+		return nil, fmt.Errorf("loading cache `%s`: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		return nil, fmt.Errorf("loading cache `%s`: %w", path, err)
+	}
+	return cache, nil
+}
+
+// Save writes the unexpired entries to the cache file.
+func (cache *Cache) Save() (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("saving cache `%s`: %w", cache.path, err)
+		}
+	}()
+
+	cache.mutex.Lock()
+	now := time.Now()
+	entries := make(map[string]cacheEntry, len(cache.entries))
+	for key, entry := range cache.entries {
+		if !cache.expired(entry, now) {
+			entries[key] = entry
+		}
+	}
+	cache.mutex.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	// write a temporary file and rename it so that an interrupted run
+	// doesn't leave a truncated cache behind
+	file, err := os.CreateTemp(filepath.Dir(cache.path), ".linkcheck-cache-*")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		return errors.Join(err, file.Close(), os.Remove(file.Name()))
+	}
+	if err := file.Close(); err != nil {
+		return errors.Join(err, os.Remove(file.Name()))
+	}
+	if err := os.Rename(file.Name(), cache.path); err != nil {
+		return errors.Join(err, os.Remove(file.Name()))
+	}
+	return nil
+}
+
+func (cache *Cache) expired(entry cacheEntry, now time.Time) bool {
+	ttl := cache.SuccessTTL
+	if entry.Error != nil {
+		ttl = cache.FailureTTL
+	}
+	return now.Sub(entry.Checked) >= ttl
+}
+
+// get returns the unexpired outcome of fetching `u`, if there is one.
+func (cache *Cache) get(u *url.URL) (cacheEntry, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, exists := cache.entries[normalizeURL(u)]
+	if !exists || cache.expired(entry, time.Now()) {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// put records the outcome of fetching `u`.
+func (cache *Cache) put(u *url.URL, entry cacheEntry) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.entries[normalizeURL(u)] = entry
+}
+
+// normalizeURL returns the cache key for `u`: the URL without its fragment,
+// with its scheme and host lowercased, default ports removed, and an empty
+// path replaced with `/`.
+func normalizeURL(u *url.URL) string {
+	normalized := *stripFragment(u)
+	normalized.Scheme = strings.ToLower(normalized.Scheme)
+	normalized.Host = strings.ToLower(normalized.Host)
+	if port := normalized.Port(); (normalized.Scheme == "http" &&
+		port == "80") || (normalized.Scheme == "https" && port == "443") {
+		normalized.Host = strings.TrimSuffix(normalized.Host, ":"+port)
+	}
+	if normalized.Path == "" && normalized.Opaque == "" {
+		normalized.Path = "/"
+	}
+	return normalized.String()
+}
+
+// cached returns the unexpired cache entry for `u`, if it is an external URL
+// with one. An entry recorded with soft 404 detection on (or off) is only
+// used while it is still on (or off).
+func (crawler *Crawler) cached(u *url.URL) (cacheEntry, bool) {
+	if !crawler.cacheable(u) {
+		return cacheEntry{}, false
+	}
+	entry, ok := crawler.Cache.get(u)
+	if !ok || entry.Soft404Checked != crawler.DetectSoft404 {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// fetched returns the outcome of fetching `u` recorded by the entry.
+func (entry *cacheEntry) fetched(u *url.URL) (page fetched, err error) {
+	page = fetched{
+		url:       u,
+		redirects: entry.Redirects,
+		cached:    true,
+		soft404:   entry.Soft404,
+	}
+	if entry.HTML {
+		page.anchors = make(map[string]struct{}, len(entry.Anchors))
+		for _, anchor := range entry.Anchors {
+			page.anchors[anchor] = struct{}{}
+		}
+	}
+	switch {
+	case entry.Error == nil:
+		return page, nil
+	case entry.Error.Code == ErrorHTTPStatus:
+		return page, ErrNotOk(entry.StatusCode)
+	default:
+		return page, entry.Error
+	}
+}
+
+// cache records the outcome of fetching `u` if it is an external URL. Once
+// the crawl's `OverallTimeout` has expired, outcomes are no longer recorded,
+// since they may have been cut short by it rather than reflecting the URL.
+func (crawler *Crawler) cache(u *url.URL, page fetched, err error) {
+	expired := crawler.ctx != nil && crawler.ctx.Err() != nil
+	if !crawler.cacheable(u) || expired {
+		return
+	}
+	entry := cacheEntry{
+		Checked:   time.Now(),
+		Redirects: page.redirects,
+		Soft404:   page.soft404,
+
+		Soft404Checked: crawler.DetectSoft404,
+	}
+	if err != nil {
+		entry.Error = classify(err)
+		if statusCode, ok := err.(ErrNotOk); ok {
+			entry.StatusCode = int(statusCode)
+		}
+	}
+	if page.anchors != nil {
+		entry.HTML = true
+		entry.Anchors = make([]string, 0, len(page.anchors))
+		for anchor := range page.anchors {
+			entry.Anchors = append(entry.Anchors, anchor)
+		}
+	}
+	crawler.Cache.put(u, entry)
+}
+
+// cacheable returns true if the outcome of fetching `u` may be cached: it is
+// an HTTP(S) URL which isn't part of the site.
+func (crawler *Crawler) cacheable(u *url.URL) bool {
+	return crawler.Cache != nil &&
+		(u.Scheme == "http" || u.Scheme == "https") &&
+		!crawler.Scope.internal(crawler.Host, u)
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	for _, tc := range []struct {
+		url    string
+		wanted string
+	}{
+		{url: "https://example.com", wanted: "https://example.com/"},
+		{url: "HTTPS://Example.COM/Page", wanted: "https://example.com/Page"},
+		{url: "https://example.com:443/a", wanted: "https://example.com/a"},
+		{url: "http://example.com:80/a", wanted: "http://example.com/a"},
+		{url: "http://example.com:443/a", wanted: "http://example.com:443/a"},
+		{url: "https://example.com/a#top", wanted: "https://example.com/a"},
+		{url: "https://example.com/a?q=1", wanted: "https://example.com/a?q=1"},
+		{url: "mailto:a@example.com", wanted: "mailto:a@example.com"},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			if got := normalizeURL(mustParse(tc.url)); got != tc.wanted {
+				t.Fatalf("wanted %q; got %q", tc.wanted, got)
+			}
+		})
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	failure := &ResultError{Code: ErrorHTTPStatus, Message: "404 Not Found"}
+	for _, tc := range []struct {
+		name   string
+		age    time.Duration
+		error  *ResultError
+		cached bool
+	}{
+		{name: "fresh success", age: 30 * time.Minute, cached: true},
+		{name: "stale success", age: 2 * time.Hour},
+		{
+			name:   "fresh failure",
+			age:    time.Second,
+			error:  failure,
+			cached: true,
+		},
+		{name: "stale failure", age: 30 * time.Minute, error: failure},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cache, err := LoadCache(
+				filepath.Join(t.TempDir(), "cache.json"),
+				time.Hour,
+				time.Minute,
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			u := mustParse("https://example.com/")
+			cache.put(u, cacheEntry{
+				Checked: time.Now().Add(-tc.age),
+				Error:   tc.error,
+			})
+			if _, cached := cache.get(u); cached != tc.cached {
+				t.Fatalf("wanted cached: %t", tc.cached)
+			}
+		})
+	}
+}
+
+func TestCacheSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := LoadCache(path, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatalf("LoadCache(): unexpected err: %v", err)
+	}
+	checked := time.Now().Add(-time.Second)
+	entries := map[string]cacheEntry{
+		"https://example.com/ok": {
+			Checked:    checked,
+			StatusCode: 200,
+			Anchors:    []string{"top"},
+			HTML:       true,
+		},
+		"https://example.com/missing": {
+			Checked:    checked,
+			StatusCode: 404,
+			Error: &ResultError{
+				Code:    ErrorHTTPStatus,
+				Message: "404 Not Found",
+			},
+		},
+		"https://example.com/moved": {
+			Checked: checked,
+			Redirects: []Redirect{{
+				StatusCode: 301,
+				URL:        "https://example.com/new",
+			}},
+		},
+	}
+	for key, entry := range entries {
+		cache.put(mustParse(key), entry)
+	}
+
+	// expired entries aren't saved
+	cache.put(mustParse("https://example.com/stale"), cacheEntry{
+		Checked: time.Now().Add(-2 * time.Minute),
+		Error:   &ResultError{Code: ErrorTimeout, Message: "timeout"},
+	})
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save(): unexpected err: %v", err)
+	}
+
+	loaded, err := LoadCache(path, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatalf("LoadCache(): unexpected err: %v", err)
+	}
+	for key, entry := range loaded.entries {
+		// the location of the checked time isn't preserved
+		if !entry.Checked.Equal(checked) {
+			t.Fatalf(
+				"`%s`: wanted checked %s; got %s",
+				key,
+				checked,
+				entry.Checked,
+			)
+		}
+		entry.Checked = checked
+		loaded.entries[key] = entry
+	}
+	if !reflect.DeepEqual(loaded.entries, entries) {
+		t.Fatalf("wanted entries %+v; got %+v", entries, loaded.entries)
+	}
+
+	// no temporary files are left behind
+	files, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("reading cache directory: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("wanted only the cache file; found %d files", len(files))
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatalf("corrupting cache: %v", err)
+	}
+	if _, err := LoadCache(path, time.Hour, time.Minute); err == nil {
+		t.Fatal("LoadCache(): wanted an error for a corrupt cache")
+	}
+}
+
+func TestCacheCrawl(t *testing.T) {
+	var requests atomic.Int32
+	external := newSite(t, map[string]http.HandlerFunc{
+		"/page": func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			html(`<h2 id="section">section</h2>`)(w, r)
+		},
+		"/missing": func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		},
+	})
+	var internalRequests atomic.Int32
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="` + external.URL + `/page#section">page</a>` +
+			`<a href="` + external.URL + `/page#nowhere">nowhere</a>` +
+			`<a href="` + external.URL + `/missing">missing</a>` +
+			`<a href="/internal">internal</a>`),
+		"/internal": func(w http.ResponseWriter, r *http.Request) {
+			internalRequests.Add(1)
+			html("internal")(w, r)
+		},
+	})
+
+	path := filepath.Join(t.TempDir(), "cache.json")
+	for run := 1; run <= 2; run++ {
+		cache, err := LoadCache(path, time.Hour, time.Hour)
+		if err != nil {
+			t.Fatalf("run %d: LoadCache(): unexpected err: %v", run, err)
+		}
+		crawler := newTestCrawler(server).SetCache(cache)
+		results, err := crawl(t, crawler, server.URL+"/")
+		if err != nil {
+			t.Fatalf("run %d: unexpected error: %v", run, err)
+		}
+		if err := cache.Save(); err != nil {
+			t.Fatalf("run %d: Save(): unexpected err: %v", run, err)
+		}
+
+		// the second run reuses every external outcome, including the
+		// page's anchors and the failure's status code
+		cached := run == 2
+		for _, tc := range []struct {
+			target     string
+			code       ErrorCode
+			statusCode int
+		}{
+			{target: external.URL + "/page#section"},
+			{
+				target: external.URL + "/page#nowhere",
+				code:   ErrorFragmentMissing,
+			},
+			{
+				target:     external.URL + "/missing",
+				code:       ErrorHTTPStatus,
+				statusCode: http.StatusNotFound,
+			},
+		} {
+			result := only(t, results, tc.target)
+			if result.Cached != cached {
+				t.Fatalf(
+					"run %d: `%s`: wanted cached: %t",
+					run,
+					tc.target,
+					cached,
+				)
+			}
+			var code ErrorCode
+			if result.Error != nil {
+				code = result.Error.Code
+			}
+			if code != tc.code {
+				t.Fatalf(
+					"run %d: `%s`: wanted error %q; got %v",
+					run,
+					tc.target,
+					tc.code,
+					result.Error,
+				)
+			}
+			if tc.statusCode != 0 && result.StatusCode != tc.statusCode {
+				t.Fatalf(
+					"run %d: `%s`: wanted status %d; got %d",
+					run,
+					tc.target,
+					tc.statusCode,
+					result.StatusCode,
+				)
+			}
+		}
+		if result := only(t, results, "/internal"); result.Cached {
+			t.Fatalf("run %d: wanted internal pages not to be cached", run)
+		}
+	}
+
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("wanted 2 external requests over both runs; got %d", got)
+	}
+	if got := internalRequests.Load(); got != 2 {
+		t.Fatalf("wanted the internal page fetched on both runs; got %d", got)
+	}
+}
+
+func TestCacheSkipsOverallTimeout(t *testing.T) {
+	external := newSite(t, map[string]http.HandlerFunc{
+		"/fast": html("fast"),
+		"/slow": func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(crawlTimeout):
+			}
+		},
+	})
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="` + external.URL + `/fast">fast</a>` +
+			`<a href="` + external.URL + `/slow">slow</a>`),
+	})
+
+	cache, err := LoadCache(
+		filepath.Join(t.TempDir(), "cache.json"),
+		time.Hour,
+		time.Hour,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	crawler := newTestCrawler(server).
+		SetCache(cache).
+		SetOverallTimeout(500 * time.Millisecond)
+	results, err := crawl(t, crawler, server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := only(t, results, external.URL+"/slow"); result.Error == nil {
+		t.Fatal("wanted `/slow` to fail when the crawl timed out")
+	}
+
+	if _, ok := cache.get(mustParse(external.URL + "/fast")); !ok {
+		t.Fatal("wanted `/fast` to be cached")
+	}
+	if entry, ok := cache.get(mustParse(external.URL + "/slow")); ok {
+		t.Fatalf("wanted `/slow` not to be cached; found %+v", entry)
+	}
+}
+
+// TestCacheSoft404 checks that an outcome cached with soft 404 detection on
+// (or off) isn't reused while it's off (or on), since whether the page is a
+// soft 404 is only known if it was detected.
+func TestCacheSoft404(t *testing.T) {
+	external := newSite(t, map[string]http.HandlerFunc{
+		"/": html("<title>Home</title><p>Welcome to our home page, " +
+			"where we sell all sorts of things.</p>"),
+		"/{path...}": func(w http.ResponseWriter, r *http.Request) {
+			html("<title>Not Found</title><p>Sorry, "+r.URL.Path+
+				" doesn't exist.</p>")(w, r)
+		},
+	})
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="` + external.URL + `/gone">gone</a>`),
+	})
+
+	cache, err := LoadCache(
+		filepath.Join(t.TempDir(), "cache.json"),
+		time.Hour,
+		time.Hour,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, tc := range []struct {
+		detect bool
+		cached bool
+	}{
+		{detect: false},
+		{detect: true},
+		{detect: true, cached: true},
+		{detect: false},
+		{detect: false, cached: true},
+	} {
+		crawler := newTestCrawler(server).
+			SetCache(cache).
+			SetDetectSoft404(tc.detect)
+		results, err := crawl(t, crawler, server.URL+"/")
+		if err != nil {
+			t.Fatalf("run %d: unexpected error: %v", i+1, err)
+		}
+		result := only(t, results, external.URL+"/gone")
+		if result.Cached != tc.cached {
+			t.Fatalf("run %d: wanted cached: %t", i+1, tc.cached)
+		}
+		if result.SoftNotFound != tc.detect {
+			t.Fatalf("run %d: wanted soft 404: %t", i+1, tc.detect)
+		}
+	}
+}
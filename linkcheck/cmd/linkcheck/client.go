@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// DefaultMaxBodySize is the default for `Crawler.MaxBodySize`.
+const DefaultMaxBodySize = 10 << 20
+
+// DefaultTimeout is the default for `Crawler.Timeout`, so that a server which
+// never responds can't stall the crawl.
+const DefaultTimeout = 30 * time.Second
+
+// newRequest prepares a request which is canceled when the crawl's
+// `OverallTimeout` expires.
+func (crawler *Crawler) newRequest(
+	method string,
+	u string,
+) (*http.Request, error) {
+	ctx := crawler.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "linkcheck/1.0")
+	return req, nil
+}
+
+// head returns true if `t` should be checked with a HEAD request rather than
+// fetched: it is external (so it won't be crawled) and none of its links so
+// far have a fragment (which requires the page's anchors). Links with
+// fragments which arrive later cause it to be fetched again (see
+// [Crawler.process]). The caller must hold `mutex`.
+func (crawler *Crawler) head(t *target) bool {
+	return crawler.HeadExternal && !crawler.DetectSoft404 &&
+		!crawler.Scope.internal(crawler.Host, t.url) &&
+		!hasFragments(t.links)
+}
+
+// send sends `req` via [Crawler.follow]. Since some servers don't support
+// HEAD requests (or block them), a HEAD request which is rejected with 405
+// or 403 is sent again as a GET request.
+func (crawler *Crawler) send(
+	req *http.Request,
+) (
+	rsp *http.Response,
+	release func(),
+	redirects []Redirect,
+	attempts int,
+	err error,
+) {
+	rsp, release, redirects, attempts, err = crawler.follow(req)
+	if err != nil ||
+		req.Method != http.MethodHead ||
+		(rsp.StatusCode != http.StatusMethodNotAllowed &&
+			rsp.StatusCode != http.StatusForbidden) {
+		return
+	}
+	rsp.Body.Close()
+	release()
+
+	get := req.Clone(req.Context())
+	get.Method = http.MethodGet
+	headAttempts := attempts
+	rsp, release, redirects, attempts, err = crawler.follow(get)
+	attempts += headAttempts
+	return
+}
+
+// limitBody limits `body` to `MaxBodySize` bytes, if set. The returned
+// function reports whether the limit was reached.
+func (crawler *Crawler) limitBody(
+	body io.ReadCloser,
+) (io.ReadCloser, func() bool) {
+	if crawler.MaxBodySize < 1 {
+		return body, func() bool { return false }
+	}
+	limited := &io.LimitedReader{R: body, N: crawler.MaxBodySize}
+	return readCloser{limited, body}, func() bool { return limited.N < 1 }
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// isHTML returns true if `rsp` has an HTML body. If the response doesn't
+// declare a content type, its body is sniffed.
+func isHTML(rsp *http.Response) bool {
+	contentType := rsp.Header.Get("Content-Type")
+	if contentType == "" {
+		r := bufio.NewReader(rsp.Body)
+		sniff, _ := r.Peek(512)
+		contentType = http.DetectContentType(sniff)
+		rsp.Body = readCloser{r, rsp.Body}
+	}
+	return isHTMLType(contentType)
+}
+
+// isHTMLFile returns true if the file at `path` is HTML, according to its
+// extension.
+func isHTMLFile(path string) bool {
+	return isHTMLType(mime.TypeByExtension(filepath.Ext(path)))
+}
+
+func isHTMLType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil &&
+		(mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// overallContext returns the context for a crawl, which is canceled
+// after `OverallTimeout` (if set).
+func (crawler *Crawler) overallContext() (context.Context, func()) {
+	if crawler.OverallTimeout > 0 {
+		return context.WithTimeout(context.Background(), crawler.OverallTimeout)
+	}
+	return context.WithCancel(context.Background())
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is the configuration file loaded from the working
+// directory if no other is specified.
+const DefaultConfigFile = ".linkcheck.yaml"
+
+// Config is the contents of a `.linkcheck.yaml` file, e.g.:
+//
+//	ignore:
+//	  - "http://localhost*"
+//	  - "re:^https://example\\.com/private/"
+//	timeout: 10s
+//	domains:
+//	  linkedin.com:
+//	    accept: [999]
+//	  docs.example.com:
+//	    headers:
+//	      X-Api-Key: "..."
+//	    auth:
+//	      bearer: "${DOCS_TOKEN}"
+//	    timeout: 30s
+//	cookies: cookies.txt
+//	severity:
+//	  redirect-permanent: info
+//	  fragment-missing: warning
+type Config struct {
+	// Ignore are patterns (see [ParsePattern]) of URLs which aren't
+	// checked.
+	Ignore []string `yaml:"ignore"`
+
+	// Timeout is the timeout for each request. Zero leaves the crawler's
+	// (by default, [DefaultTimeout]).
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Domains are per-domain policies keyed by domain, or by a wildcard for
+	// its subdomains, e.g., `*.example.com`. A domain's `accept` and
+	// `timeout` also apply to its subdomains, but its headers and
+	// credentials don't.
+	Domains map[string]DomainPolicy `yaml:"domains"`
+
+	// Cookies is the path of a Netscape cookie file (see [LoadCookieFile]),
+	// relative to the configuration file.
+	Cookies string `yaml:"cookies"`
+
+	// Severity overrides the severity of categories of results (see
+	// [DefaultSeverities]).
+	Severity map[string]Severity `yaml:"severity"`
+}
+
+// LoadConfig reads the configuration file at `path`. If `path` is empty,
+// [DefaultConfigFile] is read if it exists, and otherwise an empty
+// configuration is returned.
+func LoadConfig(path string) (config Config, err error) {
+	optional := path == ""
+	if optional {
+		path = DefaultConfigFile
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("loading config file `%s`: %w", path, err)
+		}
+	}()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+
+	if err = yaml.Unmarshal(data, &config); err != nil {
+		return
+	}
+	for domain, policy := range config.Domains {
+		if strings.Contains(strings.TrimPrefix(domain, "*."), "*") {
+			err = fmt.Errorf(
+				"domain `%s`: wildcards are only supported as a `*.` prefix",
+				domain,
+			)
+			return
+		}
+		if policy.Auth != nil {
+			if err = policy.Auth.validate(); err != nil {
+				err = fmt.Errorf("domain `%s`: %w", domain, err)
+				return
+			}
+		}
+	}
+	if config.Cookies != "" && !filepath.IsAbs(config.Cookies) {
+		config.Cookies = filepath.Join(filepath.Dir(path), config.Cookies)
+	}
+	for category := range config.Severity {
+		if _, exists := DefaultSeverities[category]; !exists {
+			err = fmt.Errorf("unknown severity category `%s`", category)
+			return
+		}
+	}
+	return
+}
+
+// Apply configures `crawler` according to the configuration.
+func (config *Config) Apply(crawler *Crawler) error {
+	for _, source := range config.Ignore {
+		pattern, err := ParsePattern(source)
+		if err != nil {
+			return err
+		}
+		crawler.Scope.Exclude = append(crawler.Scope.Exclude, pattern)
+	}
+
+	if config.Timeout > 0 {
+		crawler.Timeout = config.Timeout
+	}
+
+	if len(config.Domains) > 0 {
+		crawler.Domains = make(map[string]DomainPolicy, len(config.Domains))
+		for domain, policy := range config.Domains {
+			crawler.Domains[strings.ToLower(domain)] = policy
+		}
+	}
+
+	if config.Cookies != "" {
+		cookies, err := LoadCookieFile(config.Cookies)
+		if err != nil {
+			return err
+		}
+		crawler.Cookies = cookies
+	}
+
+	if len(config.Severity) > 0 {
+		crawler.Severities = config.Severity
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes `contents` to a configuration file in a temporary
+// directory and returns its path.
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), DefaultConfigFile)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+		wanted Config
+		err    string
+	}{
+		{name: "empty"},
+		{
+			name: "full",
+			config: `ignore:
+  - "http://localhost*"
+  - "re:^https://example\\.com/private/"
+timeout: 10s
+domains:
+  linkedin.com:
+    accept: [999]
+  docs.example.com:
+    headers:
+      X-Api-Key: key
+    auth:
+      bearer: "${DOCS_TOKEN}"
+    timeout: 30s
+  "*.intranet.example.com":
+    auth:
+      username: user
+      password: pass
+    insecureAuth: true
+severity:
+  redirect-permanent: info
+  fragment-missing: ignore
+`,
+			wanted: Config{
+				Ignore: []string{
+					"http://localhost*",
+					`re:^https://example\.com/private/`,
+				},
+				Timeout: 10 * time.Second,
+				Domains: map[string]DomainPolicy{
+					"linkedin.com": {Accept: []int{999}},
+					"docs.example.com": {
+						Headers: map[string]string{"X-Api-Key": "key"},
+						Auth:    &Credentials{Bearer: "${DOCS_TOKEN}"},
+						Timeout: 30 * time.Second,
+					},
+					"*.intranet.example.com": {
+						Auth: &Credentials{
+							Username: "user",
+							Password: "pass",
+						},
+						InsecureAuth: true,
+					},
+				},
+				Severity: map[string]Severity{
+					CategoryRedirectPermanent:    SeverityInfo,
+					string(ErrorFragmentMissing): SeverityIgnore,
+				},
+			},
+		},
+		{
+			name:   "absolute cookies",
+			config: "cookies: /etc/cookies.txt\n",
+			wanted: Config{Cookies: "/etc/cookies.txt"},
+		},
+		{
+			name:   "invalid timeout",
+			config: "timeout: soon\n",
+			err:    "soon",
+		},
+		{
+			name:   "both kinds of auth",
+			config: "domains:\n  a.com:\n    auth: {username: u, bearer: t}\n",
+			err:    "domain `a.com`: auth must be either",
+		},
+		{
+			name:   "empty auth",
+			config: "domains:\n  a.com:\n    auth: {}\n",
+			err:    "domain `a.com`: auth requires",
+		},
+		{
+			name:   "misplaced wildcard",
+			config: "domains:\n  docs.*.com:\n    accept: [999]\n",
+			err:    "domain `docs.*.com`: wildcards are only supported",
+		},
+		{
+			name:   "unknown severity category",
+			config: "severity:\n  redirect-sideways: info\n",
+			err:    "unknown severity category `redirect-sideways`",
+		},
+		{
+			name:   "invalid severity",
+			config: "severity:\n  redirect-long: fatal\n",
+			err:    "invalid severity `fatal`",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeConfig(t, tc.config)
+			config, err := LoadConfig(path)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("wanted error containing %q; got %v", tc.err, err)
+				}
+				if !strings.Contains(err.Error(), path) {
+					t.Fatalf("wanted the error to name the file; got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig(): unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(config, tc.wanted) {
+				t.Fatalf("wanted %+v; got %+v", tc.wanted, config)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMissing(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), DefaultConfigFile)
+	if _, err := LoadConfig(missing); err == nil {
+		t.Fatal("LoadConfig(): wanted an error for an explicit missing file")
+	}
+}
+
+func TestLoadConfigRelativeCookies(t *testing.T) {
+	path := writeConfig(t, "cookies: cookies.txt\n")
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig(): unexpected err: %v", err)
+	}
+	wanted := filepath.Join(filepath.Dir(path), "cookies.txt")
+	if config.Cookies != wanted {
+		t.Fatalf("wanted cookies `%s`; got `%s`", wanted, config.Cookies)
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	config := Config{
+		Ignore:   []string{"/private/*"},
+		Timeout:  10 * time.Second,
+		Domains:  map[string]DomainPolicy{"Example.COM": {}},
+		Severity: map[string]Severity{CategorySitemapOrphan: SeverityError},
+	}
+	crawler := NewCrawler("example.com")
+	if err := config.Apply(crawler); err != nil {
+		t.Fatalf("Apply(): unexpected err: %v", err)
+	}
+	if crawler.Scope.check(mustParse("https://example.com/private/page")) {
+		t.Fatal("wanted `/private/*` ignored")
+	}
+	if crawler.Timeout != config.Timeout {
+		t.Fatalf("wanted timeout %s; got %s", config.Timeout, crawler.Timeout)
+	}
+	if _, exists := crawler.Domains["example.com"]; !exists {
+		t.Fatalf("wanted domains keyed in lower case; got %v", crawler.Domains)
+	}
+	if !reflect.DeepEqual(crawler.Severities, config.Severity) {
+		t.Fatalf(
+			"wanted severities %v; got %v",
+			config.Severity,
+			crawler.Severities,
+		)
+	}
+
+	// an empty config leaves the crawler's defaults alone
+	crawler = NewCrawler("example.com")
+	if err := (&Config{}).Apply(crawler); err != nil {
+		t.Fatalf("Apply(): unexpected err: %v", err)
+	}
+	if crawler.Timeout != DefaultTimeout {
+		t.Fatalf("wanted timeout %s; got %s", DefaultTimeout, crawler.Timeout)
+	}
+
+	invalid := Config{Ignore: []string{"re:("}}
+	if err := invalid.Apply(NewCrawler("example.com")); err == nil {
+		t.Fatal("Apply(): wanted an error for an invalid pattern")
+	}
+}
+
+func TestDomainPolicy(t *testing.T) {
+	crawler := NewCrawler("example.com").
+		SetTimeout(10 * time.Second).
+		SetDomains(map[string]DomainPolicy{
+			"example.com": {
+				Accept:  []int{999},
+				Headers: map[string]string{"X-Api-Key": "key"},
+			},
+			"docs.example.com": {
+				Accept:  []int{403},
+				Timeout: 30 * time.Second,
+			},
+		})
+	for _, tc := range []struct {
+		host    string
+		accepts []int
+		timeout time.Duration
+
+		// headers is true if the policy's headers apply, which they only
+		// do to the exact host
+		headers bool
+	}{
+		{
+			host:    "example.com",
+			accepts: []int{999},
+			timeout: 10 * time.Second,
+			headers: true,
+		},
+		{
+			host:    "EXAMPLE.com:8080",
+			accepts: []int{999},
+			timeout: 10 * time.Second,
+			headers: true,
+		},
+		{
+			host:    "www.example.com",
+			accepts: []int{999},
+			timeout: 10 * time.Second,
+		},
+		{
+			// the longest matching domain wins
+			host:    "api.docs.example.com",
+			accepts: []int{403},
+			timeout: 30 * time.Second,
+		},
+		{host: "notexample.com", timeout: 10 * time.Second},
+		{host: "example.org", timeout: 10 * time.Second},
+	} {
+		t.Run(tc.host, func(t *testing.T) {
+			for _, statusCode := range []int{200, 403, 999} {
+				wanted := statusCode == 200
+				for _, accept := range tc.accepts {
+					wanted = wanted || accept == statusCode
+				}
+				if got := crawler.accepts(tc.host, statusCode); got != wanted {
+					t.Fatalf("%d: wanted accepted: %t", statusCode, wanted)
+				}
+			}
+			if got := crawler.timeout(tc.host); got != tc.timeout {
+				t.Fatalf("wanted timeout %s; got %s", tc.timeout, got)
+			}
+			policy := crawler.domainPolicy(tc.host)
+			headers := policy != nil && policy.Headers != nil
+			if headers != tc.headers {
+				t.Fatalf("wanted headers: %t; got %t", tc.headers, headers)
+			}
+		})
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	t.Setenv("LINKCHECK_TEST_TOKEN", "secret")
+	for _, tc := range []struct {
+		name          string
+		credentials   Credentials
+		authorization string
+	}{
+		{
+			name:          "bearer",
+			credentials:   Credentials{Bearer: "${LINKCHECK_TEST_TOKEN}"},
+			authorization: "Bearer secret",
+		},
+		{
+			name: "basic",
+			credentials: Credentials{
+				Username: "user",
+				Password: "$LINKCHECK_TEST_TOKEN",
+			},
+			// base64("user:secret")
+			authorization: "Basic dXNlcjpzZWNyZXQ=",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.credentials.validate(); err != nil {
+				t.Fatalf("validate(): unexpected err: %v", err)
+			}
+			req, err := http.NewRequest(http.MethodGet, "https://a.com/", nil)
+			if err != nil {
+				t.Fatalf("creating request: %v", err)
+			}
+			tc.credentials.apply(req)
+			if got := req.Header.Get("Authorization"); got != tc.authorization {
+				t.Fatalf("wanted %q; got %q", tc.authorization, got)
+			}
+		})
+	}
+}
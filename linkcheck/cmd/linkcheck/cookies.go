@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadCookieFile reads a Netscape (`cookies.txt`) cookie file, as exported
+// by browsers and written by curl, into a cookie jar. Cookies are only sent
+// to the hosts (and paths) they belong to.
+func LoadCookieFile(path string) (jar http.CookieJar, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("loading cookie file `%s`: %w", path, err)
+		}
+	}()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errors.Join(err, file.Close()) }()
+
+	cookies, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		u, cookie, ok, err := parseCookieLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ok {
+			cookies.SetCookies(u, []*http.Cookie{cookie})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// parseCookieLine parses a line of a Netscape cookie file, returning the
+// cookie and a URL it applies to. It returns false for blank lines,
+// comments, and expired cookies. The fields are tab-separated: the domain,
+// whether subdomains are included, the path, whether the cookie is secure,
+// the expiry (a Unix time, or 0 for a session cookie), the name, and the
+// value.
+func parseCookieLine(line string) (*url.URL, *http.Cookie, bool, error) {
+	httpOnly := false
+	if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+		line, httpOnly = rest, true
+	}
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+		return nil, nil, false, nil
+	}
+
+	fields := strings.Split(line, "\t")
+	if len(fields) != 7 {
+		return nil, nil, false, fmt.Errorf(
+			"expected 7 tab-separated fields; found %d",
+			len(fields),
+		)
+	}
+	domain, subdomains, path, secure := fields[0], fields[1], fields[2], fields[3]
+	expires, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("parsing expiry: %w", err)
+	}
+
+	cookie := http.Cookie{
+		Name:     fields[5],
+		Value:    fields[6],
+		Path:     path,
+		Secure:   strings.EqualFold(secure, "TRUE"),
+		HttpOnly: httpOnly,
+	}
+	if expires > 0 {
+		cookie.Expires = time.Unix(expires, 0)
+		if cookie.Expires.Before(time.Now()) {
+			return nil, nil, false, nil
+		}
+	}
+
+	// a domain cookie (which also applies to subdomains) has a `Domain`;
+	// a host-only cookie doesn't
+	host := strings.TrimPrefix(domain, ".")
+	if strings.EqualFold(subdomains, "TRUE") {
+		cookie.Domain = host
+	}
+	scheme := "http"
+	if cookie.Secure {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: host, Path: path}, &cookie, true, nil
+}
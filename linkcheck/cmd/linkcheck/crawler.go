@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -20,15 +24,138 @@ type Crawler struct {
 	Client   *http.Client
 	Host     string
 	Callback func(*Result)
-	seen     map[string]struct{}
+
+	// Concurrency is the maximum number of URLs fetched at once.
+	Concurrency int
+
+	// HostConcurrency is the maximum number of URLs fetched at once from any
+	// single host.
+	HostConcurrency int
+
+	// HostRateLimit is the maximum number of requests per second sent to any
+	// single host. Zero means unlimited.
+	HostRateLimit float64
+
+	// MaxRetries is the number of times a request is retried after a
+	// transient failure (429, 502, 503, 504, or a timeout).
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry. It doubles with each
+	// subsequent retry.
+	RetryBackoff time.Duration
+
+	// MaxRetryDelay caps the delay between retries. A `Retry-After` longer
+	// than this is not honored; the response is reported as-is instead.
+	MaxRetryDelay time.Duration
+
+	// Scope determines which URLs are part of the site (in addition to
+	// those on `Host`) and which are checked.
+	Scope Scope
+
+	// Timeout is the timeout for each request, including reading the
+	// response body (default [DefaultTimeout]). Zero means no timeout.
+	Timeout time.Duration
+
+	// OverallTimeout limits the duration of the whole crawl. Requests which
+	// haven't completed when it expires fail with a timeout. Zero means no
+	// limit.
+	OverallTimeout time.Duration
+
+	// HeadExternal checks URLs which aren't part of the site with HEAD
+	// requests rather than fetching them, unless a link requires the page's
+	// anchors. HEAD requests which are rejected are retried with GET.
+	HeadExternal bool
+
+	// CheckHTTPS checks whether the https version of each http target which
+	// is referenced insecurely (see [InsecureIssue]) responds, so that the
+	// references can be upgraded.
+	CheckHTTPS bool
+
+	// DetectSoft404 compares each page with its host's response to a URL
+	// which doesn't exist, reporting pages which match as soft 404s (see
+	// [Crawler.soft404]). Since pages must be fetched to be compared, it
+	// disables `HeadExternal`.
+	DetectSoft404 bool
+
+	// MaxBodySize is the maximum number of bytes of a page which are parsed.
+	// Links and anchors beyond it are ignored. Zero means no limit.
+	MaxBodySize int64
+
+	// PageCheckers inspect the content of each crawled page, e.g.,
+	// [DefaultPageCheckers].
+	PageCheckers []PageChecker
+
+	// Domains are per-domain policies keyed by domain; see
+	// [Crawler.domainPolicy].
+	Domains map[string]DomainPolicy
+
+	// Cookies, if set, is a cookie jar used for requests unless `Client`
+	// has its own.
+	Cookies http.CookieJar
+
+	// Severities overrides [DefaultSeverities] for categories of results.
+	Severities map[string]Severity
+
+	// Sitemap, if set, is the URL of a sitemap (or sitemap index) whose
+	// pages are crawled in addition to those reachable from the base URL.
+	// Pages in the sitemap which no crawled page links to, and crawled pages
+	// which are missing from the sitemap, are reported with a
+	// [SitemapIssue].
+	Sitemap *url.URL
+
+	// MaxRedirects is the number of redirects followed before giving up.
+	MaxRedirects int
+
+	// LongRedirectChain is the number of redirects beyond which a chain is
+	// reported as too long. Zero disables the check.
+	LongRedirectChain int
+
+	// IgnoreRobots disables robots.txt processing, e.g., for crawling our
+	// own sites. Otherwise, pages which robots.txt disallows for the
+	// `linkcheck` user agent are checked but not crawled, and Crawl-delay
+	// is honored.
+	IgnoreRobots bool
+
+	// Cache, if set, stores the outcome of checking external URLs between
+	// runs.
+	Cache *Cache
+
+	// mutex guards `seen`, `pages`, `hosts`, `robotsCache`, and
+	// `soft404Cache`.
+	mutex        sync.Mutex
+	seen         map[string]*target
+	pages        int
+	hosts        map[string]*hostState
+	robotsCache  map[string]*robotsEntry
+	soft404Cache map[string]*soft404Entry
+
+	// ctx is canceled when `OverallTimeout` expires.
+	ctx context.Context
+
+	// callbackMutex serializes calls to `Callback` so visitors needn't be
+	// safe for concurrent use.
+	callbackMutex sync.Mutex
 }
 
 func NewCrawler(host string) *Crawler {
 	return &Crawler{
-		Client:   http.DefaultClient,
-		Host:     host,
-		Callback: func(*Result) {},
-		seen:     map[string]struct{}{},
+		Client:            http.DefaultClient,
+		Host:              host,
+		Callback:          func(*Result) {},
+		Concurrency:       16,
+		HostConcurrency:   4,
+		MaxRetries:        3,
+		RetryBackoff:      time.Second,
+		MaxRetryDelay:     30 * time.Second,
+		MaxRedirects:      10,
+		LongRedirectChain: 3,
+		HeadExternal:      true,
+		MaxBodySize:       DefaultMaxBodySize,
+		Timeout:           DefaultTimeout,
+		seen:              map[string]*target{},
+		hosts:             map[string]*hostState{},
+		robotsCache:       map[string]*robotsEntry{},
+		soft404Cache:      map[string]*soft404Entry{},
 	}
 }
 
@@ -40,7 +167,10 @@ func (crawler *Crawler) SetVisitor(
 }
 
 func (crawler *Crawler) Reset() *Crawler {
-	crawler.seen = map[string]struct{}{}
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+	crawler.seen = map[string]*target{}
+	crawler.pages = 0
 	return crawler
 }
 
@@ -59,120 +189,686 @@ func (crawler *Crawler) SetCallback(callback func(*Result)) *Crawler {
 	return crawler
 }
 
+func (crawler *Crawler) SetConcurrency(concurrency int) *Crawler {
+	crawler.Concurrency = concurrency
+	return crawler
+}
+
+func (crawler *Crawler) SetHostConcurrency(concurrency int) *Crawler {
+	crawler.HostConcurrency = concurrency
+	return crawler
+}
+
+func (crawler *Crawler) SetHostRateLimit(requestsPerSecond float64) *Crawler {
+	crawler.HostRateLimit = requestsPerSecond
+	return crawler
+}
+
+func (crawler *Crawler) SetRetries(
+	maxRetries int,
+	backoff time.Duration,
+	maxDelay time.Duration,
+) *Crawler {
+	crawler.MaxRetries = maxRetries
+	crawler.RetryBackoff = backoff
+	crawler.MaxRetryDelay = maxDelay
+	return crawler
+}
+
+func (crawler *Crawler) SetRedirectLimits(
+	maxRedirects int,
+	longChain int,
+) *Crawler {
+	crawler.MaxRedirects = maxRedirects
+	crawler.LongRedirectChain = longChain
+	return crawler
+}
+
+func (crawler *Crawler) SetIgnoreRobots(ignore bool) *Crawler {
+	crawler.IgnoreRobots = ignore
+	return crawler
+}
+
+func (crawler *Crawler) SetScope(scope Scope) *Crawler {
+	crawler.Scope = scope
+	return crawler
+}
+
+func (crawler *Crawler) SetTimeout(timeout time.Duration) *Crawler {
+	crawler.Timeout = timeout
+	return crawler
+}
+
+func (crawler *Crawler) SetOverallTimeout(timeout time.Duration) *Crawler {
+	crawler.OverallTimeout = timeout
+	return crawler
+}
+
+func (crawler *Crawler) SetHeadExternal(head bool) *Crawler {
+	crawler.HeadExternal = head
+	return crawler
+}
+
+func (crawler *Crawler) SetCheckHTTPS(check bool) *Crawler {
+	crawler.CheckHTTPS = check
+	return crawler
+}
+
+func (crawler *Crawler) SetDetectSoft404(detect bool) *Crawler {
+	crawler.DetectSoft404 = detect
+	return crawler
+}
+
+func (crawler *Crawler) SetPageCheckers(checkers ...PageChecker) *Crawler {
+	crawler.PageCheckers = checkers
+	return crawler
+}
+
+func (crawler *Crawler) SetMaxBodySize(size int64) *Crawler {
+	crawler.MaxBodySize = size
+	return crawler
+}
+
+func (crawler *Crawler) SetDomains(domains map[string]DomainPolicy) *Crawler {
+	crawler.Domains = domains
+	return crawler
+}
+
+func (crawler *Crawler) SetCookies(cookies http.CookieJar) *Crawler {
+	crawler.Cookies = cookies
+	return crawler
+}
+
+func (crawler *Crawler) SetSeverities(severities map[string]Severity) *Crawler {
+	crawler.Severities = severities
+	return crawler
+}
+
+func (crawler *Crawler) SetSitemap(sitemap *url.URL) *Crawler {
+	crawler.Sitemap = sitemap
+	return crawler
+}
+
+func (crawler *Crawler) SetCache(cache *Cache) *Crawler {
+	crawler.Cache = cache
+	return crawler
+}
+
 func (crawler *Crawler) Seen(url *url.URL) bool {
-	_, seen := crawler.seen[url.String()]
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+	_, seen := crawler.seen[stripFragment(url).String()]
 	return seen
 }
 
+// target is a URL which is fetched once, no matter how many links point to
+// it.
+type target struct {
+	url *url.URL
+
+	// the remaining fields are guarded by `Crawler.mutex`
+	done     bool
+	err      error
+	attempts int
+	links    []link
+
+	// page is true if any hyperlink references the target, in which case
+	// it is crawled if it is part of the site. Targets which are only
+	// referenced as resources (images, scripts, etc.) are fetched but not
+	// crawled.
+	page bool
+
+	// anchors are the fragment identifiers defined by the target's HTML. It
+	// is nil if the target wasn't parsed as HTML, in which case fragments
+	// can't be validated.
+	anchors map[string]struct{}
+
+	// depth is the number of links between the base URL and the target.
+	depth int
+
+	// redirects are the redirects followed when fetching the target.
+	redirects []Redirect
+
+	// crawled is true if the target's links were queued.
+	crawled bool
+
+	// stylesheet is true if the target is a stylesheet whose references
+	// were queued.
+	stylesheet bool
+
+	// linked is true if a page (rather than the sitemap) references the
+	// target.
+	linked bool
+
+	// sitemap is the URL of the sitemap which lists the target, if any.
+	sitemap string
+
+	// cached is true if the outcome was read from `Cache` rather than
+	// fetched.
+	cached bool
+
+	// httpsAvailable is whether the https version of an http target
+	// responds, if `CheckHTTPS` is set. It is only probed, once (guarded by
+	// `httpsOnce` rather than `Crawler.mutex`), when a link which references
+	// the target insecurely is reported.
+	httpsOnce      sync.Once
+	httpsAvailable *bool
+
+	// soft404 is true if the target is probably a soft 404, if
+	// `DetectSoft404` is set.
+	soft404 bool
+
+	// anchorsUnknown is true if the target was checked without parsing it
+	// (with a HEAD request, or from a cached outcome without anchors), so
+	// that it must be fetched again for a link with a fragment.
+	anchorsUnknown bool
+
+	// refetch is true if the target is (or was) fetched again with a GET
+	// request because a link with a fragment arrived while it was being
+	// checked without its anchors. A target is only fetched again once.
+	refetch bool
+}
+
+// link is a reference from a page to a target.
+type link struct {
+	base      *url.URL
+	element   string
+	attribute string
+	text      string
+	href      string
+	fragment  string
+	page      bool
+
+	// sitemap is true if `base` is a sitemap rather than a page.
+	sitemap bool
+
+	// line is the line of `base` on which the link appears, if known.
+	line int
+
+	// depth is the number of links between the base URL and `base`.
+	depth int
+}
+
+// Crawl checks `base` and, if it is part of the site, every link reachable
+// from it. Links are fetched by a pool of `Concurrency` workers, and results
+// are passed to `Callback` one at a time as they complete. The returned error
+// is the error (if any) fetching `base` itself.
 func (crawler *Crawler) Crawl(base *url.URL) error {
-	base.Fragment = "" // strip fragments
-	if crawler.Seen(base) {
+	base = stripFragment(base)
+	queue := newWorkQueue()
+
+	var cancel func()
+	crawler.ctx, cancel = crawler.overallContext()
+	defer cancel()
+
+	var entries []sitemapEntry
+	complete := false
+	if crawler.Sitemap != nil {
+		entries, complete = crawler.readSitemap(crawler.Sitemap)
+	}
+
+	crawler.mutex.Lock()
+	if _, seen := crawler.seen[base.String()]; seen {
+		crawler.mutex.Unlock()
 		return nil
 	}
-	crawler.seen[base.String()] = struct{}{}
+	root := &target{url: base, page: true, linked: true}
+	crawler.seen[base.String()] = root
+	crawler.mutex.Unlock()
+	queue.push(root)
+	crawler.seedSitemap(queue, entries)
+	crawler.run(queue)
+
+	if crawler.Sitemap != nil {
+		crawler.reportSitemap(crawler.Sitemap, complete)
+	}
+	return root.err
+}
+
+// run processes the queue with a pool of `Concurrency` workers until every
+// target is done.
+func (crawler *Crawler) run(queue *workQueue) {
+	var wg sync.WaitGroup
+	for range max(crawler.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t, ok := queue.pop(); ok; t, ok = queue.pop() {
+				crawler.process(queue, t)
+				queue.done()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// process fetches `t`, reports the result to every link which references it,
+// and queues the links on its page (if it is part of the site).
+func (crawler *Crawler) process(queue *workQueue, t *target) {
+	var page fetched
+	var err error
+	var anchorsUnknown bool
+	for {
+		// a target which is fetched again needs its anchors, so it is
+		// fetched with a GET request
+		crawler.mutex.Lock()
+		head := crawler.head(t) && !t.refetch
+		fragments := hasFragments(t.links) || t.refetch
+		crawler.mutex.Unlock()
+
+		if entry, ok := crawler.cached(t.url); ok &&
+			(entry.HTML || entry.Error != nil || !fragments) {
+			page, err = entry.fetched(t.url)
+		} else {
+			page, err = crawler.fetch(t.url, head)
+			if page.crawl && page.url.Scheme != "file" &&
+				!crawler.IgnoreRobots {
+				page.crawl = crawler.robots(page.url).allowed(page.url)
+			}
+			if page.doc != nil {
+				page.anchors = anchors(page.doc)
+			}
+			if crawler.DetectSoft404 && err == nil {
+				page.soft404 = crawler.soft404(t.url, &page)
+			}
+			crawler.cache(t.url, page, err)
+		}
+		// the anchors of a target which was checked with a HEAD request
+		// (or whose cached outcome wasn't parsed) are unknown
+		anchorsUnknown = err == nil && page.anchors == nil &&
+			(head || page.cached)
+
+		crawler.mutex.Lock()
+		if !anchorsUnknown || t.refetch || !hasFragments(t.links) {
+			break
+		}
+		// a link with a fragment arrived while the target was being
+		// checked, so fetch it again (only once) to find its anchors
+		t.refetch = true
+		crawler.mutex.Unlock()
+	}
+	t.done = true
+	t.anchorsUnknown = anchorsUnknown
+	t.err = err
+	t.attempts = page.attempts
+	t.redirects = page.redirects
+	t.anchors = page.anchors
+	t.cached = page.cached
+	t.soft404 = page.soft404
+	crawl := page.crawl && t.page && page.doc != nil &&
+		(crawler.Scope.MaxDepth < 1 || t.depth <= crawler.Scope.MaxDepth) &&
+		(crawler.Scope.MaxPages < 1 || crawler.pages < crawler.Scope.MaxPages)
+	if crawl && len(page.redirects) > 0 {
+		// the page is crawled as its final URL, which several URLs may
+		// redirect to, so make sure it is only crawled once
+		crawl = crawler.claim(page.url, t)
+	} else {
+		t.crawled = crawl
+	}
+	if crawl {
+		crawler.pages++
+	}
+
+	// stylesheets are resources rather than pages, but the resources they
+	// reference (and import) are checked like those of a page
+	stylesheet := page.crawl && page.css != "" && !t.stylesheet &&
+		(crawler.Scope.MaxDepth < 1 || t.depth <= crawler.Scope.MaxDepth)
+	if stylesheet {
+		t.stylesheet = true
+	}
+	links := t.links
+	t.links = nil
+	crawler.mutex.Unlock()
+
+	for i := range links {
+		crawler.report(&links[i], t)
+	}
+
+	if crawl {
+		crawler.checkPage(page.url, page.doc)
+		crawler.queueLinks(queue, page.url, t.depth, references(page.doc))
+	}
+	if stylesheet {
+		crawler.queueLinks(
+			queue,
+			page.url,
+			t.depth,
+			stylesheetReferences(page.css),
+		)
+	}
+}
+
+// claim registers `finalURL`, which `t` redirected to, as a crawled target
+// unless it has already been seen. It returns false if it has. The caller
+// must hold `mutex`.
+func (crawler *Crawler) claim(finalURL *url.URL, t *target) bool {
+	finalURL = stripFragment(finalURL)
+	key := finalURL.String()
+	if _, seen := crawler.seen[key]; seen {
+		return false
+	}
+	crawler.seen[key] = &target{
+		url:     finalURL,
+		done:    true,
+		page:    true,
+		anchors: t.anchors,
+		depth:   t.depth,
+		crawled: true,
+		linked:  t.linked,
+	}
+	return true
+}
+
+// fetched is the outcome of fetching a target.
+type fetched struct {
+	// url is the final URL after following redirects.
+	url *url.URL
 
+	// doc is the parsed document, if it is HTML.
+	doc *goquery.Document
+
+	// crawl is true if the document's links should be crawled (unless
+	// robots.txt disallows it; see [Crawler.process]).
+	crawl bool
+
+	// attempts is the number of HTTP requests made.
+	attempts int
+
+	// redirects are the redirects followed to reach `url`.
+	redirects []Redirect
+
+	// css is the content of the stylesheet at `url`, if it is part of the
+	// site (so that its references are checked).
+	css string
+
+	// anchors are the fragment identifiers defined by `doc`; see
+	// `target.anchors`.
+	anchors map[string]struct{}
+
+	// cached is true if the outcome was read from `Cache`.
+	cached bool
+
+	// soft404 is true if the page is probably a soft 404.
+	soft404 bool
+}
+
+// fetch fetches `base`, following redirects, and parses it if it is HTML (or
+// reads it if it is a stylesheet which is part of the site). If `head` is
+// true, it is only checked with a HEAD request (if the server supports it).
+func (crawler *Crawler) fetch(
+	base *url.URL,
+	head bool,
+) (page fetched, err error) {
+	page.url = base
 	var body io.ReadCloser
+	var stylesheet bool
 	if base.Scheme == "file" {
-		var err error
-		if body, err = os.Open(base.Path); err != nil {
-			return fmt.Errorf("opening file:// url `%s`: %w", base, err)
+		page.crawl = crawler.Scope.crawl(base)
+		var file *os.File
+		if file, err = os.Open(base.Path); err != nil {
+			err = fmt.Errorf("opening file:// url `%s`: %w", base, err)
+			return
+		}
+		stylesheet = page.crawl && isCSSFile(base.Path)
+		if !stylesheet && !isHTMLFile(base.Path) {
+			if err = file.Close(); err != nil {
+				err = fmt.Errorf("closing file:// url `%s`: %w", base, err)
+			}
+			return
 		}
+		body = file
 	} else if base.Scheme == "http" || base.Scheme == "https" {
-		req, err := http.NewRequest("GET", base.String(), nil)
+		method := http.MethodGet
+		if head {
+			method = http.MethodHead
+		}
+		var req *http.Request
+		req, err = crawler.newRequest(method, base.String())
 		if err != nil {
-			return fmt.Errorf(
+			err = fmt.Errorf(
 				"checking links for url `%s`: preparing HTTP request: %w",
 				base,
 				err,
 			)
+			return
 		}
 
-		req.Header.Set("User-Agent", "linkcheck/1.0")
-
-		rsp, err := crawler.Client.Do(req)
-		if err != nil {
-			return fmt.Errorf("checking links for url `%s`: %w", base, err)
+		// fetch robots.txt before the page so that its Crawl-delay applies
+		internal := crawler.Scope.internal(crawler.Host, base)
+		if internal && !crawler.IgnoreRobots {
+			crawler.robots(base)
 		}
 
-		if rsp.StatusCode != http.StatusOK {
-			return ErrNotOk(rsp.StatusCode)
+		var rsp *http.Response
+		var release func()
+		rsp, release, page.redirects, page.attempts, err = crawler.send(req)
+		defer release()
+		if err != nil {
+			err = fmt.Errorf("checking links for url `%s`: %w", base, err)
+			return
 		}
 
-		// If the URL's host is not part of the site we're checking, then return
-		// early
-		if base.Host != crawler.Host {
-			return nil
+		// Only HTML is parsed (for its anchors and, if it's part of the
+		// site and robots.txt permits, its links), as are stylesheets which
+		// are part of the site. robots.txt is consulted by the caller, since
+		// fetching it may need the slot for the final URL's host, which is
+		// held until the body has been read.
+		page.url = rsp.Request.URL
+		page.crawl = crawler.Scope.internal(crawler.Host, page.url) &&
+			crawler.Scope.crawl(page.url)
+		stylesheet = page.crawl && isCSS(rsp)
+		if rsp.StatusCode != http.StatusOK ||
+			rsp.Request.Method == http.MethodHead ||
+			(!stylesheet && !isHTML(rsp)) {
+			if err = rsp.Body.Close(); err != nil {
+				err = fmt.Errorf(
+					"checking links for url `%s`: closing body: %w",
+					base,
+					err,
+				)
+				return
+			}
+			if rsp.StatusCode != http.StatusOK {
+				err = ErrNotOk(rsp.StatusCode)
+			}
+			return
 		}
-		body = rsp.Body
+		var truncated func() bool
+		body, truncated = crawler.limitBody(rsp.Body)
+		defer func() {
+			if truncated() {
+				slog.Warn(
+					"page exceeds the maximum body size; only its start "+
+						"was parsed",
+					"url", base,
+					"maxBodySize", crawler.MaxBodySize,
+				)
+			}
+		}()
 	} else {
 		// ignore links that are not of scheme file, http, or https (e.g.,
 		// ignore `mailto` links).
-		return nil
+		return
 	}
 
 	// closes body (so we don't keep open file handles while crawling interior
 	// links)
-	doc, err := readDoc(body)
-	if err != nil {
-		return fmt.Errorf("checking links for url `%s`: %w", base, err)
-	}
-	doc.Find("a[href]").Each(func(i int, a *goquery.Selection) {
-		href, exists := a.Attr("href")
-		if !exists {
-			html, err := a.Html()
-			if err != nil {
-				log.Printf("error rendering `%v` as HTML: %v", a, err)
-			}
-			log.Fatalf(
-				"program error: goquery selector should query only <a> tags "+
-					"with `href` attributes, but this tag is missing an "+
-					"href attribute: %s",
-				html,
-			)
+	if stylesheet {
+		if page.css, err = readStylesheet(body); err != nil {
+			err = fmt.Errorf("checking links for url `%s`: %w", base, err)
 		}
+		return
+	}
+	if page.doc, err = readDoc(body); err != nil {
+		err = fmt.Errorf("checking links for url `%s`: %w", base, err)
+	}
+	return
+}
 
+// queueLinks registers each of `refs`, which appear on `base`, with its
+// target, queueing targets which haven't been seen before. `depth` is the
+// depth of `base`.
+func (crawler *Crawler) queueLinks(
+	queue *workQueue,
+	base *url.URL,
+	depth int,
+	refs []reference,
+) {
+	for _, ref := range refs {
 		// correctly parses hrefs relative to `base`:
 		// * absolute urls: https://foo.com
 		// * relative urls: /baz ./bar ../../qux
-		target, err := base.Parse(href)
+		targetURL, err := base.Parse(strings.TrimSpace(ref.href))
 		if err != nil {
-			crawler.Callback(&Result{
-				BaseURL:       base.String(),
-				TargetText:    a.Text(),
-				TargetURL:     href,
-				URLParseError: err,
+			crawler.callback(&Result{
+				BaseURL:    base.String(),
+				Element:    ref.element,
+				Attribute:  ref.attribute,
+				TargetText: ref.text,
+				TargetURL:  ref.href,
+				Error:      parseError(err),
 			})
+			continue
+		}
+
+		crawler.link(queue, targetURL, link{
+			base:      base,
+			element:   ref.element,
+			attribute: ref.attribute,
+			text:      ref.text,
+			href:      ref.href,
+			fragment:  targetURL.Fragment,
+			page:      ref.page,
+			depth:     depth,
+		})
+	}
+}
+
+// link registers `l` as a reference to `targetURL`. If the target has
+// already been fetched, the result is reported immediately; otherwise it is
+// reported once the fetch completes. Targets which `Scope` excludes from
+// checking are ignored.
+func (crawler *Crawler) link(queue *workQueue, targetURL *url.URL, l link) {
+	targetURL = stripFragment(targetURL)
+	if !crawler.Scope.check(targetURL) {
+		return
+	}
+	key := targetURL.String()
+
+	crawler.mutex.Lock()
+	t, exists := crawler.seen[key]
+	refetch := false
+	if !exists {
+		t = &target{url: targetURL, page: l.page, depth: l.depth + 1}
+		crawler.seen[key] = t
+		queue.push(t)
+	} else if l.page && !t.page {
+		// the target was first referenced as a resource; if it has already
+		// been fetched, fetch it again so that it is crawled
+		t.page = true
+		t.depth = l.depth + 1
+		refetch = true
+	}
+	if l.fragment != "" && t.anchorsUnknown {
+		// the target was checked without finding its anchors, which the
+		// fragment requires, so fetch it again
+		t.anchorsUnknown = false
+		refetch = true
+	}
+	if refetch && t.done {
+		// links wait for the outcome of fetching it again
+		t.done = false
+		queue.push(t)
+	}
+	if l.sitemap {
+		if t.sitemap == "" {
+			t.sitemap = l.base.String()
+		}
+	} else {
+		t.linked = true
+	}
+	if !t.done {
+		t.links = append(t.links, l)
+		crawler.mutex.Unlock()
+		return
+	}
+	crawler.mutex.Unlock()
+
+	crawler.report(&l, t)
+}
+
+// report passes the result of fetching `t` via `l` to the callback. `t` must
+// be done.
+func (crawler *Crawler) report(l *link, t *target) {
+	result := Result{
+		BaseURL:    l.base.String(),
+		Element:    l.element,
+		Attribute:  l.attribute,
+		TargetText: l.text,
+		TargetURL:  l.href,
+		Attempts:   t.attempts,
+		Cached:     t.cached,
+		Line:       l.line,
+	}
+	crawler.setStatus(t, &result)
+	result.SoftNotFound = t.soft404
+	if t.err == nil && !hasAnchor(t.anchors, l.fragment) {
+		result.MissingFragment = l.fragment
+		result.Error = &ResultError{
+			Code:    ErrorFragmentMissing,
+			Message: fmt.Sprintf("missing anchor `#%s`", l.fragment),
+		}
+	}
+	result.InsecureIssue = crawler.insecureIssue(l, t)
+	if result.InsecureIssue != "" {
+		result.HTTPSAvailable = crawler.httpsAvailable(t)
+	}
+	result.Redirects = reportedRedirects(t.redirects)
+	result.RedirectIssue = crawler.redirectIssue(
+		t.url.String(),
+		result.Redirects,
+		t.err,
+	)
+	crawler.callback(&result)
+}
+
+// setStatus records the outcome of fetching `t` on `result`. `t` must be
+// done.
+func (crawler *Crawler) setStatus(t *target, result *Result) {
+	if t.err == nil {
+		result.StatusCode = http.StatusOK
+		return
+	}
+	if statusCode, ok := t.err.(ErrNotOk); ok {
+		result.StatusCode = int(statusCode)
+		if crawler.accepts(t.url.Host, int(statusCode)) {
 			return
 		}
+	}
+	result.Error = classify(t.err)
+}
 
-		if err := crawler.Crawl(target); err != nil {
-			if statusCode, ok := err.(ErrNotOk); ok {
-				crawler.Callback(&Result{
-					BaseURL:    base.String(),
-					TargetText: a.Text(),
-					TargetURL:  href,
-					StatusCode: int(statusCode),
-				})
-				return
-			}
-			crawler.Callback(&Result{
-				BaseURL:      base.String(),
-				TargetText:   a.Text(),
-				TargetURL:    href,
-				NetworkError: err,
-			})
+// callback assigns `result` a severity and passes it to `Callback` unless its
+// severity is [SeverityIgnore].
+func (crawler *Crawler) callback(result *Result) {
+	if category := category(result); category != "" {
+		result.Severity = crawler.severity(category)
+		if result.Severity == SeverityIgnore {
 			return
 		}
+	}
 
-		crawler.Callback(&Result{
-			BaseURL:    base.String(),
-			TargetText: a.Text(),
-			TargetURL:  href,
-			StatusCode: 200,
-		})
-	})
+	crawler.callbackMutex.Lock()
+	defer crawler.callbackMutex.Unlock()
+	crawler.Callback(result)
+}
 
-	return nil
+// stripFragment returns a copy of `u` without its fragment.
+func stripFragment(u *url.URL) *url.URL {
+	stripped := *u
+	stripped.Fragment = ""
+	stripped.RawFragment = ""
+	return &stripped
 }
 
 func readDoc(body io.ReadCloser) (*goquery.Document, error) {
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// crawlTimeout bounds each test crawl so that a deadlock fails the test
+// rather than hanging it.
+const crawlTimeout = 10 * time.Second
+
+// newSite starts a test server with the given handlers, keyed by path. Other
+// paths respond with 404.
+func newSite(
+	t *testing.T,
+	handlers map[string]http.HandlerFunc,
+) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	for path, handler := range handlers {
+		if path == "/" {
+			// match only the root rather than every path, so that
+			// unknown paths are not found
+			path = "/{$}"
+		}
+		mux.HandleFunc(path, handler)
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// html returns a handler which serves `body` as HTML.
+func html(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body))
+	}
+}
+
+// redirect returns a handler which redirects to `target`.
+func redirect(target string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target, http.StatusFound)
+	}
+}
+
+// newTestCrawler returns a crawler for the site served by `server` which
+// doesn't retry, so that failures are reported promptly.
+func newTestCrawler(server *httptest.Server) *Crawler {
+	return NewCrawler(mustParse(server.URL).Host).
+		SetRetries(0, time.Millisecond, time.Millisecond)
+}
+
+// crawl crawls `base` and returns the results keyed by target URL (as
+// written in the link), failing the test if the crawl doesn't finish within
+// `crawlTimeout`.
+func crawl(
+	t *testing.T,
+	crawler *Crawler,
+	base string,
+) (map[string][]*Result, error) {
+	t.Helper()
+	var mutex sync.Mutex
+	results := map[string][]*Result{}
+	crawler.SetCallback(func(result *Result) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		results[result.TargetURL] = append(results[result.TargetURL], result)
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- crawler.Crawl(mustParse(base)) }()
+	select {
+	case err := <-done:
+		return results, err
+	case <-time.After(crawlTimeout):
+		t.Fatalf("crawl of `%s` didn't finish: deadlock?", base)
+		return nil, nil
+	}
+}
+
+// only returns the single result for `href`, failing the test if there isn't
+// exactly one.
+func only(t *testing.T, results map[string][]*Result, href string) *Result {
+	t.Helper()
+	if len(results[href]) != 1 {
+		t.Fatalf(
+			"wanted one result for `%s`; found %d",
+			href,
+			len(results[href]),
+		)
+	}
+	return results[href][0]
+}
+
+func mustParse(s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func TestRedirectToInternalHostWithOneSlot(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		robots    string
+		wantCrawl bool
+	}{
+		{name: "allowed", robots: "User-agent: *\nAllow: /\n", wantCrawl: true},
+		{name: "disallowed", robots: "User-agent: *\nDisallow: /page\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newSite(t, map[string]http.HandlerFunc{
+				"/robots.txt": func(w http.ResponseWriter, _ *http.Request) {
+					w.Write([]byte(tc.robots))
+				},
+				"/page": html(`<a href="/leaf">leaf</a>`),
+				"/leaf": html(`leaf`),
+			})
+			a := newSite(t, map[string]http.HandlerFunc{
+				"/":  html(`<a href="/r">redirect</a>`),
+				"/r": redirect(b.URL + "/page"),
+			})
+
+			// the final hop of `/r` and robots.txt for its host share the
+			// single slot for B's host
+			crawler := newTestCrawler(a).
+				SetHostConcurrency(1).
+				SetScope(Scope{Hosts: []string{mustParse(b.URL).Host}})
+			results, err := crawl(t, crawler, a.URL+"/")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(results["/r"]) != 1 {
+				t.Fatalf("wanted one result for `/r`; found %v", results)
+			}
+			_, crawled := results["/leaf"]
+			if crawled != tc.wantCrawl {
+				t.Fatalf(
+					"wanted links on the redirect target crawled: %t; got %t",
+					tc.wantCrawl,
+					crawled,
+				)
+			}
+		})
+	}
+}
+
+func TestHostConcurrency(t *testing.T) {
+	const pages = 12
+	var inFlight, peak atomic.Int32
+	slow := func(w http.ResponseWriter, _ *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); {
+			p = peak.Load()
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("page"))
+	}
+
+	var links strings.Builder
+	handlers := map[string]http.HandlerFunc{}
+	for i := range pages {
+		path := fmt.Sprintf("/p%d", i)
+		fmt.Fprintf(&links, `<a href="%s">%d</a>`, path, i)
+		handlers[path] = slow
+	}
+	handlers["/"] = html(links.String())
+	server := newSite(t, handlers)
+
+	crawler := newTestCrawler(server).
+		SetConcurrency(pages).
+		SetHostConcurrency(2)
+	results, err := crawl(t, crawler, server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := range pages {
+		result := only(t, results, fmt.Sprintf("/p%d", i))
+		if result.Error != nil {
+			t.Fatalf("unexpected error for page %d: %v", i, result.Error)
+		}
+	}
+	if got := peak.Load(); got != 2 {
+		t.Fatalf("wanted at most (and at peak) 2 requests at once; got %d", got)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var flakyRequests atomic.Int32
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="/flaky">flaky</a>` +
+			`<a href="/down">down</a>` +
+			`<a href="/later">later</a>`),
+		"/flaky": func(w http.ResponseWriter, r *http.Request) {
+			if flakyRequests.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			html("ok")(w, r)
+		},
+		"/down": func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		},
+		"/later": func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		},
+	})
+
+	crawler := newTestCrawler(server).
+		SetRetries(3, time.Millisecond, time.Second)
+	results, err := crawl(t, crawler, server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []struct {
+		href       string
+		statusCode int
+		attempts   int
+	}{
+		// succeeds on the third attempt
+		{href: "/flaky", statusCode: http.StatusOK, attempts: 3},
+
+		// fails after the initial attempt and all three retries
+		{href: "/down", statusCode: http.StatusBadGateway, attempts: 4},
+
+		// asks us to wait longer than `MaxRetryDelay`, so it isn't retried
+		{href: "/later", statusCode: http.StatusTooManyRequests, attempts: 1},
+	} {
+		result := only(t, results, want.href)
+		if result.StatusCode != want.statusCode {
+			t.Fatalf(
+				"`%s`: wanted status %d; got %d",
+				want.href,
+				want.statusCode,
+				result.StatusCode,
+			)
+		}
+		if result.Attempts != want.attempts {
+			t.Fatalf(
+				"`%s`: wanted %d attempts; got %d",
+				want.href,
+				want.attempts,
+				result.Attempts,
+			)
+		}
+		if failed := result.Error != nil; failed != (want.statusCode != 200) {
+			t.Fatalf("`%s`: unexpected error: %v", want.href, result.Error)
+		}
+	}
+}
+
+func TestRetriesStopAtOverallTimeout(t *testing.T) {
+	var requests atomic.Int32
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="/down">down</a>`),
+		"/down": func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	})
+
+	// without the overall timeout, the retries would take at least 15s
+	crawler := newTestCrawler(server).
+		SetRetries(3, 10*time.Second, 10*time.Second).
+		SetOverallTimeout(200 * time.Millisecond)
+	start := time.Now()
+	results, err := crawl(t, crawler, server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("wanted the crawl to stop at the timeout; took %s", elapsed)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("wanted 1 request; got %d", got)
+	}
+	result := only(t, results, "/down")
+	if result.Error == nil || result.Error.Code != ErrorTimeout {
+		t.Fatalf("wanted a timeout; got %v", result.Error)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	if timeout := NewCrawler("example.com").Timeout; timeout != DefaultTimeout {
+		t.Fatalf(
+			"wanted a default timeout of %s; got %s",
+			DefaultTimeout,
+			timeout,
+		)
+	}
+
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="/hang">hang</a>`),
+		"/hang": func(_ http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		},
+	})
+	crawler := newTestCrawler(server).SetTimeout(100 * time.Millisecond)
+	results, err := crawl(t, crawler, server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := only(t, results, "/hang")
+	if result.Error == nil || result.Error.Code != ErrorTimeout {
+		t.Fatalf("wanted a timeout; got %v", result.Error)
+	}
+}
+
+func TestRobots(t *testing.T) {
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/robots.txt": func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte("User-agent: linkcheck\nDisallow: /private\n"))
+		},
+		"/": html(`<a href="/private">private</a>` +
+			`<a href="/public">public</a>`),
+		"/private": html(`<a href="/hidden">hidden</a>`),
+		"/public":  html(`<a href="/visible">visible</a>`),
+		"/hidden":  html("hidden"),
+		"/visible": html("visible"),
+	})
+
+	for _, tc := range []struct {
+		name       string
+		ignore     bool
+		wantHidden bool
+	}{
+		{name: "obeyed"},
+		{name: "ignored", ignore: true, wantHidden: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			crawler := newTestCrawler(server).SetIgnoreRobots(tc.ignore)
+			results, err := crawl(t, crawler, server.URL+"/")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// disallowed pages are still checked, but not crawled
+			for _, href := range []string{"/private", "/public", "/visible"} {
+				if result := only(t, results, href); result.Error != nil {
+					t.Fatalf("`%s`: unexpected error: %v", href, result.Error)
+				}
+			}
+			if _, found := results["/hidden"]; found != tc.wantHidden {
+				t.Fatalf(
+					"wanted links on a disallowed page crawled: %t; got %t",
+					tc.wantHidden,
+					found,
+				)
+			}
+		})
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="/moved">moved</a>` +
+			`<a href="/found">found</a>` +
+			`<a href="/chain">chain</a>` +
+			`<a href="/loop">loop</a>` +
+			`<a href="/gone">gone</a>`),
+		"/moved": func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		},
+		"/found":  redirect("/new"),
+		"/chain":  redirect("/chain1"),
+		"/chain1": redirect("/chain2"),
+		"/chain2": redirect("/chain3"),
+		"/chain3": redirect("/new"),
+		"/loop":   redirect("/loop1"),
+		"/loop1":  redirect("/loop"),
+		"/gone":   redirect("/missing"),
+		"/new":    html(`<a href="/child">child</a>`),
+		"/child":  html("child"),
+	})
+
+	crawler := newTestCrawler(server).SetRedirectLimits(10, 3)
+	results, err := crawl(t, crawler, server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []struct {
+		href       string
+		hops       int
+		issue      RedirectIssue
+		statusCode int
+	}{
+		{href: "/moved", hops: 1, issue: RedirectPermanent, statusCode: 200},
+		{href: "/found", hops: 1, statusCode: 200},
+		{href: "/chain", hops: 4, issue: RedirectLong, statusCode: 200},
+		{href: "/loop", hops: 2, issue: RedirectLoop},
+		{href: "/gone", hops: 1, statusCode: http.StatusNotFound},
+	} {
+		result := only(t, results, want.href)
+		if len(result.Redirects) != want.hops {
+			t.Fatalf(
+				"`%s`: wanted %d hops; got %v",
+				want.href,
+				want.hops,
+				result.Redirects,
+			)
+		}
+		if result.RedirectIssue != want.issue {
+			t.Fatalf(
+				"`%s`: wanted issue `%s`; got `%s`",
+				want.href,
+				want.issue,
+				result.RedirectIssue,
+			)
+		}
+		if result.StatusCode != want.statusCode {
+			t.Fatalf(
+				"`%s`: wanted status %d; got %d",
+				want.href,
+				want.statusCode,
+				result.StatusCode,
+			)
+		}
+	}
+
+	// `/new` is reached by three redirects, but only crawled once
+	only(t, results, "/child")
+}
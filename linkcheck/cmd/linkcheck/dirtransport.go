@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DirTransport serves a static site's build directory as though it were
+// deployed at `Base`, so that a site can be checked before it's published.
+// Requests for URLs under `Base` are served from the corresponding files in
+// `Root`, like a typical static host: a directory is served as its first
+// `Index` file, a missing `foo` is served from `foo.html` if it exists, and a
+// directory requested without a trailing slash is redirected to the URL with
+// one (which isn't reported, since it's the host's doing rather than the
+// site's). Other requests are sent via `Fallback`.
+type DirTransport struct {
+	Root     string
+	Base     string
+	Index    []string
+	Fallback http.RoundTripper
+}
+
+// NewDirTransport serves `root` as though it were deployed at `base`, e.g.,
+// `https://example.com/blog/`, with `index.html` as the index file.
+func NewDirTransport(root string, base string) *DirTransport {
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return &DirTransport{
+		Root:     root,
+		Base:     base,
+		Index:    []string{"index.html"},
+		Fallback: http.DefaultTransport,
+	}
+}
+
+// dirRedirectHeader marks the redirects [DirTransport] makes for directories
+// requested without a trailing slash.
+const dirRedirectHeader = "Linkcheck-Dir-Redirect"
+
+func (transport *DirTransport) RoundTrip(
+	req *http.Request,
+) (*http.Response, error) {
+	u := *req.URL
+	u.RawQuery, u.ForceQuery, u.Fragment, u.RawFragment = "", false, "", ""
+	rel, ok := strings.CutPrefix(u.String(), transport.Base)
+	if !ok && u.String()+"/" == transport.Base {
+		rel, ok = "", true
+	}
+	if !ok {
+		rsp, err := transport.Fallback.RoundTrip(req)
+		if rsp != nil {
+			// only our own redirects go unreported
+			rsp.Header.Del(dirRedirectHeader)
+		}
+		return rsp, err
+	}
+
+	// the path is relative to `Base` (and so is still escaped)
+	name, err := url.PathUnescape(rel)
+	if err != nil {
+		return respond(req, http.StatusBadRequest, nil), nil
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return respond(req, http.StatusMethodNotAllowed, nil), nil
+	}
+	return transport.serve(req, name)
+}
+
+// serve responds to `req` with the file at `name`, which is relative to
+// `Root`.
+func (transport *DirTransport) serve(
+	req *http.Request,
+	name string,
+) (*http.Response, error) {
+	file := filepath.Join(
+		transport.Root,
+		filepath.FromSlash(path.Clean("/"+name)),
+	)
+	info, err := os.Stat(file)
+	if errors.Is(err, fs.ErrNotExist) && name != "" &&
+		!strings.HasSuffix(name, "/") && path.Ext(name) != ".html" {
+		// static site generators often build `foo` as `foo.html`
+		if htmlInfo, htmlErr := os.Stat(file + ".html"); htmlErr == nil &&
+			!htmlInfo.IsDir() {
+			file, info, err = file+".html", htmlInfo, nil
+		}
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return respond(req, http.StatusNotFound, nil), nil
+		}
+		return nil, fmt.Errorf("serving `%s`: %w", file, err)
+	}
+
+	if info.IsDir() {
+		if name != "" && !strings.HasSuffix(name, "/") {
+			// relative links on the index resolve against the directory,
+			// so redirect like a static host would
+			location := *req.URL
+			location.Path += "/"
+			location.RawPath = ""
+			rsp := respond(req, http.StatusMovedPermanently, nil)
+			rsp.Header.Set("Location", location.String())
+			rsp.Header.Set(dirRedirectHeader, "true")
+			return rsp, nil
+		}
+		if file, info, err = transport.index(file); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return respond(req, http.StatusNotFound, nil), nil
+			}
+			return nil, fmt.Errorf("serving `%s`: %w", file, err)
+		}
+	}
+
+	body, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("serving `%s`: %w", file, err)
+	}
+	contentType, err := detectContentType(body)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"serving `%s`: %w",
+			file,
+			errors.Join(err, body.Close()),
+		)
+	}
+
+	var content io.ReadCloser = body
+	if req.Method == http.MethodHead {
+		if err := body.Close(); err != nil {
+			return nil, fmt.Errorf("serving `%s`: %w", file, err)
+		}
+		content = nil
+	}
+	rsp := respond(req, http.StatusOK, content)
+	rsp.Header.Set("Content-Type", contentType)
+	rsp.ContentLength = info.Size()
+	return rsp, nil
+}
+
+// index returns the path and info of the first of the `Index` files which
+// exists in `dir`, or an error satisfying [fs.ErrNotExist] if none does.
+func (transport *DirTransport) index(
+	dir string,
+) (string, fs.FileInfo, error) {
+	for _, index := range transport.Index {
+		file := filepath.Join(dir, index)
+		info, err := os.Stat(file)
+		if errors.Is(err, fs.ErrNotExist) || err == nil && info.IsDir() {
+			continue
+		}
+		return file, info, err
+	}
+	return dir, nil, fs.ErrNotExist
+}
+
+// detectContentType returns the content type of `file` from its extension or,
+// failing that, its contents. It leaves `file` at its beginning.
+func detectContentType(file *os.File) (string, error) {
+	if contentType := mime.TypeByExtension(
+		filepath.Ext(file.Name()),
+	); contentType != "" {
+		return contentType, nil
+	}
+
+	var buf [512]byte
+	n, err := io.ReadFull(file, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// respond returns a response to `req` with `body`, which may be nil.
+func respond(
+	req *http.Request,
+	statusCode int,
+	body io.ReadCloser,
+) *http.Response {
+	if body == nil {
+		body = http.NoBody
+	}
+	return &http.Response{
+		Status:     statusMessage(statusCode),
+		StatusCode: statusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       body,
+		Request:    req,
+	}
+}
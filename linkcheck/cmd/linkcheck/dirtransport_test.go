@@ -0,0 +1,244 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newBuildDir writes `files`, keyed by slash-separated path, to a temporary
+// directory and returns its path.
+func newBuildDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, contents := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("creating `%s`: %v", name, err)
+		}
+		if err := os.WriteFile(file, []byte(contents), 0644); err != nil {
+			t.Fatalf("writing `%s`: %v", name, err)
+		}
+	}
+	return root
+}
+
+func TestDirTransport(t *testing.T) {
+	root := newBuildDir(t, map[string]string{
+		"index.html":        "<p>home</p>",
+		"about.html":        "<p>about</p>",
+		"docs/index.html":   "<p>docs</p>",
+		"docs/intro.htm":    "<p>intro</p>",
+		"blog/default.html": "<p>blog</p>",
+		"empty/.keep":       "",
+		"notes.txt":         "notes",
+		"release-1.2.html":  "<p>release</p>",
+	})
+	fallback := newSite(t, map[string]http.HandlerFunc{
+		"/external": func(w http.ResponseWriter, _ *http.Request) {
+			// a fallback response can't pass itself off as ours
+			w.Header().Set(dirRedirectHeader, "true")
+			w.Write([]byte("external"))
+		},
+	})
+
+	for _, tc := range []struct {
+		name       string
+		index      []string
+		method     string
+		url        string
+		statusCode int
+		location   string
+		body       string
+	}{
+		{
+			name:       "root",
+			url:        "https://example.com/",
+			statusCode: http.StatusOK,
+			body:       "<p>home</p>",
+		},
+		{
+			name:       "root without a trailing slash",
+			url:        "https://example.com",
+			statusCode: http.StatusOK,
+			body:       "<p>home</p>",
+		},
+		{
+			name:       "file",
+			url:        "https://example.com/notes.txt",
+			statusCode: http.StatusOK,
+			body:       "notes",
+		},
+		{
+			name:       "query is ignored",
+			url:        "https://example.com/notes.txt?v=1#top",
+			statusCode: http.StatusOK,
+			body:       "notes",
+		},
+		{
+			name:       "directory index",
+			url:        "https://example.com/docs/",
+			statusCode: http.StatusOK,
+			body:       "<p>docs</p>",
+		},
+		{
+			name:       "directory without a trailing slash",
+			url:        "https://example.com/docs?v=1",
+			statusCode: http.StatusMovedPermanently,
+			location:   "https://example.com/docs/?v=1",
+		},
+		{
+			name:       "html fallback",
+			url:        "https://example.com/about",
+			statusCode: http.StatusOK,
+			body:       "<p>about</p>",
+		},
+		{
+			name:       "html fallback with a dot",
+			url:        "https://example.com/release-1.2",
+			statusCode: http.StatusOK,
+			body:       "<p>release</p>",
+		},
+		{
+			name:       "no html fallback for html",
+			url:        "https://example.com/missing.html",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "custom index",
+			index:      []string{"index.htm", "default.html"},
+			url:        "https://example.com/blog/",
+			statusCode: http.StatusOK,
+			body:       "<p>blog</p>",
+		},
+		{
+			name:       "custom index replaces the default",
+			index:      []string{"default.html"},
+			url:        "https://example.com/docs/",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "directory without an index",
+			url:        "https://example.com/empty/",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "missing",
+			url:        "https://example.com/missing",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "outside of the root",
+			url:        "https://example.com/../../etc/passwd",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "head",
+			method:     http.MethodHead,
+			url:        "https://example.com/notes.txt",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "method not allowed",
+			method:     http.MethodPost,
+			url:        "https://example.com/notes.txt",
+			statusCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "fallback",
+			url:        fallback.URL + "/external",
+			statusCode: http.StatusOK,
+			body:       "external",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := NewDirTransport(root, "https://example.com")
+			if tc.index != nil {
+				transport.Index = tc.index
+			}
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, err := http.NewRequest(method, tc.url, nil)
+			if err != nil {
+				t.Fatalf("creating request: %v", err)
+			}
+
+			rsp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip(): unexpected err: %v", err)
+			}
+			defer rsp.Body.Close()
+			body, err := io.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+
+			if rsp.StatusCode != tc.statusCode {
+				t.Fatalf(
+					"wanted status %d; got %d",
+					tc.statusCode,
+					rsp.StatusCode,
+				)
+			}
+			if got := rsp.Header.Get("Location"); got != tc.location {
+				t.Fatalf("wanted location %q; got %q", tc.location, got)
+			}
+			if string(body) != tc.body {
+				t.Fatalf("wanted body %q; got %q", tc.body, body)
+			}
+			implicit := rsp.Header.Get(dirRedirectHeader) != ""
+			if wanted := tc.location != ""; implicit != wanted {
+				t.Fatalf("wanted implicit redirect: %t", wanted)
+			}
+		})
+	}
+}
+
+func TestDirTransportCrawl(t *testing.T) {
+	root := newBuildDir(t, map[string]string{
+		"index.html": `<a href="/docs">docs</a>
+			<a href="/about">about</a>
+			<a href="/missing">missing</a>`,
+		"about.html":      "<p>about</p>",
+		"docs/index.html": `<a href="intro.html">intro</a>`,
+		"docs/intro.html": "<p>intro</p>",
+	})
+	crawler := NewCrawler("example.com").
+		SetRetries(0, 0, 0).
+		SetClient(&http.Client{
+			Transport: NewDirTransport(root, "https://example.com/"),
+		})
+	results, err := crawl(t, crawler, "https://example.com/")
+	if err != nil {
+		t.Fatalf("Crawl(): unexpected err: %v", err)
+	}
+
+	for _, target := range []string{"/docs", "/about", "intro.html"} {
+		result := only(t, results, target)
+		if result.Error != nil {
+			t.Fatalf("`%s`: unexpected error: %v", target, result.Error)
+		}
+		if len(result.Redirects) > 0 || result.RedirectIssue != "" {
+			t.Fatalf(
+				"`%s`: wanted no redirects reported; got %+v (%s)",
+				target,
+				result.Redirects,
+				result.RedirectIssue,
+			)
+		}
+	}
+
+	// the index's links resolve against the directory
+	if intro := only(t, results, "intro.html"); intro.BaseURL !=
+		"https://example.com/docs/" {
+		t.Fatalf("wanted `intro.html` on the docs index; got %+v", intro)
+	}
+
+	if result := only(t, results, "/missing"); result.Error == nil {
+		t.Fatal("`/missing`: wanted an error")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DomainPolicy customizes how URLs on a domain are checked; see
+// [Crawler.domainPolicy] for how policies are matched to hosts.
+type DomainPolicy struct {
+	// Accept are non-200 status codes which are treated as success, e.g.,
+	// 999 for hosts which reject crawlers.
+	Accept []int `yaml:"accept"`
+
+	// Headers are sent with every request to the domain.
+	Headers map[string]string `yaml:"headers"`
+
+	// Timeout, if set, overrides `Crawler.Timeout` for the domain.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Auth, if set, are credentials sent with every https request to the
+	// domain.
+	Auth *Credentials `yaml:"auth"`
+
+	// InsecureAuth sends `Auth` with plain http requests too, where they
+	// can be intercepted.
+	InsecureAuth bool `yaml:"insecureAuth"`
+}
+
+// Credentials authenticate requests via either HTTP basic authentication or
+// a bearer token. Environment variables (`$VAR` or `${VAR}`) in them are
+// expanded, so that secrets needn't be committed.
+type Credentials struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Bearer   string `yaml:"bearer"`
+}
+
+// validate returns an error unless exactly one kind of credentials is set.
+func (credentials *Credentials) validate() error {
+	basic := credentials.Username != "" || credentials.Password != ""
+	bearer := credentials.Bearer != ""
+	switch {
+	case basic && bearer:
+		return fmt.Errorf("auth must be either username/password or bearer")
+	case !basic && !bearer:
+		return fmt.Errorf("auth requires username/password or bearer")
+	}
+	return nil
+}
+
+// apply authenticates `req`.
+func (credentials *Credentials) apply(req *http.Request) {
+	if credentials.Bearer != "" {
+		req.Header.Set(
+			"Authorization",
+			"Bearer "+os.ExpandEnv(credentials.Bearer),
+		)
+		return
+	}
+	req.SetBasicAuth(
+		os.ExpandEnv(credentials.Username),
+		os.ExpandEnv(credentials.Password),
+	)
+}
+
+// domainPolicy returns the policy for `host`, or nil if there is none. A
+// policy keyed by `host` itself applies, as does one keyed by a wildcard for
+// one of its parents, e.g., `*.example.com`. A policy keyed by a parent
+// without a wildcard only contributes its `Accept` and `Timeout`, so that
+// headers and credentials are never sent to subdomains by accident. The
+// longest match wins.
+func (crawler *Crawler) domainPolicy(host string) *DomainPolicy {
+	if len(crawler.Domains) < 1 {
+		return nil
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(host)
+
+	if policy, exists := crawler.Domains[host]; exists {
+		return &policy
+	}
+	for {
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			return nil
+		}
+		host = parent
+		if policy, exists := crawler.Domains["*."+host]; exists {
+			return &policy
+		}
+		if policy, exists := crawler.Domains[host]; exists {
+			policy.Headers, policy.Auth = nil, nil
+			return &policy
+		}
+	}
+}
+
+// accepts returns true if `statusCode` from `host` is treated as success.
+func (crawler *Crawler) accepts(host string, statusCode int) bool {
+	if statusCode == 200 {
+		return true
+	}
+	policy := crawler.domainPolicy(host)
+	return policy != nil && slices.Contains(policy.Accept, statusCode)
+}
+
+// timeout returns the request timeout for `host`, or zero if there is none.
+func (crawler *Crawler) timeout(host string) time.Duration {
+	if policy := crawler.domainPolicy(host); policy != nil && policy.Timeout > 0 {
+		return policy.Timeout
+	}
+	return crawler.Timeout
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// sentCredentials are the credentials sent with a request.
+type sentCredentials struct {
+	authorization string
+	cookie        string
+}
+
+// recorder is a test server which records the credentials sent with each
+// request, keyed by path.
+type recorder struct {
+	*httptest.Server
+	mutex       sync.Mutex
+	credentials map[string][]sentCredentials
+}
+
+// newRecorder starts a recorder which responds with `handlers` (see
+// [newSite]).
+func newRecorder(
+	t *testing.T,
+	handlers map[string]http.HandlerFunc,
+) *recorder {
+	t.Helper()
+	r := unstartedRecorder(t, handlers)
+	r.Start()
+	return r
+}
+
+// newTLSRecorder starts a recorder like [newRecorder], but serving https.
+func newTLSRecorder(
+	t *testing.T,
+	handlers map[string]http.HandlerFunc,
+) *recorder {
+	t.Helper()
+	r := unstartedRecorder(t, handlers)
+	r.StartTLS()
+	return r
+}
+
+// unstartedRecorder returns a recorder which responds with `handlers`, which
+// is closed when the test finishes.
+func unstartedRecorder(
+	t *testing.T,
+	handlers map[string]http.HandlerFunc,
+) *recorder {
+	t.Helper()
+	r := recorder{credentials: map[string][]sentCredentials{}}
+	site := newSite(t, handlers)
+	r.Server = httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			r.mutex.Lock()
+			r.credentials[req.URL.Path] = append(
+				r.credentials[req.URL.Path],
+				sentCredentials{
+					authorization: req.Header.Get("Authorization"),
+					cookie:        req.Header.Get("Cookie"),
+				},
+			)
+			r.mutex.Unlock()
+			site.Config.Handler.ServeHTTP(w, req)
+		},
+	))
+	t.Cleanup(r.Close)
+	return &r
+}
+
+// hostsClient returns a client which connects to the servers in `hosts`,
+// keyed by hostname, so that they needn't resolve. Since the servers' test
+// certificates aren't issued for the hostnames, they aren't verified.
+func hostsClient(hosts map[string]*httptest.Server) *http.Client {
+	var dialer net.Dialer
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		DialContext: func(
+			ctx context.Context,
+			network string,
+			addr string,
+		) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			if server, exists := hosts[host]; exists {
+				addr = server.Listener.Addr().String()
+			}
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+func TestCredentialsStayOnTheirDomain(t *testing.T) {
+	other := newRecorder(t, map[string]http.HandlerFunc{
+		"/direct":  html("direct"),
+		"/landing": html("landing"),
+	})
+	site := newRecorder(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="/private">private</a>` +
+			`<a href="/to-other">to other</a>` +
+			`<a href="http://other.test/direct">direct</a>`),
+		"/private":  html("private"),
+		"/to-other": redirect("http://other.test/landing"),
+	})
+
+	cookiesPath := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := os.WriteFile(
+		cookiesPath,
+		[]byte("site.test\tFALSE\t/\tFALSE\t0\tsession\tsecret\n"),
+		0644,
+	); err != nil {
+		t.Fatalf("writing cookie file: %v", err)
+	}
+	cookies, err := LoadCookieFile(cookiesPath)
+	if err != nil {
+		t.Fatalf("LoadCookieFile(): unexpected err: %v", err)
+	}
+
+	crawler := NewCrawler("site.test").
+		SetRetries(0, 0, 0).
+		SetClient(hostsClient(map[string]*httptest.Server{
+			"site.test":  site.Server,
+			"other.test": other.Server,
+		})).
+		SetCookies(cookies).
+		SetDomains(map[string]DomainPolicy{
+			"site.test": {
+				Auth:         &Credentials{Bearer: "token"},
+				InsecureAuth: true,
+			},
+		})
+	results, err := crawl(t, crawler, "http://site.test/")
+	if err != nil {
+		t.Fatalf("Crawl(): unexpected err: %v", err)
+	}
+	for _, target := range []string{
+		"/private",
+		"/to-other",
+		"http://other.test/direct",
+	} {
+		if result := only(t, results, target); result.Error != nil {
+			t.Fatalf("`%s`: unexpected error: %v", target, result.Error)
+		}
+	}
+
+	credentials := sentCredentials{
+		authorization: "Bearer token",
+		cookie:        "session=secret",
+	}
+	for _, tc := range []struct {
+		name        string
+		server      *recorder
+		path        string
+		credentials sentCredentials
+	}{
+		{
+			name:        "site root",
+			server:      site,
+			path:        "/",
+			credentials: credentials,
+		},
+		{
+			name:        "site link",
+			server:      site,
+			path:        "/private",
+			credentials: credentials,
+		},
+		{
+			name:        "site redirect",
+			server:      site,
+			path:        "/to-other",
+			credentials: credentials,
+		},
+		{name: "other link", server: other, path: "/direct"},
+		{name: "redirect to other", server: other, path: "/landing"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assertCredentials(t, tc.server, tc.path, tc.credentials)
+		})
+	}
+
+	// nothing at all reaches the other host
+	other.mutex.Lock()
+	defer other.mutex.Unlock()
+	for path, sent := range other.credentials {
+		for _, got := range sent {
+			if got != (sentCredentials{}) {
+				t.Fatalf("`%s`: credentials leaked: %+v", path, got)
+			}
+		}
+	}
+}
+
+// assertCredentials fails the test unless every request `server` received
+// for `path` had `wanted` credentials.
+func assertCredentials(
+	t *testing.T,
+	server *recorder,
+	path string,
+	wanted sentCredentials,
+) {
+	t.Helper()
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	sent := server.credentials[path]
+	if len(sent) < 1 {
+		t.Fatalf("wanted a request for `%s`", path)
+	}
+	for _, got := range sent {
+		if got != wanted {
+			t.Fatalf("`%s`: wanted credentials %+v; got %+v", path, wanted, got)
+		}
+	}
+}
+
+func TestCredentialsOnlyOverHTTPS(t *testing.T) {
+	secure := newTLSRecorder(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="http://plain.test/page">plain</a>`),
+	})
+	plain := newRecorder(t, map[string]http.HandlerFunc{
+		"/page": html("page"),
+	})
+	credentials := &Credentials{Bearer: "token"}
+	crawler := NewCrawler("secure.test").
+		SetRetries(0, 0, 0).
+		SetClient(hostsClient(map[string]*httptest.Server{
+			"secure.test": secure.Server,
+			"plain.test":  plain.Server,
+		})).
+		SetDomains(map[string]DomainPolicy{
+			"secure.test": {Auth: credentials},
+			"plain.test":  {Auth: credentials},
+		})
+	if _, err := crawl(t, crawler, "https://secure.test/"); err != nil {
+		t.Fatalf("Crawl(): unexpected err: %v", err)
+	}
+
+	assertCredentials(
+		t,
+		secure,
+		"/",
+		sentCredentials{authorization: "Bearer token"},
+	)
+	assertCredentials(t, plain, "/page", sentCredentials{})
+}
+
+func TestCredentialsOnlyForMatchingHosts(t *testing.T) {
+	server := newRecorder(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="http://www.site.test/www">www</a>` +
+			`<a href="http://wild.test/apex">apex</a>` +
+			`<a href="http://a.wild.test/sub">sub</a>` +
+			`<a href="http://b.a.wild.test/nested">nested</a>` +
+			`<a href="http://a.b.site.test/exact">exact</a>`),
+		"/www":    html("www"),
+		"/apex":   html("apex"),
+		"/sub":    html("sub"),
+		"/nested": html("nested"),
+		"/exact":  html("exact"),
+	})
+	hosts := map[string]*httptest.Server{}
+	for _, host := range []string{
+		"site.test",
+		"www.site.test",
+		"wild.test",
+		"a.wild.test",
+		"b.a.wild.test",
+		"a.b.site.test",
+	} {
+		hosts[host] = server.Server
+	}
+	policy := func(token string) DomainPolicy {
+		return DomainPolicy{
+			Auth:         &Credentials{Bearer: token},
+			InsecureAuth: true,
+		}
+	}
+	crawler := NewCrawler("site.test").
+		SetRetries(0, 0, 0).
+		SetClient(hostsClient(hosts)).
+		SetDomains(map[string]DomainPolicy{
+			"site.test":     policy("site"),
+			"*.wild.test":   policy("wild"),
+			"a.b.site.test": policy("exact"),
+		})
+	if _, err := crawl(t, crawler, "http://site.test/"); err != nil {
+		t.Fatalf("Crawl(): unexpected err: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name          string
+		path          string
+		authorization string
+	}{
+		{name: "exact", path: "/", authorization: "Bearer site"},
+		{name: "subdomain without wildcard", path: "/www"},
+		{name: "wildcard parent", path: "/apex"},
+		{name: "wildcard", path: "/sub", authorization: "Bearer wild"},
+		{
+			name:          "nested wildcard",
+			path:          "/nested",
+			authorization: "Bearer wild",
+		},
+		{
+			name:          "exact subdomain",
+			path:          "/exact",
+			authorization: "Bearer exact",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assertCredentials(
+				t,
+				server,
+				tc.path,
+				sentCredentials{authorization: tc.authorization},
+			)
+		})
+	}
+}
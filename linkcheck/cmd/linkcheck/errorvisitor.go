@@ -1,9 +1,8 @@
 package main
 
-import "net/http"
-
 type ErrorVisitor struct {
-	inner ResultVisitor
+	inner       ResultVisitor
+	minSeverity Severity
 }
 
 func NewErrorsOnlyVisitor() *ErrorVisitor {
@@ -15,11 +14,17 @@ func (visitor *ErrorVisitor) SetInner(inner ResultVisitor) *ErrorVisitor {
 	return visitor
 }
 
+// SetMinSeverity restricts the visitor to problems at least as severe as
+// `severity`. By default, every problem is passed through.
+func (visitor *ErrorVisitor) SetMinSeverity(severity Severity) *ErrorVisitor {
+	visitor.minSeverity = severity
+	return visitor
+}
+
 func (visitor *ErrorVisitor) Visit(r *Result) {
 	if visitor.inner != nil &&
-		(r.URLParseError != nil ||
-			r.NetworkError != nil ||
-			r.StatusCode != http.StatusOK) {
+		r.Severity != "" &&
+		r.Severity.AtLeast(visitor.minSeverity) {
 		visitor.inner.Visit(r)
 	}
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// anchors returns the fragment identifiers defined by `doc`: the `id` of any
+// element and the `name` of any `<a>` element.
+func anchors(doc *goquery.Document) map[string]struct{} {
+	anchors := map[string]struct{}{}
+	doc.Find("[id], a[name]").Each(func(_ int, s *goquery.Selection) {
+		if id, exists := s.Attr("id"); exists && id != "" {
+			anchors[id] = struct{}{}
+		}
+		if goquery.NodeName(s) == "a" {
+			if name, exists := s.Attr("name"); exists && name != "" {
+				anchors[name] = struct{}{}
+			}
+		}
+	})
+	return anchors
+}
+
+// hasAnchor returns true if `fragment` identifies a location in a page with
+// the given `anchors`. Empty fragments, `#top`, and text fragments
+// (`#:~:text=...`) always resolve, as do fragments into pages which weren't
+// parsed (`anchors` is nil).
+func hasAnchor(anchors map[string]struct{}, fragment string) bool {
+	if anchors == nil ||
+		fragment == "" ||
+		strings.EqualFold(fragment, "top") ||
+		strings.HasPrefix(fragment, ":~:") {
+		return true
+	}
+	_, exists := anchors[fragment]
+	return exists
+}
+
+// hasFragments returns true if any of `links` has a fragment, which requires
+// the target's anchors.
+func hasFragments(links []link) bool {
+	return slices.ContainsFunc(links, func(l link) bool {
+		return l.fragment != ""
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFragments(t *testing.T) {
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<h1 id="intro">intro</h1>` +
+			`<a href="#intro">intro</a>` +
+			`<a href="#gone">gone</a>` +
+			`<a href="/doc#named">named</a>` +
+			`<a href="/doc#heading">heading</a>` +
+			`<a href="/doc#top">top</a>` +
+			`<a href="/doc#:~:text=doc">text fragment</a>` +
+			`<a href="/doc#missing">missing</a>`),
+		"/doc": html(`<a name="named">doc</a><h2 id="heading">doc</h2>`),
+	})
+
+	results, err := crawl(t, newTestCrawler(server), server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []struct {
+		href    string
+		missing string
+	}{
+		{href: "#intro"},
+		{href: "#gone", missing: "gone"},
+		{href: "/doc#named"},
+		{href: "/doc#heading"},
+		{href: "/doc#top"},
+		{href: "/doc#:~:text=doc"},
+		{href: "/doc#missing", missing: "missing"},
+	} {
+		assertFragment(t, results, want.href, want.missing)
+	}
+}
+
+// TestFragmentAfterHead checks that a link with a fragment to an external
+// page which was (or is being) checked with a HEAD request is validated
+// against the page's anchors, however the links are ordered.
+func TestFragmentAfterHead(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+
+		// pageDelay delays the page with the fragment link, so that it is
+		// found after the HEAD request completes
+		pageDelay time.Duration
+
+		// headDelay delays the HEAD request, so that the fragment link is
+		// found while it is in flight
+		headDelay time.Duration
+	}{
+		{name: "after", pageDelay: 200 * time.Millisecond},
+		{name: "during", headDelay: 200 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var gets atomic.Int32
+			external := newSite(t, map[string]http.HandlerFunc{
+				"/doc": func(w http.ResponseWriter, r *http.Request) {
+					if r.Method == http.MethodHead {
+						time.Sleep(tc.headDelay)
+					} else {
+						gets.Add(1)
+					}
+					html(`<h1 id="present">doc</h1>`)(w, r)
+				},
+			})
+			doc := external.URL + "/doc"
+			server := newSite(t, map[string]http.HandlerFunc{
+				"/": html(`<a href="` + doc + `">doc</a>` +
+					`<a href="/sub">sub</a>`),
+				"/sub": func(w http.ResponseWriter, r *http.Request) {
+					time.Sleep(tc.pageDelay)
+					html(`<a href="`+doc+`#missing">missing</a>`+
+						`<a href="`+doc+`#present">present</a>`)(w, r)
+				},
+			})
+
+			results, err := crawl(t, newTestCrawler(server), server.URL+"/")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertFragment(t, results, doc+"#missing", "missing")
+			assertFragment(t, results, doc+"#present", "")
+			if result := only(t, results, doc); result.Error != nil {
+				t.Fatalf("unexpected error: %v", result.Error)
+			}
+			// the page is fetched again for its anchors only once
+			if n := gets.Load(); n != 1 {
+				t.Fatalf("wanted 1 GET request for `%s`; found %d", doc, n)
+			}
+		})
+	}
+}
+
+// TestFragmentSkipsAnchorlessCache checks that a cached outcome without
+// anchors isn't used for a link with a fragment.
+func TestFragmentSkipsAnchorlessCache(t *testing.T) {
+	external := newSite(t, map[string]http.HandlerFunc{
+		"/doc": html(`<h1 id="present">doc</h1>`),
+	})
+	doc := external.URL + "/doc"
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="` + doc + `#missing">missing</a>`),
+	})
+
+	cache, err := LoadCache(
+		filepath.Join(t.TempDir(), "cache.json"),
+		time.Hour,
+		time.Hour,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cache.put(mustParse(doc), cacheEntry{Checked: time.Now()})
+
+	crawler := newTestCrawler(server).SetCache(cache)
+	results, err := crawl(t, crawler, server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := assertFragment(t, results, doc+"#missing", "missing")
+	if result.Cached {
+		t.Fatal("wanted the page to be fetched rather than read from the cache")
+	}
+}
+
+// assertFragment checks that the single result for `href` is missing the
+// fragment `missing` (or, if it is empty, isn't missing a fragment), and
+// returns it.
+func assertFragment(
+	t *testing.T,
+	results map[string][]*Result,
+	href string,
+	missing string,
+) *Result {
+	t.Helper()
+	result := only(t, results, href)
+	if result.MissingFragment != missing {
+		t.Fatalf(
+			"`%s`: wanted missing fragment `%s`; got `%s`",
+			href,
+			missing,
+			result.MissingFragment,
+		)
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GitHubResultPrinter prints problems as GitHub Actions workflow commands
+// (`::error`, `::warning`, and `::notice`) so that they show up as
+// annotations.
+type GitHubResultPrinter struct{}
+
+func (printer *GitHubResultPrinter) Visit(r *Result) {
+	var command string
+	switch r.Severity {
+	case SeverityError:
+		command = "error"
+	case SeverityWarning:
+		command = "warning"
+	case SeverityInfo:
+		command = "notice"
+	default:
+		return
+	}
+
+	// annotate the line of the source file if the result has one (see
+	// [Crawler.CheckFiles])
+	var file string
+	if r.Line > 0 {
+		file = fmt.Sprintf(
+			"file=%s,line=%d,",
+			escapeGitHubProperty(r.BaseURL),
+			r.Line,
+		)
+	}
+
+	_, message := describe(r)
+	fmt.Printf(
+		"::%s %stitle=%s::%s\n",
+		command,
+		file,
+		escapeGitHubProperty("linkcheck: "+category(r)),
+		escapeGitHubData(message),
+	)
+}
+
+// escapeGitHubData escapes a workflow command's message.
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer(
+		"%", "%25",
+		"\r", "%0D",
+		"\n", "%0A",
+	).Replace(s)
+}
+
+// escapeGitHubProperty escapes a workflow command's property value.
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer(
+		"%", "%25",
+		"\r", "%0D",
+		"\n", "%0A",
+		":", "%3A",
+		",", "%2C",
+	).Replace(s)
+}
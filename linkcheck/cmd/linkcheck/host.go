@@ -0,0 +1,85 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// hostState tracks the politeness state for a single host: how many requests may
+// be in flight at once and how far apart requests must start.
+type hostState struct {
+	slots chan struct{}
+
+	// mutex guards the fields below
+	mutex    sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newHostState(concurrency int, interval time.Duration) *hostState {
+	h := hostState{interval: interval}
+	if concurrency > 0 {
+		h.slots = make(chan struct{}, concurrency)
+	}
+	return &h
+}
+
+// acquire blocks until a request slot is available and the host's rate limit
+// permits another request, returning a function which releases the slot.
+func (h *hostState) acquire() (release func()) {
+	if h.slots != nil {
+		h.slots <- struct{}{}
+	}
+
+	h.mutex.Lock()
+	now := time.Now()
+	start := now
+	if h.next.After(now) {
+		start = h.next
+	}
+	h.next = start.Add(h.interval)
+	h.mutex.Unlock()
+	time.Sleep(time.Until(start))
+
+	return func() {
+		if h.slots != nil {
+			<-h.slots
+		}
+	}
+}
+
+// pause delays every subsequent request to the host until `until`.
+func (h *hostState) pause(until time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if until.After(h.next) {
+		h.next = until
+	}
+}
+
+// setMinInterval raises the minimum interval between requests to `interval`
+// if it is currently lower.
+func (h *hostState) setMinInterval(interval time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if interval > h.interval {
+		h.interval = interval
+	}
+}
+
+// hostState returns the politeness state for `name`, creating it if
+// necessary.
+func (crawler *Crawler) hostState(name string) *hostState {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+	h, exists := crawler.hosts[name]
+	if !exists {
+		var interval time.Duration
+		if crawler.HostRateLimit > 0 {
+			interval = time.Duration(float64(time.Second) / crawler.HostRateLimit)
+		}
+		h = newHostState(crawler.HostConcurrency, interval)
+		crawler.hosts[name] = h
+	}
+	return h
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// InsecureIssue is a problem with a reference over plain http.
+type InsecureIssue string
+
+const (
+	// InsecureMixedContent indicates that an https page loads a resource
+	// (an image, script, stylesheet, etc.) over http, which browsers block
+	// or warn about.
+	InsecureMixedContent InsecureIssue = "mixed-content"
+
+	// InsecureLink indicates a hyperlink to an http URL from an https page
+	// or to an external http URL.
+	InsecureLink InsecureIssue = "link"
+)
+
+// insecureIssue returns the issue (if any) with `l` referencing `t` over
+// plain http. Links between pages of a site which is itself served over http
+// aren't reported.
+func (crawler *Crawler) insecureIssue(l *link, t *target) InsecureIssue {
+	if t.url.Scheme != "http" {
+		return ""
+	}
+	secure := l.base.Scheme == "https"
+	switch {
+	case !l.page && secure:
+		return InsecureMixedContent
+	case l.page && (secure || !crawler.Scope.internal(crawler.Host, t.url)):
+		return InsecureLink
+	default:
+		return ""
+	}
+}
+
+// httpsAvailable returns whether the https version of the http target `t`
+// responds, probing it on first use, or nil unless `CheckHTTPS` is set.
+func (crawler *Crawler) httpsAvailable(t *target) *bool {
+	if !crawler.CheckHTTPS {
+		return nil
+	}
+	t.httpsOnce.Do(func() {
+		available := crawler.probeHTTPS(t.url)
+		t.httpsAvailable = &available
+	})
+	return t.httpsAvailable
+}
+
+// probeHTTPS returns true if the https version of the http URL `u`
+// responds successfully.
+func (crawler *Crawler) probeHTTPS(u *url.URL) bool {
+	secure := *u
+	secure.Scheme = "https"
+	req, err := crawler.newRequest(http.MethodHead, secure.String())
+	if err != nil {
+		return false
+	}
+	rsp, release, _, _, err := crawler.send(req)
+	defer release()
+	if err != nil {
+		return false
+	}
+	io.Copy(io.Discard, rsp.Body)
+	rsp.Body.Close()
+	return crawler.accepts(secure.Host, rsp.StatusCode)
+}
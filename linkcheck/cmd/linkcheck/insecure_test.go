@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// httpsTransport answers https requests itself, counting them, and sends the
+// rest to the test servers.
+type httpsTransport struct {
+	mutex  sync.Mutex
+	probes map[string]int
+}
+
+func (transport *httpsTransport) RoundTrip(
+	req *http.Request,
+) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	transport.mutex.Lock()
+	transport.probes[req.URL.String()]++
+	transport.mutex.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestCheckHTTPSOnlyProbesInsecureTargets(t *testing.T) {
+	external := newSite(t, map[string]http.HandlerFunc{
+		"/page": html("page"),
+	})
+	page := external.URL + "/page"
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="/internal">internal</a>` +
+			`<a href="/other">other</a>` +
+			`<a href="` + page + `">external</a>`),
+		"/internal": html("internal"),
+		"/other":    html(`<a href="` + page + `">external</a>`),
+	})
+
+	transport := &httpsTransport{probes: map[string]int{}}
+	crawler := newTestCrawler(server).
+		SetClient(&http.Client{Transport: transport}).
+		SetCheckHTTPS(true)
+	results, err := crawl(t, crawler, server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// links between the pages of an http site aren't insecure, so only
+	// the external page is probed, once
+	secure := "https" + strings.TrimPrefix(page, "http")
+	if len(transport.probes) != 1 || transport.probes[secure] != 1 {
+		t.Fatalf("wanted one probe of `%s`; got %v", secure, transport.probes)
+	}
+	if result := only(t, results, "/internal"); result.HTTPSAvailable != nil {
+		t.Fatalf("wanted no https check of `/internal`; got %+v", result)
+	}
+	if len(results[page]) != 2 {
+		t.Fatalf("wanted two results for `%s`; got %v", page, results[page])
+	}
+	for _, result := range results[page] {
+		if result.InsecureIssue != InsecureLink ||
+			result.HTTPSAvailable == nil || !*result.HTTPSAvailable {
+			t.Fatalf("wanted an available insecure link; got %+v", result)
+		}
+	}
+}
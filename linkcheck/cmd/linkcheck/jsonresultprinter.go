@@ -17,18 +17,26 @@ func (printer *JSONResultPrinter) SetIndent(indent bool) *JSONResultPrinter {
 func (printer *JSONResultPrinter) Visit(r *Result) { printer.Print(r) }
 
 func (printer *JSONResultPrinter) Print(r *Result) {
+	printer.print(r)
+}
+
+func (printer *JSONResultPrinter) PrintAggregate(aggregate *Aggregate) {
+	printer.print(aggregate)
+}
+
+func (printer *JSONResultPrinter) print(v any) {
 	var (
 		data []byte
 		err  error
 	)
 	if printer.indent {
-		data, err = json.MarshalIndent(r, "", "  ")
+		data, err = json.MarshalIndent(v, "", "  ")
 	} else {
-		data, err = json.Marshal(r)
+		data, err = json.Marshal(v)
 	}
 
 	if err != nil {
-		panic("cannot marshal Result type")
+		panic(fmt.Sprintf("cannot marshal %T type", v))
 	}
 
 	fmt.Printf("%s\n", data)
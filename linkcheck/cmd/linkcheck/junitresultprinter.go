@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"time"
+)
+
+// JUnitResultPrinter collects every result and prints them as a JUnit XML
+// report on `Flush`, with one test case per checked link. Errors are
+// failures; warnings and info are recorded in the test case's output.
+type JUnitResultPrinter struct {
+	start     time.Time
+	testCases []junitTestCase
+	failures  int
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func NewJUnitResultPrinter() *JUnitResultPrinter {
+	return &JUnitResultPrinter{start: time.Now()}
+}
+
+func (printer *JUnitResultPrinter) Visit(r *Result) {
+	testCase := junitTestCase{ClassName: r.BaseURL, Name: tag(r)}
+	if r.Severity != "" {
+		_, message := describe(r)
+		if r.Severity == SeverityError {
+			testCase.Failure = &junitFailure{
+				Type:    category(r),
+				Message: message,
+				Text:    message,
+			}
+			printer.failures++
+		} else {
+			testCase.SystemOut = fmt.Sprintf("%s: %s", r.Severity, message)
+		}
+	}
+	printer.testCases = append(printer.testCases, testCase)
+}
+
+// Flush prints the report.
+func (printer *JUnitResultPrinter) Flush() error {
+	report := junitTestSuites{TestSuites: []junitTestSuite{{
+		Name:      "linkcheck",
+		Tests:     len(printer.testCases),
+		Failures:  printer.failures,
+		Time:      fmt.Sprintf("%.3f", time.Since(printer.start).Seconds()),
+		Timestamp: printer.start.UTC().Format(time.RFC3339),
+		TestCases: printer.testCases,
+	}}}
+
+	if _, err := fmt.Print(xml.Header); err != nil {
+		return fmt.Errorf("printing JUnit report: %w", err)
+	}
+	encoder := xml.NewEncoder(os.Stdout)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("printing JUnit report: %w", err)
+	}
+	if _, err := fmt.Println(); err != nil {
+		return fmt.Errorf("printing JUnit report: %w", err)
+	}
+	return nil
+}
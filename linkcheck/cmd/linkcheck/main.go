@@ -1,10 +1,13 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -14,51 +17,463 @@ const (
 	errorCodeInvalidURL            = 2
 	errorCodeToplevelNetworkError  = 3
 	errorCodeFailuresDetected      = 4
+	errorCodeInvalidConfig         = 5
+	errorCodeInvalidFormat         = 6
+	errorCodeInvalidBaseline       = 7
+	errorCodeInvalidCache          = 8
 )
 
+// defaultDirURL is the URL at which `-dir` is served if no URL is given.
+const defaultDirURL = "http://localhost/"
+
+// Flusher is implemented by visitors which print their output once every
+// result has been visited.
+type Flusher interface {
+	Flush() error
+}
+
 func main() {
 	slog.SetLogLoggerLevel(slog.LevelDebug)
 	start := time.Now()
 	slog.Debug("starting", "time", start)
 	defer func() { slog.Debug("completed", "elapsed", time.Since(start)) }()
 
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "USAGE: linkcheck URL\n")
+	crawler := NewCrawler("")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE: linkcheck [FLAGS] URL\n")
+		fmt.Fprintf(os.Stderr, "       linkcheck [FLAGS] -dir DIR [URL]\n")
+		fmt.Fprintf(os.Stderr, "       linkcheck [FLAGS] -files [PATH...]\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(
+			os.Stderr,
+			"\nPatterns beginning with `re:` are regular expressions. "+
+				"Otherwise they are globs\n(`*` matches anything) which "+
+				"match the path if they begin with `/` and the\nwhole URL "+
+				"otherwise.\n",
+		)
+	}
+	flag.IntVar(
+		&crawler.Concurrency,
+		"concurrency",
+		crawler.Concurrency,
+		"the maximum number of URLs to fetch at once",
+	)
+	flag.IntVar(
+		&crawler.HostConcurrency,
+		"host-concurrency",
+		crawler.HostConcurrency,
+		"the maximum number of URLs to fetch at once from a single host",
+	)
+	flag.Float64Var(
+		&crawler.HostRateLimit,
+		"host-rate",
+		crawler.HostRateLimit,
+		"the maximum requests per second to a single host (0 is unlimited)",
+	)
+	flag.IntVar(
+		&crawler.MaxRetries,
+		"retries",
+		crawler.MaxRetries,
+		"the number of times to retry 429, 502, 503, 504, and timeouts",
+	)
+	flag.DurationVar(
+		&crawler.RetryBackoff,
+		"retry-backoff",
+		crawler.RetryBackoff,
+		"the delay before the first retry (doubles for each retry)",
+	)
+	flag.DurationVar(
+		&crawler.MaxRetryDelay,
+		"max-retry-delay",
+		crawler.MaxRetryDelay,
+		"the maximum delay between retries, including Retry-After",
+	)
+	flag.IntVar(
+		&crawler.MaxRedirects,
+		"max-redirects",
+		crawler.MaxRedirects,
+		"the number of redirects to follow before giving up",
+	)
+	flag.IntVar(
+		&crawler.LongRedirectChain,
+		"long-redirect-chain",
+		crawler.LongRedirectChain,
+		"report redirect chains with more hops than this (0 disables)",
+	)
+	flag.BoolVar(
+		&crawler.IgnoreRobots,
+		"ignore-robots",
+		false,
+		"ignore robots.txt (e.g., for crawling your own sites)",
+	)
+	flag.Var(
+		(*stringsFlag)(&crawler.Scope.Hosts),
+		"internal-host",
+		"also treat this host as part of the site (repeatable)",
+	)
+	flag.Var(
+		(*stringsFlag)(&crawler.Scope.Prefixes),
+		"internal-prefix",
+		"only treat paths with this prefix as part of the site (repeatable)",
+	)
+	flag.Var(
+		(*patternsFlag)(&crawler.Scope.Include),
+		"include",
+		"only check URLs matching this pattern (repeatable; see below)",
+	)
+	flag.Var(
+		(*patternsFlag)(&crawler.Scope.Exclude),
+		"exclude",
+		"don't check URLs matching this pattern (repeatable; see below)",
+	)
+	flag.Var(
+		(*patternsFlag)(&crawler.Scope.CrawlInclude),
+		"crawl-include",
+		"only follow links on pages matching this pattern (repeatable)",
+	)
+	flag.Var(
+		(*patternsFlag)(&crawler.Scope.CrawlExclude),
+		"crawl-exclude",
+		"don't follow links on pages matching this pattern (repeatable)",
+	)
+	flag.IntVar(
+		&crawler.Scope.MaxDepth,
+		"max-depth",
+		0,
+		"the maximum number of links from URL to a crawled page "+
+			"(0 is unlimited)",
+	)
+	flag.IntVar(
+		&crawler.Scope.MaxPages,
+		"max-pages",
+		0,
+		"the maximum number of pages to crawl (0 is unlimited)",
+	)
+	timeout := flag.Duration(
+		"timeout",
+		0,
+		"the timeout for each request, overriding the config file "+
+			"(default "+DefaultTimeout.String()+")",
+	)
+	flag.DurationVar(
+		&crawler.OverallTimeout,
+		"overall-timeout",
+		0,
+		"the maximum duration of the whole crawl (0 is unlimited)",
+	)
+	flag.BoolVar(
+		&crawler.HeadExternal,
+		"head",
+		crawler.HeadExternal,
+		"check external links with HEAD requests (falling back to GET on "+
+			"405 or 403) unless their anchors are needed",
+	)
+	flag.BoolVar(
+		&crawler.CheckHTTPS,
+		"check-https",
+		false,
+		"check whether the https version of each insecure link responds",
+	)
+	checkPages := flag.Bool(
+		"check-pages",
+		false,
+		"check the content of each crawled page: image alt text, link "+
+			"text, duplicate ids, title and meta description, and "+
+			"canonical links",
+	)
+	flag.BoolVar(
+		&crawler.DetectSoft404,
+		"soft-404",
+		false,
+		"report pages which resemble each host's response to a "+
+			"nonexistent URL (implies -head=false)",
+	)
+	flag.Int64Var(
+		&crawler.MaxBodySize,
+		"max-body-size",
+		crawler.MaxBodySize,
+		"the maximum number of bytes of a page to parse (0 is unlimited)",
+	)
+	cookiesPath := flag.String(
+		"cookies",
+		"",
+		"a Netscape cookie file (cookies.txt) whose cookies are sent to "+
+			"matching hosts, overriding the config file",
+	)
+	configPath := flag.String(
+		"config",
+		"",
+		"the configuration file to load (default `"+DefaultConfigFile+
+			"` in the working directory, if it exists)",
+	)
+	format := flag.String(
+		"format",
+		"auto",
+		"the output format: pretty, json, junit, sarif, github, or auto "+
+			"(pretty if stdout is a terminal, otherwise json)",
+	)
+	sitemap := flag.String(
+		"sitemap",
+		"",
+		"also crawl the pages in this sitemap (relative to URL, e.g., "+
+			"`/sitemap.xml`) and report pages missing from it or only "+
+			"reachable via it",
+	)
+	baselinePath := flag.String(
+		"baseline",
+		"",
+		"a file of JSON results from a previous run; links which were "+
+			"already broken are reported but only new breakages fail",
+	)
+	cachePath := flag.String(
+		"cache",
+		"",
+		"a file in which to cache the outcome of checking external links "+
+			"between runs",
+	)
+	cacheTTL := flag.Duration(
+		"cache-ttl",
+		24*time.Hour,
+		"how long to reuse successful outcomes from the cache",
+	)
+	cacheFailureTTL := flag.Duration(
+		"cache-failure-ttl",
+		time.Hour,
+		"how long to reuse failed outcomes from the cache",
+	)
+	dir := flag.String(
+		"dir",
+		"",
+		"check a static site's build directory as though it were deployed "+
+			"at URL (default "+defaultDirURL+")",
+	)
+	var indexes []string
+	flag.Var(
+		(*stringsFlag)(&indexes),
+		"index",
+		"the file to serve for a directory with -dir, tried in order "+
+			"(repeatable; default index.html)",
+	)
+	aggregate := flag.Bool(
+		"aggregate",
+		false,
+		"group problems by target URL, listing the pages which link to "+
+			"each (pretty and json formats only)",
+	)
+	files := flag.Bool(
+		"files",
+		false,
+		"check the links in source files (e.g., Markdown) in each PATH "+
+			"(default the working directory) rather than crawling a URL",
+	)
+	var extensions []string
+	flag.Var(
+		(*stringsFlag)(&extensions),
+		"ext",
+		"the extension of source files to check with -files, e.g., .md, "+
+			".rst, or .html (repeatable; default .md)",
+	)
+	flag.Parse()
+
+	base := flag.Arg(0)
+	if base == "" && *dir != "" {
+		base = defaultDirURL
+	}
+	if base == "" && !*files {
+		flag.Usage()
+		os.Exit(errorCodeInsufficientArguments)
+	}
+	if *files && (*dir != "" || *sitemap != "") {
+		fmt.Fprintf(os.Stderr, "-files can't be used with -dir or -sitemap\n")
 		os.Exit(errorCodeInsufficientArguments)
 	}
 
-	u, err := url.Parse(os.Args[1])
+	config, err := LoadConfig(*configPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "parsing base url: %v", err)
-		os.Exit(errorCodeInvalidURL)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(errorCodeInvalidConfig)
+	}
+	if err := config.Apply(crawler); err != nil {
+		fmt.Fprintf(os.Stderr, "applying config: %v\n", err)
+		os.Exit(errorCodeInvalidConfig)
+	}
+	if *timeout > 0 {
+		crawler.SetTimeout(*timeout)
+	}
+	if *cookiesPath != "" {
+		cookies, err := LoadCookieFile(*cookiesPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(errorCodeInvalidConfig)
+		}
+		crawler.SetCookies(cookies)
+	}
+	if *checkPages {
+		crawler.SetPageCheckers(DefaultPageCheckers...)
+	}
+
+	var u *url.URL
+	if !*files {
+		if u, err = url.Parse(base); err != nil {
+			fmt.Fprintf(os.Stderr, "parsing base url: %v", err)
+			os.Exit(errorCodeInvalidURL)
+		}
+		crawler.SetHost(u.Host)
+	}
+	if *dir != "" {
+		transport := NewDirTransport(*dir, u.String())
+		if len(indexes) > 0 {
+			transport.Index = indexes
+		}
+		crawler.SetClient(&http.Client{Transport: transport})
+	}
+	if *sitemap != "" {
+		sitemapURL, err := u.Parse(*sitemap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "parsing sitemap url: %v", err)
+			os.Exit(errorCodeInvalidURL)
+		}
+		crawler.SetSitemap(sitemapURL)
 	}
 
-	crawler := NewCrawler(u.Host)
+	if *cachePath != "" {
+		cache, err := LoadCache(*cachePath, *cacheTTL, *cacheFailureTTL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(errorCodeInvalidCache)
+		}
+		crawler.SetCache(cache)
+	}
 
 	// Prepare the visitor.
+	if *format == "auto" {
+		if isTTY() {
+			*format = "pretty"
+		} else {
+			*format = "json"
+		}
+	}
+	var printer ResultVisitor
+	switch *format {
+	case "pretty":
+		// print everything
+		printer = new(PrettyResultPrinter)
+	case "json":
+		// only print problems
+		printer = new(ErrorVisitor).SetInner(new(JSONResultPrinter))
+	case "junit":
+		// print a test case for every link
+		printer = NewJUnitResultPrinter()
+	case "sarif":
+		printer = new(SARIFResultPrinter)
+	case "github":
+		printer = new(GitHubResultPrinter)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown output format: %s\n", *format)
+		flag.Usage()
+		os.Exit(errorCodeInvalidFormat)
+	}
+	if *aggregate {
+		var aggregatePrinter AggregatePrinter
+		switch *format {
+		case "pretty":
+			aggregatePrinter = new(PrettyResultPrinter)
+		case "json":
+			aggregatePrinter = new(JSONResultPrinter)
+		default:
+			fmt.Fprintf(
+				os.Stderr,
+				"-aggregate isn't supported by the %s format\n",
+				*format,
+			)
+			os.Exit(errorCodeInvalidFormat)
+		}
+		printer = NewAggregateVisitor().SetPrinter(aggregatePrinter)
+	}
 	errorCounter := new(CountVisitor)
-	if isTTY() {
-		// If we're a TTY, print everything but also count errors
-		crawler.SetVisitor(NewMultiVisitor(
-			new(PrettyResultPrinter),
-			new(ErrorVisitor).SetInner(errorCounter),
-		))
-	} else {
-		// If we're not a TTY, then count errors and only print them
-		crawler.SetVisitor(new(ErrorVisitor).SetInner(NewMultiVisitor(
-			errorCounter,
-			new(JSONResultPrinter),
-		)))
+	var visitor ResultVisitor = NewMultiVisitor(
+		printer,
+		new(ErrorVisitor).SetMinSeverity(SeverityError).SetInner(errorCounter),
+	)
+	var baselineVisitor *BaselineVisitor
+	if *baselinePath != "" {
+		baseline, err := LoadBaseline(*baselinePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(errorCodeInvalidBaseline)
+		}
+		baselineVisitor = NewBaselineVisitor(baseline).SetInner(visitor)
+		visitor = baselineVisitor
 	}
+	crawler.SetVisitor(visitor)
 
-	if err := crawler.Crawl(u); err != nil {
-		fmt.Fprintf(os.Stderr, "toplevel network error: %v", err)
+	var crawlErr error
+	if *files {
+		paths := flag.Args()
+		if len(paths) < 1 {
+			paths = []string{"."}
+		}
+		if len(extensions) < 1 {
+			extensions = DefaultSourceExtensions
+		}
+		crawlErr = crawler.CheckFiles(paths, extensions)
+	} else {
+		crawlErr = crawler.Crawl(u)
+	}
+	if crawler.Cache != nil {
+		if err := crawler.Cache.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+	if baselineVisitor != nil {
+		// report fixed links before the printer flushes
+		if err := baselineVisitor.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+	if flusher, ok := printer.(Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+	if baselineVisitor != nil {
+		fmt.Fprintln(os.Stderr, baselineVisitor.Summary())
+	}
+	if crawlErr != nil {
+		fmt.Fprintf(os.Stderr, "toplevel network error: %v", crawlErr)
 		os.Exit(errorCodeToplevelNetworkError)
 	}
-
 	if errorCount := errorCounter.GetCount(); errorCount > 0 {
 		os.Exit(errorCodeFailuresDetected)
 	}
 
 	os.Exit(errorCodeOK)
 }
+
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+type patternsFlag []Pattern
+
+func (f *patternsFlag) String() string {
+	sources := make([]string, len(*f))
+	for i, pattern := range *f {
+		sources[i] = pattern.String()
+	}
+	return strings.Join(sources, ",")
+}
+
+func (f *patternsFlag) Set(value string) error {
+	pattern, err := ParsePattern(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, pattern)
+	return nil
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	markdownFence = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+
+	markdownATXHeading = regexp.MustCompile(
+		`^ {0,3}#{1,6}(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`,
+	)
+
+	markdownSetextUnderline = regexp.MustCompile(`^ {0,3}(?:=+|-+)[ \t]*$`)
+
+	// markdownListItem matches the marker of a list item and the spaces
+	// after it, e.g., `- ` or `1. `.
+	markdownListItem = regexp.MustCompile(
+		`^[ \t]*(?:[-+*]|\d{1,9}[.)])(?:[ \t]+|$)`,
+	)
+
+	// markdownDefinition matches a link reference definition, e.g.,
+	// `[label]: https://example.com "Title"`.
+	markdownDefinition = regexp.MustCompile(
+		`^ {0,3}\[([^\]]+)\]:[ \t]*(?:<([^>]*)>|(\S+))`,
+	)
+
+	// markdownReferenceLink matches a full or collapsed reference link or
+	// image, e.g., `[text][label]` or `[label][]`.
+	markdownReferenceLink = regexp.MustCompile(
+		`(!?)\[([^\[\]]*)\]\[([^\[\]]*)\]`,
+	)
+
+	// markdownAutolink matches an autolink, e.g., `<https://example.com>`.
+	markdownAutolink = regexp.MustCompile(
+		`<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^<>\s]*)>`,
+	)
+
+	// markdownLinkMarkup matches inline links and images in link text and
+	// headings, so that they can be replaced with their text.
+	markdownLinkMarkup = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+
+	bareURL = regexp.MustCompile(`https?://[^\s<>]+`)
+
+	htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+	htmlReference = regexp.MustCompile(
+		`(?i)<(a|img)\b[^>]*?\b(href|src)\s*=\s*(?:"([^"]*)"|'([^']*)')`,
+	)
+
+	htmlAnchor = regexp.MustCompile(
+		`(?i)<[a-z][^>]*?\b(?:id|name)\s*=\s*(?:"([^"]*)"|'([^']*)')`,
+	)
+)
+
+// parseMarkdown returns the links in a Markdown document (inline links and
+// images, link reference definitions, autolinks, bare URLs, and HTML `<a>` and
+// `<img>` elements) and its anchors: the GitHub-style slugs of its headings
+// and the `id`s and `name`s of its HTML elements. Reference links and images
+// whose labels aren't defined are returned as broken links. Code blocks and
+// code spans are ignored.
+func parseMarkdown(content string) *sourceFile {
+	source := sourceFile{anchors: map[string]struct{}{}}
+	slugs := slugger{}
+	definitions := map[string]struct{}{}
+	var usages []sourceLink
+	lines := strings.Split(content, "\n")
+
+	start := 0
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		// skip front matter
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	// scan finds the anchors and links in `text`, a block of lines beginning
+	// at `firstLine`
+	scan := func(text string, firstLine int) {
+		text = blankCodeSpans(text)
+		for _, match := range htmlAnchor.FindAllStringSubmatch(text, -1) {
+			source.anchors[match[1]+match[2]] = struct{}{}
+		}
+		if match := markdownDefinition.FindStringSubmatch(text); match != nil {
+			definitions[markdownLabel(match[1])] = struct{}{}
+		} else {
+			usages = append(usages, markdownReferences(text, firstLine)...)
+		}
+		source.links = append(
+			source.links,
+			markdownLinks(text, firstLine)...,
+		)
+	}
+
+	// the lines of a paragraph are scanned together, since links may wrap
+	// onto the next line
+	var paragraph []string
+	paragraphLine := 0
+	flush := func() {
+		if len(paragraph) > 0 {
+			scan(strings.Join(paragraph, "\n"), paragraphLine)
+			paragraph = nil
+		}
+	}
+
+	// listIndent is the indentation of the content of the current list
+	// item, if any, which code blocks in the item are indented beyond
+	fence, listIndent := "", 0
+	for i := start; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		lineNumber := i + 1
+
+		if match := markdownFence.FindStringSubmatch(line); match != nil {
+			switch {
+			case fence == "":
+				flush()
+				fence = match[1]
+			case strings.HasPrefix(match[1], fence[:1]) &&
+				len(match[1]) >= len(fence):
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+			continue
+		case len(paragraph) < 1 && markdownIndent(line) >= listIndent+4:
+			// an indented code block, which can't interrupt a paragraph
+			continue
+		case markdownSetextUnderline.MatchString(line) && len(paragraph) > 0:
+			heading := strings.Join(paragraph, "\n")
+			source.anchors[slugs.slug(heading)] = struct{}{}
+			flush()
+			continue
+		case markdownATXHeading.MatchString(line):
+			flush()
+			heading := markdownATXHeading.FindStringSubmatch(line)[1]
+			source.anchors[slugs.slug(heading)] = struct{}{}
+			scan(line, lineNumber)
+			continue
+		case markdownDefinition.MatchString(line):
+			flush()
+			scan(line, lineNumber)
+			continue
+		}
+
+		if match := markdownListItem.FindString(line); match != "" {
+			flush()
+			listIndent = markdownIndent(match)
+		} else if len(paragraph) < 1 && markdownIndent(line) < listIndent {
+			listIndent = 0
+		}
+		if len(paragraph) < 1 {
+			paragraphLine = lineNumber
+		}
+		paragraph = append(paragraph, line)
+	}
+	flush()
+
+	// definitions may follow their usages, so labels are only resolved once
+	// the whole document has been read
+	for _, usage := range usages {
+		if _, defined := definitions[markdownLabel(usage.href)]; !defined {
+			source.links = append(source.links, usage)
+		}
+	}
+	slices.SortStableFunc(source.links, func(l, r sourceLink) int {
+		return l.line - r.line
+	})
+	return &source
+}
+
+// markdownReferences returns the full and collapsed reference links and
+// images in lines of Markdown beginning at `firstLine` whose code spans have
+// been blanked, with their labels as their hrefs and an error in case the
+// labels aren't defined.
+func markdownReferences(block string, firstLine int) []sourceLink {
+	// an inline link's text may look like a reference, e.g., `[[a][b]](c)`
+	for _, l := range markdownInlineLinks(block) {
+		block = blank(block, l.start, l.end)
+	}
+
+	var links []sourceLink
+	matches := markdownReferenceLink.FindAllStringSubmatchIndex(block, -1)
+	for _, match := range matches {
+		text, label := block[match[4]:match[5]], block[match[6]:match[7]]
+		if label == "" {
+			label = text
+		}
+		// a label may wrap onto the next line
+		label = strings.Join(strings.Fields(label), " ")
+		if label == "" {
+			continue
+		}
+		l := sourceLink{
+			line:      lineOf(block, match[0], firstLine),
+			element:   "a",
+			attribute: "href",
+			text:      markdownText(text),
+			href:      label,
+			err: &ResultError{
+				Code:    ErrorUndefinedReference,
+				Message: fmt.Sprintf("undefined reference `%s`", label),
+			},
+		}
+		if match[3] > match[2] {
+			l.element, l.attribute = "img", "src"
+		}
+		links = append(links, l)
+	}
+	return links
+}
+
+// markdownLabel normalizes a reference label for matching: labels are
+// case-insensitive, and runs of whitespace are equivalent.
+func markdownLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// markdownLinks returns the links in lines of Markdown beginning at
+// `firstLine` whose code spans have been blanked.
+func markdownLinks(block string, firstLine int) []sourceLink {
+	var links []sourceLink
+	add := func(start int, element, attribute, text, href string) {
+		links = append(links, sourceLink{
+			line:      lineOf(block, start, firstLine),
+			element:   element,
+			attribute: attribute,
+			text:      markdownText(text),
+			href:      href,
+		})
+	}
+
+	if match := markdownDefinition.FindStringSubmatch(block); match != nil {
+		add(0, "a", "href", match[1], match[2]+match[3])
+		return links
+	}
+
+	// each kind of link is blanked once it's found so that its URL isn't
+	// found again, e.g., as a bare URL
+	for _, l := range markdownInlineLinks(block) {
+		if l.image {
+			add(l.start, "img", "src", l.text, l.href)
+		} else {
+			add(l.start, "a", "href", l.text, l.href)
+		}
+		block = blank(block, l.start, l.end)
+	}
+	for _, match := range htmlReference.FindAllStringSubmatchIndex(block, -1) {
+		element := strings.ToLower(block[match[2]:match[3]])
+		attribute := strings.ToLower(block[match[4]:match[5]])
+		href := submatch(block, match, 3) + submatch(block, match, 4)
+		add(match[0], element, attribute, "", href)
+		block = blank(block, match[0], match[1])
+	}
+	autolinks := markdownAutolink.FindAllStringSubmatchIndex(block, -1)
+	for _, match := range autolinks {
+		href := block[match[2]:match[3]]
+		add(match[0], "a", "href", href, href)
+		block = blank(block, match[0], match[1])
+	}
+	for _, match := range bareURL.FindAllStringIndex(block, -1) {
+		href := trimURLPunctuation(block[match[0]:match[1]])
+		add(match[0], "a", "href", href, href)
+	}
+	return links
+}
+
+// lineOf returns the number of the line containing `block[offset]`, where
+// `block` begins at line `firstLine`.
+func lineOf(block string, offset int, firstLine int) int {
+	return firstLine + strings.Count(block[:offset], "\n")
+}
+
+// markdownIndent returns the width of the indentation of `line`, with tabs
+// advancing to the next multiple of 4 columns.
+func markdownIndent(line string) int {
+	width := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// inlineLink is an inline link, e.g., `[text](href "title")`, or image, e.g.,
+// `![alt](src)`, spanning `[start, end)` of its line.
+type inlineLink struct {
+	start int
+	end   int
+	image bool
+	text  string
+	href  string
+}
+
+// markdownInlineLinks returns the inline links and images in `line`,
+// innermost first (e.g., an image inside a link's text precedes the link).
+func markdownInlineLinks(line string) []inlineLink {
+	var links []inlineLink
+	scanned := line
+	for i := 0; i+1 < len(scanned); i++ {
+		if scanned[i] != ']' || scanned[i+1] != '(' {
+			continue
+		}
+
+		// find the matching `[`
+		open, depth := -1, 0
+		for j := i - 1; j >= 0; j-- {
+			if scanned[j] == ']' {
+				depth++
+			} else if scanned[j] == '[' {
+				if depth == 0 {
+					open = j
+					break
+				}
+				depth--
+			}
+		}
+		if open < 0 {
+			continue
+		}
+
+		href, end, ok := markdownDestination(scanned, i+2)
+		if !ok {
+			continue
+		}
+		l := inlineLink{
+			start: open,
+			end:   end,
+			text:  line[open+1 : i],
+			href:  href,
+		}
+		if open > 0 && scanned[open-1] == '!' {
+			l.start, l.image = open-1, true
+		}
+		links = append(links, l)
+		scanned = blank(scanned, l.start, l.end)
+		i = end - 1
+	}
+	return links
+}
+
+// markdownDestination parses the destination (and optional title) of an
+// inline link beginning at `start`, just after the `(`. It returns the
+// destination and the index just after the closing `)`.
+func markdownDestination(line string, start int) (string, int, bool) {
+	i := start
+	for i < len(line) && strings.IndexByte(" \t\n", line[i]) >= 0 {
+		i++
+	}
+
+	var href string
+	if i < len(line) && line[i] == '<' {
+		end := strings.IndexByte(line[i:], '>')
+		if end < 0 {
+			return "", 0, false
+		}
+		href, i = line[i+1:i+end], i+end+1
+	} else {
+		begin, depth := i, 0
+	destination:
+		for ; i < len(line); i++ {
+			switch line[i] {
+			case ' ', '\t', '\n':
+				break destination
+			case '(':
+				depth++
+			case ')':
+				if depth == 0 {
+					break destination
+				}
+				depth--
+			}
+		}
+		href = line[begin:i]
+	}
+
+	// skip the title, if any
+	end := strings.IndexByte(line[i:], ')')
+	if end < 0 {
+		return "", 0, false
+	}
+	return href, i + end + 1, true
+}
+
+// blankCodeSpans replaces code spans in `line` with spaces.
+func blankCodeSpans(line string) string {
+	for i := 0; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		n := backticks(line, i)
+		closing := -1
+		for j := i + n; j < len(line); {
+			if line[j] != '`' {
+				j++
+				continue
+			}
+			if m := backticks(line, j); m == n {
+				closing = j
+				break
+			} else {
+				j += m
+			}
+		}
+		if closing < 0 {
+			i += n
+			continue
+		}
+		line = blank(line, i, closing+n)
+		i = closing + n
+	}
+	return line
+}
+
+// backticks returns the length of the run of backticks at `line[i]`.
+func backticks(line string, i int) int {
+	n := 0
+	for i+n < len(line) && line[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+// blank replaces `s[start:end]` with spaces, preserving offsets and line
+// breaks.
+func blank(s string, start int, end int) string {
+	b := []byte(s)
+	for i := start; i < end; i++ {
+		if b[i] != '\n' {
+			b[i] = ' '
+		}
+	}
+	return string(b)
+}
+
+// submatch returns submatch `n` of an index match, or the empty string if it
+// didn't participate.
+func submatch(s string, match []int, n int) string {
+	if match[2*n] < 0 {
+		return ""
+	}
+	return s[match[2*n]:match[2*n+1]]
+}
+
+// trimURLPunctuation removes trailing punctuation from a bare URL, e.g., the
+// period at the end of a sentence, or a closing parenthesis with no opening
+// one.
+func trimURLPunctuation(u string) string {
+	for len(u) > 0 {
+		last := u[len(u)-1]
+		switch {
+		case strings.IndexByte(".,:;!?'\"*_~", last) >= 0:
+			u = u[:len(u)-1]
+		case last == ')' &&
+			strings.Count(u, ")") > strings.Count(u, "("):
+			u = u[:len(u)-1]
+		default:
+			return u
+		}
+	}
+	return u
+}
+
+// markdownText returns the plain text of Markdown link text or a heading.
+func markdownText(text string) string {
+	text = markdownLinkMarkup.ReplaceAllString(text, "$1")
+	text = htmlTag.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// slugger generates GitHub-style heading anchors, which are unique within a
+// document.
+type slugger map[string]int
+
+// slug returns the anchor for `heading`: its text in lowercase, without
+// punctuation, and with spaces replaced by hyphens. Repeated slugs are
+// suffixed with `-1`, `-2`, and so on.
+func (slugs slugger) slug(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(markdownText(heading)) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteByte('-')
+		}
+	}
+	slug := b.String()
+	n := slugs[slug]
+	slugs[slug] = n + 1
+	if n > 0 {
+		slug += "-" + strconv.Itoa(n)
+	}
+	return slug
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// describeLinks returns the links in `source` as `line element attribute
+// "text" href`, which is easier to compare.
+func describeLinks(source *sourceFile) []string {
+	var links []string
+	for _, l := range source.links {
+		links = append(links, fmt.Sprintf(
+			"%d %s %s %q %s",
+			l.line,
+			l.element,
+			l.attribute,
+			l.text,
+			l.href,
+		))
+	}
+	return links
+}
+
+// sortedAnchors returns the anchors in `source` in order.
+func sortedAnchors(source *sourceFile) []string {
+	var anchors []string
+	for anchor := range source.anchors {
+		anchors = append(anchors, anchor)
+	}
+	sort.Strings(anchors)
+	return anchors
+}
+
+func TestParseMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		markdown string
+		links    []string
+		anchors  []string
+	}{
+		{
+			name: "inline links and images",
+			markdown: `See [the docs](docs/README.md#usage "Docs") and ` +
+				"![logo](img/logo.png).\n" +
+				"[![badge](badge.svg)](https://ci.example.com/)\n",
+			links: []string{
+				`1 a href "the docs" docs/README.md#usage`,
+				`1 img src "logo" img/logo.png`,
+				`2 img src "badge" badge.svg`,
+				`2 a href "badge" https://ci.example.com/`,
+			},
+		},
+		{
+			name: "destinations",
+			markdown: "[a](<with space.md>) [b](wiki/Foo_(bar)) " +
+				"[c]( padded.md )\n",
+			links: []string{
+				`1 a href "a" with space.md`,
+				`1 a href "b" wiki/Foo_(bar)`,
+				`1 a href "c" padded.md`,
+			},
+		},
+		{
+			name: "reference definitions",
+			markdown: "[label]: https://example.com/a \"Title\"\n" +
+				"  [other]: <b.md>\n",
+			links: []string{
+				`1 a href "label" https://example.com/a`,
+				`2 a href "other" b.md`,
+			},
+		},
+		{
+			name: "autolinks and bare URLs",
+			markdown: "<https://example.com/auto> and " +
+				"https://example.com/bare.\n" +
+				"(see https://example.com/paren)\n",
+			links: []string{
+				`1 a href "https://example.com/auto" ` +
+					`https://example.com/auto`,
+				`1 a href "https://example.com/bare" ` +
+					`https://example.com/bare`,
+				`2 a href "https://example.com/paren" ` +
+					`https://example.com/paren`,
+			},
+		},
+		{
+			name: "HTML",
+			markdown: `<a href="x.md">x</a> <img src='y.png'>` + "\n" +
+				`<a name="named"></a><div id="identified"></div>` + "\n",
+			links: []string{
+				`1 a href "" x.md`,
+				`1 img src "" y.png`,
+			},
+			anchors: []string{"identified", "named"},
+		},
+		{
+			name: "code",
+			markdown: "```go\n[fenced](a.md)\n```\n" +
+				"~~~~\n```\n[tilde](b.md)\n~~~~\n" +
+				"`[span](c.md)` and ``https://example.com/`` " +
+				"[after](d.md)\n",
+			links: []string{`8 a href "after" d.md`},
+		},
+		{
+			name: "wrapped links",
+			markdown: "Intro\nsee [some long\ntext](../other.md), <a\n" +
+				`href="x.md">x</a> and ![an` + "\n" +
+				"image](img.png \"a\ntitle\") and\nhttps://example.com/bare.\n",
+			links: []string{
+				`2 a href "some long text" ../other.md`,
+				`3 a href "" x.md`,
+				`4 img src "an image" img.png`,
+				`7 a href "https://example.com/bare" ` +
+					`https://example.com/bare`,
+			},
+		},
+		{
+			name: "indented code",
+			markdown: "Text\n\n    [code](a.md)\n\t[tab](b.md)\n\n" +
+				"Text\n    [continued](c.md)\n" +
+				"# Heading\n    [code](d.md)\n",
+			links:   []string{`7 a href "continued" c.md`},
+			anchors: []string{"heading"},
+		},
+		{
+			name: "lists",
+			markdown: "- item [a](a.md)\n\n" +
+				"  continued [b](b.md)\n\n" +
+				"      [code](c.md)\n\n" +
+				"1. [d](d.md)\n" +
+				"   - nested\n\n" +
+				"         [code](e.md)\n\n" +
+				"    [not code](f.md)\n",
+			links: []string{
+				`1 a href "a" a.md`,
+				`3 a href "b" b.md`,
+				`7 a href "d" d.md`,
+				`12 a href "not code" f.md`,
+			},
+		},
+		{
+			name: "front matter",
+			markdown: "---\nurl: https://example.com/front\n---\n" +
+				"[a](b.md)\n",
+			links: []string{`4 a href "a" b.md`},
+		},
+		{
+			name: "headings",
+			markdown: "# Title\n" +
+				"## Title ##\n" +
+				"Setext\n======\n" +
+				"Another *setext*\n---\n" +
+				"### `Code`, [Link](x.md) & <em>HTML</em>!\n" +
+				"#hashtag\n",
+			links: []string{`7 a href "Link" x.md`},
+			anchors: []string{
+				"another-setext",
+				"code-link--html",
+				"setext",
+				"title",
+				"title-1",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			source := parseMarkdown(tc.markdown)
+			if links := describeLinks(source); !slices.Equal(
+				links,
+				tc.links,
+			) {
+				t.Fatalf("wanted links %q; found %q", tc.links, links)
+			}
+			if anchors := sortedAnchors(source); !slices.Equal(
+				anchors,
+				tc.anchors,
+			) {
+				t.Fatalf("wanted anchors %q; found %q", tc.anchors, anchors)
+			}
+		})
+	}
+}
+
+func TestSlugger(t *testing.T) {
+	slugs := slugger{}
+	for _, tc := range []struct {
+		heading string
+		wanted  string
+	}{
+		{heading: "Hello, World!", wanted: "hello-world"},
+		{heading: "Hello World", wanted: "hello-world-1"},
+		{heading: "hello-world", wanted: "hello-world-2"},
+		{heading: "  Spaces   around ", wanted: "spaces-around"},
+		{heading: "snake_case & kebab-case", wanted: "snake_case--kebab-case"},
+		{heading: "Ünïcode 日本語", wanted: "ünïcode-日本語"},
+		{heading: "[Linked](x.md) heading", wanted: "linked-heading"},
+		{heading: "v1.2.3", wanted: "v123"},
+	} {
+		if slug := slugs.slug(tc.heading); slug != tc.wanted {
+			t.Errorf(
+				"slug(%q): wanted `%s`; found `%s`",
+				tc.heading,
+				tc.wanted,
+				slug,
+			)
+		}
+	}
+}
+
+func TestMarkdownUndefinedReferences(t *testing.T) {
+	source := parseMarkdown(`# Title
+
+See [the docs][docs], [Docs][], [the  DOCS][docs ] and ![logo][].
+Also [missing][nowhere], [Undefined][] and ![icon][gone].
+A [wrapped
+label][] and [the
+docs][docs] wrap.
+A [task] list, an [inline](https://example.com/) link, and
+` + "`[code][span]`" + ` are fine.
+
+` + "```" + `
+[fenced][block]
+` + "```" + `
+
+[docs]: https://example.com/docs
+[LOGO]: logo.png
+`)
+
+	var undefined []string
+	for _, l := range source.links {
+		if l.err == nil {
+			continue
+		}
+		if l.err.Code != ErrorUndefinedReference {
+			t.Fatalf("unexpected error: %v", l.err)
+		}
+		undefined = append(undefined, l.element+" "+l.href)
+	}
+	wanted := []string{
+		"a nowhere",
+		"a Undefined",
+		"img gone",
+		"a wrapped label",
+	}
+	if !slices.Equal(undefined, wanted) {
+		t.Fatalf("wanted undefined references %q; got %q", wanted, undefined)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/url"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PageIssue is a problem with the content of a page (rather than with a
+// link), found by a [PageChecker]. Its category is `page-` followed by the
+// issue, e.g., `page-missing-alt`.
+type PageIssue string
+
+const (
+	// PageMissingAlt indicates an image without an `alt` attribute.
+	PageMissingAlt PageIssue = "missing-alt"
+
+	// PageEmptyLinkText indicates a hyperlink without any text (or an
+	// accessible name), which screen readers can't describe.
+	PageEmptyLinkText PageIssue = "empty-link-text"
+
+	// PageDuplicateID indicates an `id` used by more than one element, so
+	// that fragments identify the first of them.
+	PageDuplicateID PageIssue = "duplicate-id"
+
+	// PageMissingTitle indicates a page without a `<title>`.
+	PageMissingTitle PageIssue = "missing-title"
+
+	// PageMissingDescription indicates a page without a
+	// `<meta name="description">`.
+	PageMissingDescription PageIssue = "missing-description"
+
+	// PageBrokenCanonical indicates a `<link rel="canonical">` which is
+	// empty, invalid, or contradicted by another. A canonical URL which
+	// doesn't respond is reported like any other `<link>`.
+	PageBrokenCanonical PageIssue = "broken-canonical"
+)
+
+// PageFinding is a problem found on a page by a [PageChecker].
+type PageFinding struct {
+	Issue PageIssue
+
+	// Element and Attribute identify the element with the problem, if it is
+	// a single element, e.g., `img` and `src`.
+	Element   string
+	Attribute string
+
+	// Text and URL are the element's text and the URL it references, if
+	// any.
+	Text string
+	URL  string
+
+	// Message describes the problem.
+	Message string
+}
+
+// PageChecker inspects the content of each crawled page of the site (see
+// [Crawler.PageCheckers]). Findings are reported as results with a
+// [PageIssue], alongside those of the page's links.
+type PageChecker interface {
+	CheckPage(page *url.URL, doc *goquery.Document) []PageFinding
+}
+
+// PageCheckerFunc adapts a function to a [PageChecker].
+type PageCheckerFunc func(page *url.URL, doc *goquery.Document) []PageFinding
+
+func (f PageCheckerFunc) CheckPage(
+	page *url.URL,
+	doc *goquery.Document,
+) []PageFinding {
+	return f(page, doc)
+}
+
+// DefaultPageCheckers are the built-in page checks, enabled by
+// `-check-pages`.
+var DefaultPageCheckers = []PageChecker{
+	PageCheckerFunc(checkAltText),
+	PageCheckerFunc(checkLinkText),
+	PageCheckerFunc(checkDuplicateIDs),
+	PageCheckerFunc(checkMetadata),
+	PageCheckerFunc(checkCanonical),
+}
+
+// checkPage passes the findings of each of `PageCheckers` on the page at
+// `page` to the callback.
+func (crawler *Crawler) checkPage(page *url.URL, doc *goquery.Document) {
+	for _, checker := range crawler.PageCheckers {
+		for _, finding := range checker.CheckPage(page, doc) {
+			crawler.callback(&Result{
+				BaseURL:    page.String(),
+				Element:    finding.Element,
+				Attribute:  finding.Attribute,
+				TargetText: finding.Text,
+				TargetURL:  finding.URL,
+				PageIssue:  finding.Issue,
+				Message:    finding.Message,
+			})
+		}
+	}
+}
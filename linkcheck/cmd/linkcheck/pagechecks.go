@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// checkAltText reports images (including image map areas and image inputs)
+// without an `alt` attribute. An empty `alt` marks an image as decorative, so
+// it isn't reported.
+func checkAltText(_ *url.URL, doc *goquery.Document) []PageFinding {
+	var findings []PageFinding
+	doc.Find("img:not([alt]), area[href]:not([alt]), " +
+		"input[type=image]:not([alt])").Each(
+		func(_ int, s *goquery.Selection) {
+			element := goquery.NodeName(s)
+			attribute := "src"
+			if element == "area" {
+				attribute = "href"
+			}
+			href, _ := s.Attr(attribute)
+			findings = append(findings, PageFinding{
+				Issue:     PageMissingAlt,
+				Element:   element,
+				Attribute: attribute,
+				URL:       href,
+				Message:   "missing `alt` attribute",
+			})
+		},
+	)
+	return findings
+}
+
+// checkLinkText reports hyperlinks without an accessible name: no text, no
+// image with alt text, and no `aria-label`, `aria-labelledby`, or `title`.
+func checkLinkText(_ *url.URL, doc *goquery.Document) []PageFinding {
+	var findings []PageFinding
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		if strings.TrimSpace(s.Text()) != "" {
+			return
+		}
+		for _, attribute := range []string{
+			"aria-label",
+			"aria-labelledby",
+			"title",
+		} {
+			if value, _ := s.Attr(attribute); strings.TrimSpace(value) != "" {
+				return
+			}
+		}
+		labeled := false
+		s.Find("img[alt]").EachWithBreak(
+			func(_ int, img *goquery.Selection) bool {
+				alt, _ := img.Attr("alt")
+				labeled = strings.TrimSpace(alt) != ""
+				return !labeled
+			},
+		)
+		if labeled {
+			return
+		}
+
+		href, _ := s.Attr("href")
+		findings = append(findings, PageFinding{
+			Issue:     PageEmptyLinkText,
+			Element:   "a",
+			Attribute: "href",
+			URL:       href,
+			Message:   "link has no text",
+		})
+	})
+	return findings
+}
+
+// checkDuplicateIDs reports each `id` which is used by more than one element.
+func checkDuplicateIDs(_ *url.URL, doc *goquery.Document) []PageFinding {
+	var ids []string
+	elements := map[string]string{}
+	counts := map[string]int{}
+	doc.Find("[id]").Each(func(_ int, s *goquery.Selection) {
+		id, _ := s.Attr("id")
+		if id == "" {
+			return
+		}
+		if counts[id] == 0 {
+			ids = append(ids, id)
+			elements[id] = goquery.NodeName(s)
+		}
+		counts[id]++
+	})
+
+	var findings []PageFinding
+	for _, id := range ids {
+		if counts[id] < 2 {
+			continue
+		}
+		findings = append(findings, PageFinding{
+			Issue:     PageDuplicateID,
+			Element:   elements[id],
+			Attribute: "id",
+			Text:      id,
+			Message: fmt.Sprintf(
+				"id `%s` is used by %d elements",
+				id,
+				counts[id],
+			),
+		})
+	}
+	return findings
+}
+
+// checkMetadata reports a missing (or empty) `<title>` and
+// `<meta name="description">`.
+func checkMetadata(_ *url.URL, doc *goquery.Document) []PageFinding {
+	var findings []PageFinding
+	if strings.TrimSpace(doc.Find("title").First().Text()) == "" {
+		findings = append(findings, PageFinding{
+			Issue:   PageMissingTitle,
+			Message: "missing `<title>`",
+		})
+	}
+
+	described := false
+	doc.Find("meta[name]").EachWithBreak(
+		func(_ int, s *goquery.Selection) bool {
+			name, _ := s.Attr("name")
+			content, _ := s.Attr("content")
+			described = strings.EqualFold(name, "description") &&
+				strings.TrimSpace(content) != ""
+			return !described
+		},
+	)
+	if !described {
+		findings = append(findings, PageFinding{
+			Issue:   PageMissingDescription,
+			Message: "missing `<meta name=\"description\">`",
+		})
+	}
+	return findings
+}
+
+// checkCanonical reports `<link rel="canonical">` elements which are empty,
+// aren't valid http(s) URLs, or contradict an earlier canonical URL.
+func checkCanonical(page *url.URL, doc *goquery.Document) []PageFinding {
+	var findings []PageFinding
+	canonical := ""
+	doc.Find("link[rel]").Each(func(_ int, s *goquery.Selection) {
+		rel, _ := s.Attr("rel")
+		if !slices.Contains(strings.Fields(strings.ToLower(rel)), "canonical") {
+			return
+		}
+
+		href, _ := s.Attr("href")
+		report := func(format string, args ...any) {
+			findings = append(findings, PageFinding{
+				Issue:     PageBrokenCanonical,
+				Element:   "link",
+				Attribute: "href",
+				URL:       href,
+				Message:   fmt.Sprintf(format, args...),
+			})
+		}
+		if strings.TrimSpace(href) == "" {
+			report("canonical link has no URL")
+			return
+		}
+		u, err := page.Parse(strings.TrimSpace(href))
+		switch {
+		case err != nil:
+			report("invalid canonical URL: %v", err)
+		case u.Scheme != "http" && u.Scheme != "https":
+			report("canonical URL isn't an http(s) URL")
+		case canonical == "":
+			canonical = stripFragment(u).String()
+		case canonical != stripFragment(u).String():
+			report("conflicts with canonical URL %s", canonical)
+		}
+	})
+	return findings
+}
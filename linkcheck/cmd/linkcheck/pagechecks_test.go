@@ -0,0 +1,278 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// described is the metadata of a page which has it.
+const described = `<title>Page</title>` +
+	`<meta name="description" content="A page.">`
+
+func TestPageChecks(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		checker  func(*url.URL, *goquery.Document) []PageFinding
+		html     string
+		findings []PageFinding
+	}{
+		{
+			name:    "alt text",
+			checker: checkAltText,
+			html: `<img src="a.png" alt="A">` +
+				`<img src="decorative.png" alt="">` +
+				`<img src="b.png">` +
+				`<map><area href="/c" alt="C"><area href="/d"></map>` +
+				`<input type="image" src="e.png">` +
+				`<input type="image" src="f.png" alt="F">`,
+			findings: []PageFinding{
+				{
+					Issue:     PageMissingAlt,
+					Element:   "img",
+					Attribute: "src",
+					URL:       "b.png",
+					Message:   "missing `alt` attribute",
+				},
+				{
+					Issue:     PageMissingAlt,
+					Element:   "area",
+					Attribute: "href",
+					URL:       "/d",
+					Message:   "missing `alt` attribute",
+				},
+				{
+					Issue:     PageMissingAlt,
+					Element:   "input",
+					Attribute: "src",
+					URL:       "e.png",
+					Message:   "missing `alt` attribute",
+				},
+			},
+		},
+		{
+			name:    "link text",
+			checker: checkLinkText,
+			html: `<a href="/text">text</a>` +
+				`<a href="/label" aria-label="Label"></a>` +
+				`<a href="/labelledby" aria-labelledby="x"></a>` +
+				`<a href="/title" title="Title"></a>` +
+				`<a href="/img"><img src="i.png" alt="Image"></a>` +
+				`<a name="anchor"></a>` +
+				`<a href="/empty"> </a>` +
+				`<a href="/blank-label" aria-label=" "></a>` +
+				`<a href="/decorative"><img src="i.png" alt=""></a>`,
+			findings: []PageFinding{
+				{
+					Issue:     PageEmptyLinkText,
+					Element:   "a",
+					Attribute: "href",
+					URL:       "/empty",
+					Message:   "link has no text",
+				},
+				{
+					Issue:     PageEmptyLinkText,
+					Element:   "a",
+					Attribute: "href",
+					URL:       "/blank-label",
+					Message:   "link has no text",
+				},
+				{
+					Issue:     PageEmptyLinkText,
+					Element:   "a",
+					Attribute: "href",
+					URL:       "/decorative",
+					Message:   "link has no text",
+				},
+			},
+		},
+		{
+			name:    "duplicate ids",
+			checker: checkDuplicateIDs,
+			html: `<h2 id="a">A</h2><p id="b"></p><div id="a"></div>` +
+				`<p id="c"></p><span id="a"></span><p id="c"></p>` +
+				`<p id=""></p><p id=""></p>`,
+			findings: []PageFinding{
+				{
+					Issue:     PageDuplicateID,
+					Element:   "h2",
+					Attribute: "id",
+					Text:      "a",
+					Message:   "id `a` is used by 3 elements",
+				},
+				{
+					Issue:     PageDuplicateID,
+					Element:   "p",
+					Attribute: "id",
+					Text:      "c",
+					Message:   "id `c` is used by 2 elements",
+				},
+			},
+		},
+		{
+			name:    "metadata",
+			checker: checkMetadata,
+			html:    described,
+		},
+		{
+			name:    "metadata with a differently cased name",
+			checker: checkMetadata,
+			html: `<title>Page</title>` +
+				`<meta name="Description" content="A page.">`,
+		},
+		{
+			name:    "missing metadata",
+			checker: checkMetadata,
+			html: `<title> </title>` +
+				`<meta name="keywords" content="a, b">` +
+				`<meta name="description" content=" ">`,
+			findings: []PageFinding{
+				{Issue: PageMissingTitle, Message: "missing `<title>`"},
+				{
+					Issue:   PageMissingDescription,
+					Message: "missing `<meta name=\"description\">`",
+				},
+			},
+		},
+		{
+			name:    "canonical",
+			checker: checkCanonical,
+			html: `<link rel="canonical" href="/docs/page">` +
+				`<link rel="Canonical alternate" ` +
+				`href="https://example.com/docs/page#top">` +
+				`<link rel="stylesheet" href="style.css">`,
+		},
+		{
+			name:    "broken canonical",
+			checker: checkCanonical,
+			html: `<link rel="canonical" href=" ">` +
+				`<link rel="canonical" href="mailto:a@example.com">` +
+				`<link rel="canonical" href="http://[bad">` +
+				`<link rel="canonical" href="/docs/page">` +
+				`<link rel="canonical" href="/docs/other">`,
+			findings: []PageFinding{
+				{
+					Issue:     PageBrokenCanonical,
+					Element:   "link",
+					Attribute: "href",
+					URL:       " ",
+					Message:   "canonical link has no URL",
+				},
+				{
+					Issue:     PageBrokenCanonical,
+					Element:   "link",
+					Attribute: "href",
+					URL:       "mailto:a@example.com",
+					Message:   "canonical URL isn't an http(s) URL",
+				},
+				{
+					Issue:     PageBrokenCanonical,
+					Element:   "link",
+					Attribute: "href",
+					URL:       "http://[bad",
+				},
+				{
+					Issue:     PageBrokenCanonical,
+					Element:   "link",
+					Attribute: "href",
+					URL:       "/docs/other",
+					Message: "conflicts with canonical URL " +
+						"https://example.com/docs/page",
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(
+				strings.NewReader(tc.html),
+			)
+			if err != nil {
+				t.Fatalf("parsing page: %v", err)
+			}
+			findings := tc.checker(mustParse("https://example.com/docs/"), doc)
+			for i := range findings {
+				// parse errors' messages are up to `net/url`
+				if strings.HasPrefix(
+					findings[i].Message,
+					"invalid canonical URL: ",
+				) {
+					findings[i].Message = ""
+				}
+			}
+			if !reflect.DeepEqual(findings, tc.findings) {
+				t.Fatalf(
+					"wanted findings:\n%+v\ngot:\n%+v",
+					tc.findings,
+					findings,
+				)
+			}
+		})
+	}
+}
+
+func TestCheckPages(t *testing.T) {
+	external := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<img src="external.png">`),
+	})
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(described +
+			`<a href="/page">page</a>` +
+			`<a href="` + external.URL + `/">external</a>`),
+		"/page": html(`<title>Page</title><img src="/missing-alt.png">`),
+		"/missing-alt.png": func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+		},
+	})
+
+	crawler := newTestCrawler(server).
+		SetPageCheckers(DefaultPageCheckers...).
+		SetHeadExternal(false)
+	results, err := crawl(t, crawler, server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type finding struct {
+		baseURL  string
+		issue    PageIssue
+		severity Severity
+	}
+	var findings []finding
+	for _, rs := range results {
+		for _, r := range rs {
+			if r.PageIssue != "" {
+				findings = append(findings, finding{
+					baseURL:  r.BaseURL,
+					issue:    r.PageIssue,
+					severity: r.Severity,
+				})
+			}
+		}
+	}
+
+	// only pages of the site are checked
+	wanted := map[finding]bool{
+		{
+			baseURL:  server.URL + "/page",
+			issue:    PageMissingAlt,
+			severity: SeverityWarning,
+		}: true,
+		{
+			baseURL:  server.URL + "/page",
+			issue:    PageMissingDescription,
+			severity: SeverityInfo,
+		}: true,
+	}
+	if len(findings) != len(wanted) {
+		t.Fatalf("wanted findings %v; got %+v", wanted, findings)
+	}
+	for _, f := range findings {
+		if !wanted[f] {
+			t.Fatalf("wanted findings %v; got %+v", wanted, findings)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type PrettyResultPrinter struct {
 	lastOkay bool
@@ -11,43 +14,194 @@ func (printer *PrettyResultPrinter) Visit(r *Result) {
 }
 
 func (printer *PrettyResultPrinter) Print(r *Result) {
-	if !printer.lastOkay {
+	if r.Severity == "" {
+		fmt.Print(".")
+		printer.lastOkay = true
+		return
+	}
+
+	if printer.lastOkay {
 		fmt.Print("\n")
 	}
-	if r.StatusCode != 200 {
-		fmt.Printf(
-			"\n⛔️ %s <a href=\"%s\">%s</a>: %d",
+	icon, message := describe(r)
+	var labels []string
+	if r.Severity != SeverityError {
+		labels = append(labels, string(r.Severity))
+	}
+	if r.Baseline == BaselineNew || r.Baseline == BaselineStillBroken {
+		labels = append(labels, string(r.Baseline))
+	}
+	if len(labels) > 0 {
+		message = fmt.Sprintf("%s [%s]", message, strings.Join(labels, ", "))
+	}
+	fmt.Printf("%s %s\n", icon, message)
+	printer.lastOkay = false
+}
+
+// describe returns an icon and a message describing the most serious problem
+// with `r`.
+func describe(r *Result) (icon string, message string) {
+	icon, problem := diagnose(r)
+	switch category(r) {
+	case CategorySitemapOrphan:
+		return icon, fmt.Sprintf(
+			"%s lists %s, but no crawled page links to it",
 			r.BaseURL,
 			r.TargetURL,
-			r.TargetText,
-			r.StatusCode,
 		)
-		printer.lastOkay = false
-		return
+	case CategorySitemapUnlisted:
+		return icon, fmt.Sprintf(
+			"%s is missing from %s",
+			r.TargetURL,
+			r.BaseURL,
+		)
+	case "":
+		return icon, fmt.Sprintf("%s %s", location(r), tag(r))
 	}
+	if r.PageIssue != "" && (r.Element == "" || r.Attribute == "id") {
+		// the problem isn't with a reference, so there's no tag to render
+		return icon, fmt.Sprintf("%s: %s", location(r), problem)
+	}
+	return icon, fmt.Sprintf("%s %s: %s", location(r), tag(r), problem)
+}
 
-	if r.URLParseError != nil {
-		fmt.Printf(
-			"🙅‍♂️ %s <a href=\"%s\">%s</a>: %v",
-			r.BaseURL,
-			r.TargetURL,
-			r.TargetText,
-			r.URLParseError,
+// location returns the result's base URL and, if it is known, the line on
+// which the reference appears, e.g., `docs/README.md:12`.
+func location(r *Result) string {
+	if r.Line > 0 {
+		return fmt.Sprintf("%s:%d", r.BaseURL, r.Line)
+	}
+	return r.BaseURL
+}
+
+// diagnose returns an icon and a description of the most serious problem with
+// `r`, independent of the page which links to the target.
+func diagnose(r *Result) (icon string, problem string) {
+	if r.Error != nil {
+		switch r.Error.Code {
+		case ErrorParse, ErrorUndefinedReference:
+			icon = "🙅‍♂️"
+		case ErrorFragmentMissing:
+			icon = "#️⃣"
+		default:
+			icon = "⛔️"
+		}
+		return icon, r.Error.Message
+	}
+
+	if r.PageIssue != "" {
+		return "📄", r.Message
+	}
+
+	switch category(r) {
+	case CategorySoft404:
+		return "👻", "looks like a \"not found\" page despite responding " +
+			"successfully"
+	case CategoryRedirectPermanent:
+		return "↪️", fmt.Sprintf(
+			"permanently redirects to %s",
+			r.Redirects[len(r.Redirects)-1].URL,
 		)
-		printer.lastOkay = false
-		return
+	case CategoryRedirectDowngrade:
+		return "🔓", fmt.Sprintf(
+			"redirects from https to http: %s",
+			r.Redirects[len(r.Redirects)-1].URL,
+		)
+	case CategoryRedirectLong:
+		return "↪️", fmt.Sprintf(
+			"redirects %d times to %s",
+			len(r.Redirects),
+			r.Redirects[len(r.Redirects)-1].URL,
+		)
+	case CategorySitemapOrphan:
+		return "🗺️", "listed in the sitemap, but no crawled page links to it"
+	case CategorySitemapUnlisted:
+		return "🗺️", "missing from the sitemap"
+	case CategoryInsecureMixed:
+		return "🔓", "https page loads a resource over http" + httpsHint(r)
+	case CategoryInsecureLink:
+		return "🔓", "links over insecure http" + httpsHint(r)
+	case CategoryBaselineFixed:
+		return "✅", "broken in the baseline but not anymore"
+	default:
+		return "❓", ""
 	}
+}
 
-	if r.NetworkError != nil {
-		fmt.Printf(
-			"\n⛔️ %s <a href=\"%s\">%s</a>: %v",
-			r.BaseURL,
+// httpsHint describes whether the https version of an insecure target
+// responds, if it was checked.
+func httpsHint(r *Result) string {
+	switch {
+	case r.HTTPSAvailable == nil:
+		return ""
+	case *r.HTTPSAvailable:
+		return "; the https version responds"
+	default:
+		return "; the https version doesn't respond"
+	}
+}
+
+// PrintAggregate prints the aggregate's problem followed by each link.
+func (printer *PrettyResultPrinter) PrintAggregate(aggregate *Aggregate) {
+	links := "links"
+	if aggregate.Count == 1 {
+		links = "link"
+	}
+	fmt.Printf(
+		"%s %s: %s [%s, %d %s]\n",
+		aggregate.icon,
+		aggregate.TargetURL,
+		aggregate.Message,
+		aggregate.Severity,
+		aggregate.Count,
+		links,
+	)
+	for i := range aggregate.Sources {
+		source := &aggregate.Sources[i]
+		fmt.Printf("    %s %s\n", location(&Result{
+			BaseURL: source.BaseURL,
+			Line:    source.Line,
+		}), tag(&Result{
+			Element:    source.Element,
+			Attribute:  source.Attribute,
+			TargetText: source.TargetText,
+			TargetURL:  source.TargetURL,
+		}))
+	}
+}
+
+// tag renders the element which references the result's target, e.g.,
+// `<img src="logo.png">`.
+func tag(r *Result) string {
+	element, attribute := r.Element, r.Attribute
+	if element == "" {
+		element, attribute = "a", "href"
+	}
+	switch {
+	case element == "style":
+		return fmt.Sprintf("<style>url(%s)</style>", r.TargetURL)
+	case element == "@import":
+		return fmt.Sprintf("@import url(%s)", r.TargetURL)
+	case element == "url":
+		return fmt.Sprintf("url(%s)", r.TargetURL)
+	case attribute == "":
+		return fmt.Sprintf("<%s>%s</%s>", element, r.TargetURL, element)
+	case attribute == "style":
+		return fmt.Sprintf("<%s style=\"url(%s)\">", element, r.TargetURL)
+	case element == "img":
+		return fmt.Sprintf(
+			"<img %s=\"%s\" alt=\"%s\">",
+			attribute,
 			r.TargetURL,
 			r.TargetText,
-			r.NetworkError,
 		)
 	}
-
-	fmt.Print(".")
-	printer.lastOkay = true
+	return fmt.Sprintf(
+		"<%s %s=\"%s\">%s</%s>",
+		element,
+		attribute,
+		r.TargetURL,
+		r.TargetText,
+		element,
+	)
 }
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Redirect is a single hop of a redirect chain.
+type Redirect struct {
+	// StatusCode is the redirect status, e.g., 301.
+	StatusCode int `json:"statusCode"`
+
+	// URL is the location redirected to.
+	URL string `json:"url"`
+
+	// implicit is true for the redirects [DirTransport] makes for
+	// directories requested without a trailing slash, which aren't
+	// reported.
+	implicit bool
+}
+
+// reportedRedirects returns `redirects` without implicit hops.
+func reportedRedirects(redirects []Redirect) []Redirect {
+	var reported []Redirect
+	for _, hop := range redirects {
+		if !hop.implicit {
+			reported = append(reported, hop)
+		}
+	}
+	return reported
+}
+
+// RedirectIssue classifies a problematic redirect chain.
+type RedirectIssue string
+
+const (
+	// RedirectPermanent indicates that the chain includes a permanent (301
+	// or 308) redirect, so the link should be updated to the final URL.
+	RedirectPermanent RedirectIssue = "permanent"
+
+	// RedirectLong indicates that the chain is longer than
+	// `Crawler.LongRedirectChain`.
+	RedirectLong RedirectIssue = "long"
+
+	// RedirectDowngrade indicates that the chain redirects from https to
+	// http.
+	RedirectDowngrade RedirectIssue = "downgrade"
+
+	// RedirectLoop indicates that the chain revisits a URL.
+	RedirectLoop RedirectIssue = "loop"
+
+	// RedirectTooMany indicates that the chain was abandoned after
+	// `Crawler.MaxRedirects` hops.
+	RedirectTooMany RedirectIssue = "too-many"
+)
+
+// ErrRedirectLoop is returned when a redirect chain revisits a URL.
+type ErrRedirectLoop string
+
+func (e ErrRedirectLoop) Error() string {
+	return fmt.Sprintf("redirect loop at `%s`", string(e))
+}
+
+// ErrTooManyRedirects is returned when a redirect chain exceeds
+// `Crawler.MaxRedirects`.
+type ErrTooManyRedirects int
+
+func (e ErrTooManyRedirects) Error() string {
+	return fmt.Sprintf("stopped after %d redirects", int(e))
+}
+
+// follow sends `req`, following redirects up to `MaxRedirects` hops and
+// recording each one. Each hop is subject to the politeness and retry rules
+// of [Crawler.do]. The final URL is `rsp.Request.URL`.
+func (crawler *Crawler) follow(
+	req *http.Request,
+) (
+	rsp *http.Response,
+	release func(),
+	redirects []Redirect,
+	attempts int,
+	err error,
+) {
+	visited := map[string]struct{}{req.URL.String(): {}}
+	for {
+		var n int
+		rsp, release, n, err = crawler.do(req)
+		attempts += n
+		if err != nil || !isRedirect(rsp.StatusCode) {
+			return
+		}
+
+		location, err := rsp.Location()
+		if err != nil {
+			// a redirect without a usable `Location` is reported as-is
+			return rsp, release, redirects, attempts, nil
+		}
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+		release()
+		release = func() {}
+
+		redirects = append(redirects, Redirect{
+			StatusCode: rsp.StatusCode,
+			URL:        location.String(),
+			implicit:   rsp.Header.Get(dirRedirectHeader) != "",
+		})
+		if _, seen := visited[location.String()]; seen {
+			return nil, release, redirects, attempts, ErrRedirectLoop(
+				location.String(),
+			)
+		}
+		if len(redirects) > crawler.MaxRedirects {
+			return nil, release, redirects, attempts, ErrTooManyRedirects(
+				crawler.MaxRedirects,
+			)
+		}
+		visited[location.String()] = struct{}{}
+
+		method := req.Method
+		if rsp.StatusCode == http.StatusSeeOther &&
+			method != http.MethodHead {
+			method = http.MethodGet
+		}
+		next, err := http.NewRequestWithContext(
+			req.Context(),
+			method,
+			location.String(),
+			nil,
+		)
+		if err != nil {
+			return nil, release, redirects, attempts, err
+		}
+		next.Header = req.Header.Clone()
+		req = next
+	}
+}
+
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusSeeOther,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// redirectIssue returns the most severe issue with a redirect chain starting
+// at `start`, if any.
+func (crawler *Crawler) redirectIssue(
+	start string,
+	redirects []Redirect,
+	err error,
+) RedirectIssue {
+	var loop ErrRedirectLoop
+	var tooMany ErrTooManyRedirects
+	var resultErr *ResultError
+	switch {
+	case errors.As(err, &loop),
+		errors.As(err, &resultErr) && resultErr.Code == ErrorRedirectLoop:
+		return RedirectLoop
+	case errors.As(err, &tooMany),
+		errors.As(err, &resultErr) && resultErr.Code == ErrorTooManyRedirects:
+		return RedirectTooMany
+	}
+	if len(redirects) < 1 {
+		return ""
+	}
+
+	permanent, downgrade := false, false
+	from := start
+	for _, hop := range redirects {
+		if hop.StatusCode == http.StatusMovedPermanently ||
+			hop.StatusCode == http.StatusPermanentRedirect {
+			permanent = true
+		}
+		if isScheme(from, "https") && isScheme(hop.URL, "http") {
+			downgrade = true
+		}
+		from = hop.URL
+	}
+
+	switch {
+	case downgrade:
+		return RedirectDowngrade
+	case crawler.LongRedirectChain > 0 &&
+		len(redirects) > crawler.LongRedirectChain:
+		return RedirectLong
+	case permanent:
+		return RedirectPermanent
+	default:
+		return ""
+	}
+}
+
+func isScheme(rawURL, scheme string) bool {
+	prefix, _, found := strings.Cut(rawURL, ":")
+	return found && strings.EqualFold(prefix, scheme)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// reference is a URL referenced by an element of an HTML document.
+type reference struct {
+	element   string
+	attribute string
+	text      string
+	href      string
+
+	// page is true if the reference is a hyperlink to another page (as
+	// opposed to a resource embedded in this one), in which case it is
+	// crawled if it is part of the site.
+	page bool
+}
+
+// referenceAttributes are the attributes which hold a single URL, keyed by
+// selector. Hyperlinks are handled separately.
+var referenceAttributes = []struct {
+	selector  string
+	attribute string
+}{
+	{"img[src]", "src"},
+	{"script[src]", "src"},
+	{"iframe[src]", "src"},
+	{"frame[src]", "src"},
+	{"embed[src]", "src"},
+	{"source[src]", "src"},
+	{"track[src]", "src"},
+	{"audio[src]", "src"},
+	{"video[src]", "src"},
+	{"video[poster]", "poster"},
+	{"object[data]", "data"},
+	{"input[type=image][src]", "src"},
+}
+
+// linkRelIgnored are `<link rel>` values whose `href` names an origin or a
+// hint rather than a resource which must exist.
+var linkRelIgnored = map[string]struct{}{
+	"dns-prefetch": {},
+	"preconnect":   {},
+}
+
+// references returns every URL referenced by `doc`: hyperlinks (`<a>` and
+// `<area>`), embedded resources, `srcset` candidates, `<link>` targets, meta
+// refresh targets, and CSS `url(...)`s (and `@import`s) in `<style>` elements
+// and `style` attributes.
+func references(doc *goquery.Document) []reference {
+	var refs []reference
+	add := func(s *goquery.Selection, attribute, href string, page bool) {
+		// inline data needn't be checked
+		if len(href) >= 5 && strings.EqualFold(href[:5], "data:") {
+			return
+		}
+		element := goquery.NodeName(s)
+		var text string
+		switch {
+		case page:
+			text = s.Text()
+		case element == "img":
+			text, _ = s.Attr("alt")
+		}
+		refs = append(refs, reference{
+			element:   element,
+			attribute: attribute,
+			text:      text,
+			href:      href,
+			page:      page,
+		})
+	}
+
+	doc.Find("a[href], area[href]").Each(func(_ int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		add(s, "href", href, true)
+	})
+
+	for _, ra := range referenceAttributes {
+		doc.Find(ra.selector).Each(func(_ int, s *goquery.Selection) {
+			href, _ := s.Attr(ra.attribute)
+			add(s, ra.attribute, href, false)
+		})
+	}
+
+	doc.Find("link[href]").Each(func(_ int, s *goquery.Selection) {
+		rel, _ := s.Attr("rel")
+		for _, r := range strings.Fields(strings.ToLower(rel)) {
+			if _, ignored := linkRelIgnored[r]; ignored {
+				return
+			}
+		}
+		href, _ := s.Attr("href")
+		add(s, "href", href, false)
+	})
+
+	doc.Find("img[srcset], source[srcset]").Each(
+		func(_ int, s *goquery.Selection) {
+			srcset, _ := s.Attr("srcset")
+			for _, href := range parseSrcset(srcset) {
+				add(s, "srcset", href, false)
+			}
+		},
+	)
+
+	doc.Find("meta[http-equiv][content]").Each(
+		func(_ int, s *goquery.Selection) {
+			equiv, _ := s.Attr("http-equiv")
+			if !strings.EqualFold(equiv, "refresh") {
+				return
+			}
+			content, _ := s.Attr("content")
+			if href, ok := parseMetaRefresh(content); ok {
+				add(s, "content", href, true)
+			}
+		},
+	)
+
+	doc.Find("style").Each(func(_ int, s *goquery.Selection) {
+		for _, ref := range cssReferences(s.Text()) {
+			add(s, "", ref.href, false)
+		}
+	})
+	doc.Find("[style]").Each(func(_ int, s *goquery.Selection) {
+		style, _ := s.Attr("style")
+		for _, ref := range cssReferences(style) {
+			add(s, "style", ref.href, false)
+		}
+	})
+
+	return refs
+}
+
+// parseSrcset returns the URLs of the image candidates in a `srcset`
+// attribute, e.g., `a.png 1x, b.png 2x`.
+func parseSrcset(srcset string) []string {
+	var urls []string
+	for {
+		srcset = strings.TrimLeft(srcset, " \t\n\r\f,")
+		if srcset == "" {
+			return urls
+		}
+
+		// the URL runs until whitespace; a trailing comma ends the
+		// candidate without descriptors
+		end := strings.IndexAny(srcset, " \t\n\r\f")
+		if end < 0 {
+			end = len(srcset)
+		}
+		u := srcset[:end]
+		srcset = srcset[end:]
+		if trimmed := strings.TrimRight(u, ","); trimmed != u {
+			urls = append(urls, trimmed)
+			continue
+		}
+		urls = append(urls, u)
+
+		// skip the descriptors
+		if i := strings.IndexByte(srcset, ','); i >= 0 {
+			srcset = srcset[i+1:]
+		} else {
+			srcset = ""
+		}
+	}
+}
+
+// parseMetaRefresh returns the URL in a meta refresh's `content`, e.g.,
+// `5; url=/new-page`.
+func parseMetaRefresh(content string) (string, bool) {
+	_, target, found := strings.Cut(content, ";")
+	if !found {
+		if _, target, found = strings.Cut(content, ","); !found {
+			return "", false
+		}
+	}
+	target = strings.TrimSpace(target)
+	if len(target) >= 4 && strings.EqualFold(target[:4], "url=") {
+		target = strings.TrimSpace(target[4:])
+	}
+	target = strings.Trim(target, `'"`)
+	return target, target != ""
+}
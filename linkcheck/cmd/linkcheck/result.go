@@ -1,10 +1,75 @@
 package main
 
 type Result struct {
-	BaseURL       string `json:"baseURL"`
-	TargetText    string `json:"targetText"`
-	TargetURL     string `json:"targetURL"`
-	URLParseError error  `json:"urlParseError,omitempty"`
-	NetworkError  error  `json:"networkError,omitempty"`
-	StatusCode    int    `json:"statusCode,omitempty"`
+	BaseURL string `json:"baseURL"`
+
+	// Element and Attribute identify where the reference appears on the
+	// base page, e.g., `img` and `src`. Attribute is empty for CSS `url()`s
+	// in `<style>` elements. For references in a stylesheet, Element is
+	// `@import` or `url` and Attribute is empty.
+	Element   string `json:"element,omitempty"`
+	Attribute string `json:"attribute,omitempty"`
+
+	// Line is the line of BaseURL on which the reference appears. It is
+	// only known when checking source files (see [Crawler.CheckFiles]).
+	Line int `json:"line,omitempty"`
+
+	TargetText string `json:"targetText"`
+	TargetURL  string `json:"targetURL"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Attempts   int    `json:"attempts,omitempty"`
+
+	// Cached is true if the outcome was reused from the cache rather than
+	// fetched.
+	Cached bool `json:"cached,omitempty"`
+
+	// Error describes why the link failed, if it did.
+	Error *ResultError `json:"error,omitempty"`
+
+	// MissingFragment is the link's fragment if the target page loaded but
+	// has no element with a matching `id` (or `<a name>`).
+	MissingFragment string `json:"missingFragment,omitempty"`
+
+	// SoftNotFound is true if the target responded successfully but is
+	// probably a "not found" page (see `-soft-404`).
+	SoftNotFound bool `json:"softNotFound,omitempty"`
+
+	// Redirects are the hops followed to reach the target, if any. The last
+	// hop's URL is the final URL.
+	Redirects []Redirect `json:"redirects,omitempty"`
+
+	// RedirectIssue, if set, is the most severe problem with the redirect
+	// chain.
+	RedirectIssue RedirectIssue `json:"redirectIssue,omitempty"`
+
+	// SitemapIssue, if set, indicates that the target is orphaned (listed in
+	// the sitemap at BaseURL but not linked from any crawled page) or
+	// unlisted (crawled but missing from the sitemap at BaseURL).
+	SitemapIssue SitemapIssue `json:"sitemapIssue,omitempty"`
+
+	// InsecureIssue, if set, indicates that the target is referenced over
+	// plain http: as a resource of an https page (mixed content) or as a
+	// link.
+	InsecureIssue InsecureIssue `json:"insecureIssue,omitempty"`
+
+	// HTTPSAvailable is whether the https version of an insecure target
+	// responds, if it was checked (see `-check-https`).
+	HTTPSAvailable *bool `json:"httpsAvailable,omitempty"`
+
+	// PageIssue, if set, is a problem with the content of the page at
+	// BaseURL rather than with a link, found by a [PageChecker]. Element,
+	// Attribute, TargetText and TargetURL describe the element with the
+	// problem, if any.
+	PageIssue PageIssue `json:"pageIssue,omitempty"`
+
+	// Message describes the PageIssue.
+	Message string `json:"message,omitempty"`
+
+	// Severity is the severity of the result's most serious problem, or
+	// empty if it has none.
+	Severity Severity `json:"severity,omitempty"`
+
+	// Baseline, if set, compares the result to the baseline passed via
+	// `-baseline`.
+	Baseline BaselineStatus `json:"baseline,omitempty"`
 }
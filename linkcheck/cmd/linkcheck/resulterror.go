@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// ErrorCode is a stable, machine-readable classification of a failure.
+type ErrorCode string
+
+const (
+	// ErrorParse indicates that a reference isn't a valid URL.
+	ErrorParse ErrorCode = "parse-error"
+
+	// ErrorDNS indicates that the target's host couldn't be resolved.
+	ErrorDNS ErrorCode = "dns"
+
+	// ErrorConnectionRefused indicates that the target's host refused the
+	// connection.
+	ErrorConnectionRefused ErrorCode = "connection-refused"
+
+	// ErrorTLS indicates a TLS handshake or certificate failure.
+	ErrorTLS ErrorCode = "tls"
+
+	// ErrorTimeout indicates that the request timed out.
+	ErrorTimeout ErrorCode = "timeout"
+
+	// ErrorNetwork indicates any other failure to fetch the target.
+	ErrorNetwork ErrorCode = "network-error"
+
+	// ErrorFile indicates a failure to read a `file://` target.
+	ErrorFile ErrorCode = "file-error"
+
+	// ErrorHTTPStatus indicates a non-200 (and not accepted) status code.
+	ErrorHTTPStatus ErrorCode = "http-status"
+
+	// ErrorRedirectLoop indicates a redirect chain which revisits a URL.
+	ErrorRedirectLoop ErrorCode = "redirect-loop"
+
+	// ErrorTooManyRedirects indicates a redirect chain which exceeded
+	// `Crawler.MaxRedirects`.
+	ErrorTooManyRedirects ErrorCode = "redirect-too-many"
+
+	// ErrorFragmentMissing indicates that the target page has no anchor
+	// matching the link's fragment.
+	ErrorFragmentMissing ErrorCode = "fragment-missing"
+
+	// ErrorUndefinedReference indicates a Markdown reference link (e.g.,
+	// `[text][label]`) whose label has no definition.
+	ErrorUndefinedReference ErrorCode = "undefined-reference"
+)
+
+// ResultError describes why a link failed. Unlike an `error`, it survives
+// JSON encoding.
+type ResultError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+func (err *ResultError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Code, err.Message)
+}
+
+// classify converts an error encountered fetching a target into a
+// [ResultError].
+func classify(err error) *ResultError {
+	code := ErrorNetwork
+
+	var (
+		resultErr    *ResultError
+		statusCode   ErrNotOk
+		loop         ErrRedirectLoop
+		tooMany      ErrTooManyRedirects
+		dnsErr       *net.DNSError
+		netErr       net.Error
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		pathErr      *fs.PathError
+	)
+	switch {
+	case errors.As(err, &resultErr):
+		// e.g., a failure read from the cache
+		return resultErr
+	case errors.As(err, &statusCode):
+		return &ResultError{
+			Code:    ErrorHTTPStatus,
+			Message: statusMessage(int(statusCode)),
+		}
+	case errors.As(err, &loop):
+		code = ErrorRedirectLoop
+	case errors.As(err, &tooMany):
+		code = ErrorTooManyRedirects
+	case errors.As(err, &dnsErr):
+		code = ErrorDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		code = ErrorConnectionRefused
+	case errors.As(err, &recordErr),
+		errors.As(err, &alertErr),
+		errors.As(err, &verifyErr),
+		errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &invalidErr):
+		code = ErrorTLS
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		code = ErrorTimeout
+	case errors.As(err, &pathErr):
+		code = ErrorFile
+	}
+	return &ResultError{Code: code, Message: err.Error()}
+}
+
+// parseError converts an error parsing a reference into a [ResultError].
+func parseError(err error) *ResultError {
+	return &ResultError{Code: ErrorParse, Message: err.Error()}
+}
+
+// statusMessage renders a status code, e.g., `404 Not Found`.
+func statusMessage(statusCode int) string {
+	if text := http.StatusText(statusCode); text != "" {
+		return fmt.Sprintf("%d %s", statusCode, text)
+	}
+	return fmt.Sprint(statusCode)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// timeoutError is a [net.Error] which timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassify(t *testing.T) {
+	cached := &ResultError{Code: ErrorDNS, Message: "cached"}
+	for _, tc := range []struct {
+		name    string
+		err     error
+		code    ErrorCode
+		message string
+	}{
+		{
+			name:    "status",
+			err:     ErrNotOk(http.StatusNotFound),
+			code:    ErrorHTTPStatus,
+			message: "404 Not Found",
+		},
+		{
+			name:    "unknown status",
+			err:     fmt.Errorf("fetching: %w", ErrNotOk(999)),
+			code:    ErrorHTTPStatus,
+			message: "999",
+		},
+		{
+			name: "redirect loop",
+			err:  ErrRedirectLoop("https://example.com/"),
+			code: ErrorRedirectLoop,
+		},
+		{
+			name: "too many redirects",
+			err:  ErrTooManyRedirects(10),
+			code: ErrorTooManyRedirects,
+		},
+		{
+			name: "dns",
+			err: &url.Error{
+				Op:  "Get",
+				URL: "https://nowhere.invalid/",
+				Err: &net.DNSError{Err: "no such host", Name: "nowhere"},
+			},
+			code: ErrorDNS,
+		},
+		{
+			name: "connection refused",
+			err: &net.OpError{
+				Op:  "dial",
+				Net: "tcp",
+				Err: os.NewSyscallError("connect", syscall.ECONNREFUSED),
+			},
+			code: ErrorConnectionRefused,
+		},
+		{
+			name: "unknown authority",
+			err:  &url.Error{Err: x509.UnknownAuthorityError{}},
+			code: ErrorTLS,
+		},
+		{
+			name: "tls record",
+			err:  tls.RecordHeaderError{Msg: "not tls"},
+			code: ErrorTLS,
+		},
+		{
+			name: "deadline",
+			err:  fmt.Errorf("reading body: %w", context.DeadlineExceeded),
+			code: ErrorTimeout,
+		},
+		{
+			name: "network timeout",
+			err:  &url.Error{Err: timeoutError{}},
+			code: ErrorTimeout,
+		},
+		{
+			name: "file",
+			err:  &fs.PathError{Op: "open", Path: "/a", Err: fs.ErrNotExist},
+			code: ErrorFile,
+		},
+		{
+			name: "other",
+			err:  errors.New("connection reset"),
+			code: ErrorNetwork,
+		},
+		{name: "cached", err: cached, code: ErrorDNS, message: "cached"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := classify(tc.err)
+			message := tc.message
+			if message == "" {
+				message = tc.err.Error()
+			}
+			if got.Code != tc.code || got.Message != message {
+				t.Fatalf(
+					"wanted %s: %s; got %s: %s",
+					tc.code,
+					message,
+					got.Code,
+					got.Message,
+				)
+			}
+		})
+	}
+}
+
+func TestClassifyFetchErrors(t *testing.T) {
+	// an untrusted certificate
+	tlsServer := httptest.NewUnstartedServer(html("ok"))
+	tlsServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	// a port which nothing listens on
+	closed := httptest.NewServer(html("ok"))
+	closed.Close()
+
+	for _, tc := range []struct {
+		name string
+		url  string
+		code ErrorCode
+	}{
+		{name: "tls", url: tlsServer.URL, code: ErrorTLS},
+		{
+			name: "connection refused",
+			url:  closed.URL,
+			code: ErrorConnectionRefused,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rsp, err := http.Get(tc.url)
+			if err == nil {
+				rsp.Body.Close()
+				t.Fatal("wanted an error")
+			}
+			if got := classify(err); got.Code != tc.code {
+				t.Fatalf("wanted %s; got %v", tc.code, got)
+			}
+		})
+	}
+}
+
+// resultJSON encodes `r` as `-format json` would.
+func resultJSON(t *testing.T, r Result) string {
+	t.Helper()
+	data, err := json.Marshal(&r)
+	if err != nil {
+		t.Fatalf("encoding result: %v", err)
+	}
+	return string(data)
+}
+
+func TestResultErrorJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		result Result
+		error  any
+	}{
+		{
+			name: "failure",
+			result: Result{
+				TargetURL: "/missing",
+				Error: &ResultError{
+					Code:    ErrorHTTPStatus,
+					Message: "404 Not Found",
+				},
+			},
+			error: map[string]any{
+				"code":    "http-status",
+				"message": "404 Not Found",
+			},
+		},
+		{name: "success", result: Result{TargetURL: "/ok"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var encoded map[string]any
+			if err := json.Unmarshal(
+				[]byte(resultJSON(t, tc.result)),
+				&encoded,
+			); err != nil {
+				t.Fatalf("decoding result: %v", err)
+			}
+			if got := encoded["error"]; !reflect.DeepEqual(got, tc.error) {
+				t.Fatalf("wanted error %v; got %v", tc.error, got)
+			}
+
+			var decoded Result
+			if err := json.Unmarshal(
+				[]byte(resultJSON(t, tc.result)),
+				&decoded,
+			); err != nil {
+				t.Fatalf("decoding result: %v", err)
+			}
+			if !reflect.DeepEqual(decoded.Error, tc.result.Error) {
+				t.Fatalf(
+					"wanted error %v; got %v",
+					tc.result.Error,
+					decoded.Error,
+				)
+			}
+		})
+	}
+}
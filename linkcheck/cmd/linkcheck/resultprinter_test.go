@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what `f` prints to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("creating pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-output
+}
+
+// printerResults are results of each severity for the printer tests.
+var printerResults = []Result{
+	{
+		BaseURL:    "https://example.com/",
+		TargetText: "missing",
+		TargetURL:  "/missing",
+		Error: &ResultError{
+			Code:    ErrorHTTPStatus,
+			Message: "404 Not Found",
+		},
+		Severity: SeverityError,
+	},
+	{
+		BaseURL:       "https://example.com/",
+		TargetText:    "old",
+		TargetURL:     "/old",
+		RedirectIssue: RedirectPermanent,
+		Redirects: []Redirect{{
+			StatusCode: 301,
+			URL:        "https://example.com/new",
+		}},
+		Severity: SeverityWarning,
+	},
+	{
+		BaseURL:    "https://example.com/",
+		TargetText: "ok",
+		TargetURL:  "/ok",
+	},
+	{
+		BaseURL:    "docs/a,b:c.md",
+		Line:       12,
+		TargetText: "gone",
+		TargetURL:  "https://example.com/gone",
+		Baseline:   BaselineFixed,
+		Severity:   SeverityInfo,
+	},
+}
+
+// visitAll visits each of `results` with `visitor`.
+func visitAll(visitor ResultVisitor, results []Result) {
+	for i := range results {
+		visitor.Visit(&results[i])
+	}
+}
+
+func TestGitHubResultPrinter(t *testing.T) {
+	output := captureStdout(t, func() {
+		visitAll(&GitHubResultPrinter{}, printerResults)
+	})
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	wanted := []string{
+		"::error title=linkcheck%3A http-status::https://example.com/ " +
+			`<a href="/missing">missing</a>: 404 Not Found`,
+		"::warning title=linkcheck%3A redirect-permanent::",
+		"::notice file=docs/a%2Cb%3Ac.md,line=12," +
+			"title=linkcheck%3A baseline-fixed::docs/a,b:c.md:12 ",
+	}
+	if len(lines) != len(wanted) {
+		t.Fatalf("wanted %d annotations; got:\n%s", len(wanted), output)
+	}
+	for i := range wanted {
+		if !strings.HasPrefix(lines[i], wanted[i]) {
+			t.Fatalf("wanted a line starting %q; got %q", wanted[i], lines[i])
+		}
+	}
+}
+
+func TestEscapeGitHub(t *testing.T) {
+	const s = "50% a:b,c\r\nd"
+	if got := escapeGitHubData(s); got != "50%25 a:b,c%0D%0Ad" {
+		t.Fatalf("escapeGitHubData(): got %q", got)
+	}
+	if got := escapeGitHubProperty(s); got != "50%25 a%3Ab%2Cc%0D%0Ad" {
+		t.Fatalf("escapeGitHubProperty(): got %q", got)
+	}
+}
+
+func TestJUnitResultPrinter(t *testing.T) {
+	printer := NewJUnitResultPrinter()
+	output := captureStdout(t, func() {
+		visitAll(printer, printerResults)
+		if err := printer.Flush(); err != nil {
+			t.Errorf("Flush(): unexpected err: %v", err)
+		}
+	})
+	if !strings.HasPrefix(output, xml.Header) {
+		t.Fatalf("wanted an XML header; got:\n%s", output)
+	}
+
+	var report junitTestSuites
+	if err := xml.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("decoding report: %v\n%s", err, output)
+	}
+	if len(report.TestSuites) != 1 {
+		t.Fatalf("wanted one test suite; got %d", len(report.TestSuites))
+	}
+	suite := report.TestSuites[0]
+	if suite.Name != "linkcheck" || suite.Tests != 4 || suite.Failures != 1 {
+		t.Fatalf(
+			"wanted 4 tests with 1 failure; got %d with %d",
+			suite.Tests,
+			suite.Failures,
+		)
+	}
+
+	for i, tc := range []struct {
+		className string
+		name      string
+		failure   string
+		systemOut string
+	}{
+		{
+			className: "https://example.com/",
+			name:      `<a href="/missing">missing</a>`,
+			failure:   "http-status",
+		},
+		{
+			className: "https://example.com/",
+			name:      `<a href="/old">old</a>`,
+			systemOut: "warning: ",
+		},
+		{className: "https://example.com/", name: `<a href="/ok">ok</a>`},
+		{
+			className: "docs/a,b:c.md",
+			name:      `<a href="https://example.com/gone">gone</a>`,
+			systemOut: "info: ",
+		},
+	} {
+		testCase := suite.TestCases[i]
+		if testCase.ClassName != tc.className || testCase.Name != tc.name {
+			t.Fatalf(
+				"%d: wanted %s %s; got %+v",
+				i,
+				tc.className,
+				tc.name,
+				testCase,
+			)
+		}
+		switch {
+		case tc.failure != "":
+			if testCase.Failure == nil ||
+				testCase.Failure.Type != tc.failure ||
+				!strings.HasSuffix(testCase.Failure.Message, "404 Not Found") {
+				t.Fatalf(
+					"%d: wanted a %s failure; got %+v",
+					i,
+					tc.failure,
+					testCase,
+				)
+			}
+		case testCase.Failure != nil:
+			t.Fatalf("%d: unexpected failure: %+v", i, testCase.Failure)
+		}
+		if tc.systemOut == "" && testCase.SystemOut != "" ||
+			!strings.HasPrefix(testCase.SystemOut, tc.systemOut) {
+			t.Fatalf(
+				"%d: wanted output starting %q; got %q",
+				i,
+				tc.systemOut,
+				testCase.SystemOut,
+			)
+		}
+	}
+}
+
+func TestSARIFResultPrinter(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		results []Result
+		rules   []sarifRule
+		levels  []string
+	}{
+		{
+			name:    "results",
+			results: printerResults,
+			rules: []sarifRule{
+				{ID: "baseline-fixed"},
+				{ID: "http-status"},
+				{ID: "redirect-permanent"},
+			},
+			levels: []string{"error", "warning", "note"},
+		},
+		{name: "no results", rules: []sarifRule{}, levels: []string{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var printer SARIFResultPrinter
+			output := captureStdout(t, func() {
+				visitAll(&printer, tc.results)
+				if err := printer.Flush(); err != nil {
+					t.Errorf("Flush(): unexpected err: %v", err)
+				}
+			})
+
+			var log sarifLog
+			if err := json.Unmarshal([]byte(output), &log); err != nil {
+				t.Fatalf("decoding log: %v\n%s", err, output)
+			}
+			if log.Version != "2.1.0" || len(log.Runs) != 1 {
+				t.Fatalf("wanted one SARIF 2.1.0 run; got:\n%s", output)
+			}
+			run := log.Runs[0]
+			if !reflect.DeepEqual(run.Tool.Driver.Rules, tc.rules) {
+				t.Fatalf(
+					"wanted rules %+v; got %+v",
+					tc.rules,
+					run.Tool.Driver.Rules,
+				)
+			}
+			if !strings.Contains(output, `"results": [`) {
+				t.Fatalf("wanted a results array; got:\n%s", output)
+			}
+			levels := []string{}
+			for _, result := range run.Results {
+				levels = append(levels, result.Level)
+			}
+			if !reflect.DeepEqual(levels, tc.levels) {
+				t.Fatalf("wanted levels %v; got %v", tc.levels, levels)
+			}
+		})
+	}
+}
+
+func TestSARIFLocations(t *testing.T) {
+	var printer SARIFResultPrinter
+	output := captureStdout(t, func() {
+		visitAll(&printer, printerResults)
+		if err := printer.Flush(); err != nil {
+			t.Errorf("Flush(): unexpected err: %v", err)
+		}
+	})
+	var log sarifLog
+	if err := json.Unmarshal([]byte(output), &log); err != nil {
+		t.Fatalf("decoding log: %v\n%s", err, output)
+	}
+
+	wanted := []sarifPhysicalLocation{
+		{
+			ArtifactLocation: sarifArtifactLocation{
+				URI: "https://example.com/",
+			},
+		},
+		{
+			ArtifactLocation: sarifArtifactLocation{
+				URI: "https://example.com/",
+			},
+		},
+		{
+			ArtifactLocation: sarifArtifactLocation{URI: "docs/a,b:c.md"},
+			Region:           &sarifRegion{StartLine: 12},
+		},
+	}
+	results := log.Runs[0].Results
+	if len(results) != len(wanted) {
+		t.Fatalf("wanted %d results; got %d", len(wanted), len(results))
+	}
+	for i, result := range results {
+		if len(result.Locations) != 1 || !reflect.DeepEqual(
+			result.Locations[0].PhysicalLocation,
+			wanted[i],
+		) {
+			t.Fatalf("%d: wanted location %+v; got %+v", i, wanted[i], result)
+		}
+		if result.Message.Text == "" {
+			t.Fatalf("%d: wanted a message", i)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// do sends `req`, retrying with exponential backoff if the server responds
+// with a transient status (429, 502, 503, or 504) or the request times out. A
+// `Retry-After` header on a transient response overrides the backoff delay
+// and pauses every request to the host. The returned `release` function must
+// be called once the response body has been consumed. Redirects are returned
+// rather than followed (see [Crawler.follow]). Nothing is retried once the
+// request's context is done, e.g., because the overall timeout expired.
+func (crawler *Crawler) do(
+	req *http.Request,
+) (rsp *http.Response, release func(), attempts int, err error) {
+	client := *crawler.Client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	if client.Jar == nil {
+		client.Jar = crawler.Cookies
+	}
+
+	// apply the policy to a copy so that its headers and credentials aren't
+	// copied to redirects (which may be to other domains)
+	if policy := crawler.domainPolicy(req.URL.Host); policy != nil {
+		req = req.Clone(req.Context())
+		for key, value := range policy.Headers {
+			req.Header.Set(key, value)
+		}
+		if policy.Auth != nil &&
+			(req.URL.Scheme == "https" || policy.InsecureAuth) {
+			policy.Auth.apply(req)
+		}
+	}
+	timeout := crawler.timeout(req.URL.Host)
+
+	h := crawler.hostState(req.URL.Host)
+	for attempts = 1; ; attempts++ {
+		release = h.acquire()
+		if timeout > 0 {
+			// the timeout covers reading the body, so it is only canceled
+			// when the slot is released
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			releaseSlot := release
+			release = func() { cancel(); releaseSlot() }
+			rsp, err = client.Do(req.WithContext(ctx))
+		} else {
+			rsp, err = client.Do(req)
+		}
+
+		// once the crawl's overall timeout expires, every retry would fail
+		// too
+		if attempts > crawler.MaxRetries ||
+			req.Context().Err() != nil ||
+			!retryable(rsp, err) {
+			return
+		}
+
+		delay := crawler.backoff(attempts)
+		if after, ok := retryAfter(rsp); ok {
+			if after > crawler.MaxRetryDelay {
+				// the server wants us to wait longer than we're willing
+				// to, so report the response as-is
+				return
+			}
+			delay = after
+			h.pause(time.Now().Add(after))
+		}
+
+		if rsp != nil {
+			io.Copy(io.Discard, rsp.Body)
+			rsp.Body.Close()
+		}
+		release()
+		if err = sleep(req.Context(), delay); err != nil {
+			return nil, func() {}, attempts, err
+		}
+	}
+}
+
+// sleep waits for `delay`, returning early with the context's error if `ctx`
+// is canceled first.
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns the delay before retry number `attempt`: `RetryBackoff`
+// doubled for each prior attempt, capped at `MaxRetryDelay`, with up to 50%
+// random jitter so that concurrent retries don't stampede the host.
+func (crawler *Crawler) backoff(attempt int) time.Duration {
+	delay := crawler.RetryBackoff << (attempt - 1)
+	if delay <= 0 || delay > crawler.MaxRetryDelay {
+		delay = crawler.MaxRetryDelay
+	}
+	if delay > 1 {
+		delay = delay/2 + rand.N(delay/2)
+	}
+	return delay
+}
+
+func retryable(rsp *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr) && netErr.Timeout()
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter parses the `Retry-After` header, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(rsp *http.Response) (time.Duration, bool) {
+	if rsp == nil {
+		return 0, false
+	}
+	value := rsp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsUserAgent is the product token matched against robots.txt
+// `User-agent` lines.
+const robotsUserAgent = "linkcheck"
+
+// robots is the subset of a robots.txt file which applies to linkcheck.
+type robots struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsEntry is a cached robots.txt. `ready` is closed once `robots` has
+// been fetched.
+type robotsEntry struct {
+	ready  chan struct{}
+	robots *robots
+}
+
+var (
+	robotsAllowAll    = &robots{}
+	robotsDisallowAll = &robots{
+		rules: []robotsRule{{allow: false, pattern: "/"}},
+	}
+)
+
+// robots returns the robots.txt rules for the host of `u`, fetching them on
+// first use. Concurrent callers for the same host wait for a single fetch.
+func (crawler *Crawler) robots(u *url.URL) *robots {
+	key := u.Scheme + "://" + u.Host
+
+	crawler.mutex.Lock()
+	entry, exists := crawler.robotsCache[key]
+	if !exists {
+		entry = &robotsEntry{ready: make(chan struct{})}
+		crawler.robotsCache[key] = entry
+	}
+	crawler.mutex.Unlock()
+
+	if exists {
+		<-entry.ready
+		return entry.robots
+	}
+
+	entry.robots = crawler.fetchRobots(key + "/robots.txt")
+	if entry.robots.crawlDelay > 0 {
+		crawler.hostState(u.Host).setMinInterval(entry.robots.crawlDelay)
+	}
+	close(entry.ready)
+	return entry.robots
+}
+
+// fetchRobots fetches and parses the robots.txt at `robotsURL`. Following
+// RFC 9309, a missing robots.txt (4xx) allows everything while an
+// unreachable one (5xx or a network error) disallows everything.
+func (crawler *Crawler) fetchRobots(robotsURL string) *robots {
+	req, err := crawler.newRequest(http.MethodGet, robotsURL)
+	if err != nil {
+		return robotsAllowAll
+	}
+
+	rsp, release, _, _, err := crawler.follow(req)
+	defer release()
+	if err != nil {
+		return robotsDisallowAll
+	}
+	defer rsp.Body.Close()
+
+	switch {
+	case rsp.StatusCode >= 500:
+		return robotsDisallowAll
+	case rsp.StatusCode >= 400:
+		return robotsAllowAll
+	case rsp.StatusCode != http.StatusOK:
+		return robotsAllowAll
+	}
+
+	// RFC 9309 requires parsing at least 500KiB
+	return parseRobots(io.LimitReader(rsp.Body, 512*1024), robotsUserAgent)
+}
+
+// parseRobots parses the rules in a robots.txt which apply to `userAgent`,
+// falling back to the rules for `*` if no group names `userAgent` exactly
+// (ignoring case).
+func parseRobots(r io.Reader, userAgent string) *robots {
+	var (
+		specific, wildcard robots
+		foundSpecific      bool
+		inSpecific         bool
+		inWildcard         bool
+		inAgents           bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			// consecutive user-agent lines share a group
+			if !inAgents {
+				inSpecific, inWildcard = false, false
+				inAgents = true
+			}
+			// RFC 9309 matches the product token case-insensitively; an
+			// empty user agent matches nothing
+			if value == "*" {
+				inWildcard = true
+			} else if value != "" && strings.EqualFold(value, userAgent) {
+				inSpecific = true
+				foundSpecific = true
+			}
+			continue
+		}
+		inAgents = false
+
+		var rule *robotsRule
+		switch key {
+		case "allow":
+			rule = &robotsRule{allow: true, pattern: value}
+		case "disallow":
+			// an empty disallow allows everything
+			if value == "" {
+				continue
+			}
+			rule = &robotsRule{allow: false, pattern: value}
+		case "crawl-delay":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			delay := time.Duration(seconds * float64(time.Second))
+			if inSpecific {
+				specific.crawlDelay = delay
+			}
+			if inWildcard {
+				wildcard.crawlDelay = delay
+			}
+			continue
+		default:
+			continue
+		}
+
+		if inSpecific {
+			specific.rules = append(specific.rules, *rule)
+		}
+		if inWildcard {
+			wildcard.rules = append(wildcard.rules, *rule)
+		}
+	}
+
+	if foundSpecific {
+		return &specific
+	}
+	return &wildcard
+}
+
+// allowed returns true if the rules permit crawling `u`. The rule with the
+// longest matching pattern wins, and an allow rule wins a tie.
+func (robots *robots) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	allowed, longest := true, -1
+	for _, rule := range robots.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > longest ||
+			len(rule.pattern) == longest && rule.allow {
+			allowed, longest = rule.allow, len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// robotsMatch reports whether `path` matches `pattern`, where `*` matches any
+// sequence of characters and a trailing `$` anchors the end of the path.
+// Patterns otherwise match prefixes.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+
+	if !anchored || rest == "" {
+		return true
+	}
+
+	// the pattern is anchored but the greedy-leftmost match left a suffix;
+	// an anchored pattern ending in a literal can still match if the path
+	// ends with that literal
+	last := parts[len(parts)-1]
+	return len(parts) > 1 && strings.HasSuffix(path, last)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRobots(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		robots  string
+		allowed []string
+		denied  []string
+	}{
+		{
+			name:    "wildcard",
+			robots:  "User-agent: *\nDisallow: /private\n",
+			allowed: []string{"/", "/public"},
+			denied:  []string{"/private", "/private/page"},
+		},
+		{
+			name: "specific group overrides wildcard",
+			robots: "User-agent: *\nDisallow: /\n\n" +
+				"User-agent: linkcheck\nDisallow: /private\n",
+			allowed: []string{"/", "/public"},
+			denied:  []string{"/private"},
+		},
+		{
+			name: "product token is case-insensitive",
+			robots: "User-agent: *\nDisallow: /\n\n" +
+				"User-agent: LinkCheck\nDisallow: /private\n",
+			allowed: []string{"/public"},
+			denied:  []string{"/private"},
+		},
+		{
+			name: "shared group",
+			robots: "User-agent: other\nUser-agent: linkcheck\n" +
+				"Disallow: /private\n",
+			allowed: []string{"/public"},
+			denied:  []string{"/private"},
+		},
+		{
+			name: "empty user agent matches nothing",
+			robots: "User-agent:\nDisallow: /\n\n" +
+				"User-agent: *\nDisallow: /private\n",
+			allowed: []string{"/", "/public"},
+			denied:  []string{"/private"},
+		},
+		{
+			name: "prefix of the product token",
+			robots: "User-agent: link\nDisallow: /\n\n" +
+				"User-agent: l\nDisallow: /\n\n" +
+				"User-agent: *\nDisallow: /private\n",
+			allowed: []string{"/public"},
+			denied:  []string{"/private"},
+		},
+		{
+			name: "product token is a prefix",
+			robots: "User-agent: linkchecker\nDisallow: /\n\n" +
+				"User-agent: *\nDisallow: /private\n",
+			allowed: []string{"/public"},
+			denied:  []string{"/private"},
+		},
+		{
+			name: "longest match wins",
+			robots: "User-agent: *\nDisallow: /docs\n" +
+				"Allow: /docs/public\nDisallow: /*.pdf$\n",
+			allowed: []string{"/docs/public/page", "/report.pdf?download"},
+			denied:  []string{"/docs/private", "/report.pdf"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			robots := parseRobots(strings.NewReader(tc.robots), robotsUserAgent)
+			for _, path := range tc.allowed {
+				if !robots.allowed(mustParse(path)) {
+					t.Fatalf("wanted `%s` allowed", path)
+				}
+			}
+			for _, path := range tc.denied {
+				if robots.allowed(mustParse(path)) {
+					t.Fatalf("wanted `%s` disallowed", path)
+				}
+			}
+		})
+	}
+}
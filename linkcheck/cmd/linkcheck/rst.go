@@ -0,0 +1,143 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// rstHyperlink matches an embedded hyperlink: text and a URL in angle
+	// brackets, enclosed in backticks and followed by `_` or `__`.
+	rstHyperlink = regexp.MustCompile("`([^`<]*?)\\s*<([^<>`]+)>`__?")
+
+	// rstTarget matches a hyperlink target, e.g.,
+	// `.. _label: https://example.com`, or an internal target, e.g.,
+	// `.. _label:`.
+	rstTarget = regexp.MustCompile(`^\s*\.\.\s+_([^:]+):\s*(\S*)\s*$`)
+
+	// rstImage matches an image or figure directive.
+	rstImage = regexp.MustCompile(`^\s*\.\.\s+(?:image|figure)::\s*(\S+)`)
+
+	rstInlineLiteral = regexp.MustCompile("``[^`]+``")
+
+	// rstDirective matches a directive, e.g., `.. note::`.
+	rstDirective = regexp.MustCompile(`^\s*\.\.\s+[\w-]+::`)
+
+	// rstCodeBlock matches a directive whose content is code.
+	rstCodeBlock = regexp.MustCompile(
+		`^\s*\.\.\s+(?:code-block|code|sourcecode)::`,
+	)
+)
+
+// parseRST returns the links in a reStructuredText document (embedded
+// hyperlinks, hyperlink targets, images and figures, and bare URLs) and its
+// anchors: the ids of its section titles and internal targets, as generated
+// by docutils. Literal blocks (introduced by `::`) and the contents of code
+// directives are ignored.
+func parseRST(content string) *sourceFile {
+	source := sourceFile{anchors: map[string]struct{}{}}
+	lines := strings.Split(content, "\n")
+
+	// literal is the indentation of the line introducing the current literal
+	// block, whose lines are indented beyond it, or -1 outside of one
+	literal := -1
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		lineNumber := i + 1
+
+		if literal >= 0 {
+			if strings.TrimSpace(line) == "" || rstIndent(line) > literal {
+				continue
+			}
+			literal = -1
+		}
+		if rstCodeBlock.MatchString(line) ||
+			strings.HasSuffix(strings.TrimRight(line, " \t"), "::") &&
+				!rstDirective.MatchString(line) {
+			literal = rstIndent(line)
+		}
+
+		add := func(element, attribute, text, href string) {
+			source.links = append(source.links, sourceLink{
+				line:      lineNumber,
+				element:   element,
+				attribute: attribute,
+				text:      text,
+				href:      href,
+			})
+		}
+
+		// a section title is underlined with punctuation at least as long
+		// as the title
+		if i+1 < len(lines) && strings.TrimSpace(line) != "" {
+			underline := strings.TrimRight(lines[i+1], "\r\t ")
+			if len(underline) >= len(strings.TrimSpace(line)) &&
+				isRSTUnderline(underline) &&
+				!isRSTUnderline(line) {
+				source.anchors[rstID(line)] = struct{}{}
+			}
+		}
+
+		if match := rstTarget.FindStringSubmatch(line); match != nil {
+			if match[2] == "" {
+				source.anchors[rstID(match[1])] = struct{}{}
+			} else {
+				add("a", "href", match[1], match[2])
+			}
+			continue
+		}
+		if match := rstImage.FindStringSubmatch(line); match != nil {
+			add("img", "src", "", match[1])
+			continue
+		}
+
+		line = rstInlineLiteral.ReplaceAllStringFunc(line, func(s string) string {
+			return strings.Repeat(" ", len(s))
+		})
+		for _, match := range rstHyperlink.FindAllStringSubmatchIndex(line, -1) {
+			href := line[match[4]:match[5]]
+			add("a", "href", line[match[2]:match[3]], href)
+			line = blank(line, match[0], match[1])
+		}
+		for _, match := range bareURL.FindAllStringIndex(line, -1) {
+			href := trimURLPunctuation(line[match[0]:match[1]])
+			add("a", "href", href, href)
+		}
+	}
+	return &source
+}
+
+// rstID returns the id which docutils generates for a section title or
+// target: lowercase, with runs of anything other than letters and digits
+// replaced by hyphens.
+func rstID(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
+// rstIndent returns the width of the indentation of `line`.
+func rstIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// isRSTUnderline returns true if `line` could underline (or overline) a
+// section title: a repeated punctuation character.
+func isRSTUnderline(line string) bool {
+	line = strings.TrimRight(line, "\r\t ")
+	if line == "" || !strings.ContainsRune("=-`:'\"~^_*+#<>.", rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseRST(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		rst     string
+		links   []string
+		anchors []string
+	}{
+		{
+			name: "hyperlinks",
+			rst: "See `the docs <https://example.com/docs>`_ and " +
+				"`anonymous <other.rst>`__.\n" +
+				"`<bare.rst>`_ and https://example.com/bare.\n",
+			links: []string{
+				`1 a href "the docs" https://example.com/docs`,
+				`1 a href "anonymous" other.rst`,
+				`2 a href "" bare.rst`,
+				`2 a href "https://example.com/bare" ` +
+					`https://example.com/bare`,
+			},
+		},
+		{
+			name: "targets",
+			rst: ".. _external: https://example.com/ext\n" +
+				".. _Internal Target:\n",
+			links:   []string{`1 a href "external" https://example.com/ext`},
+			anchors: []string{"internal-target"},
+		},
+		{
+			name: "images and figures",
+			rst: ".. image:: img/logo.png\n" +
+				"   :alt: logo\n" +
+				".. figure:: fig.png\n",
+			links: []string{
+				`1 img src "" img/logo.png`,
+				`3 img src "" fig.png`,
+			},
+		},
+		{
+			name: "inline literals",
+			rst:  "``https://example.com/literal`` is code\n",
+		},
+		{
+			name: "literal blocks",
+			rst: "Example::\n\n" +
+				"    `x <a.rst>`_\n" +
+				"    https://example.com/literal\n\n" +
+				"After `y <b.rst>`_\n\n" +
+				".. code-block:: python\n" +
+				"   :linenos:\n\n" +
+				"   url = \"https://example.com/code\"\n\n" +
+				".. note::\n\n" +
+				"   `z <c.rst>`_\n\n" +
+				"::\n\n" +
+				"  https://example.com/expanded\n",
+			links: []string{
+				`6 a href "y" b.rst`,
+				`15 a href "z" c.rst`,
+			},
+		},
+		{
+			name: "section titles",
+			rst: "Title\n=====\n\n" +
+				"=========\nOverlined\n=========\n\n" +
+				"Section Two!\n------------------\n\n" +
+				"Too short\n---\n",
+			anchors: []string{"overlined", "section-two", "title"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			source := parseRST(tc.rst)
+			if links := describeLinks(source); !slices.Equal(
+				links,
+				tc.links,
+			) {
+				t.Fatalf("wanted links %q; found %q", tc.links, links)
+			}
+			if anchors := sortedAnchors(source); !slices.Equal(
+				anchors,
+				tc.anchors,
+			) {
+				t.Fatalf("wanted anchors %q; found %q", tc.anchors, anchors)
+			}
+		})
+	}
+}
+
+func TestRSTID(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		wanted string
+	}{
+		{name: "Title", wanted: "title"},
+		{name: "Section Two!", wanted: "section-two"},
+		{name: "API v2.0 (beta)", wanted: "api-v2-0-beta"},
+		{name: "  --leading and trailing--  ", wanted: "leading-and-trailing"},
+		{name: "snake_case", wanted: "snake-case"},
+		{name: "!!!", wanted: ""},
+	} {
+		if id := rstID(tc.name); id != tc.wanted {
+			t.Errorf(
+				"rstID(%q): wanted `%s`; found `%s`",
+				tc.name,
+				tc.wanted,
+				id,
+			)
+		}
+	}
+}
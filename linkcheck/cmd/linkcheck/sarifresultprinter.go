@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// SARIFResultPrinter collects problems and prints them as a SARIF 2.1.0 log
+// on `Flush`. Each category (see [DefaultSeverities]) is a rule, and each
+// problem is located at the page containing the link.
+type SARIFResultPrinter struct {
+	rules   []string
+	results []sarifResult
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+func (printer *SARIFResultPrinter) Visit(r *Result) {
+	var level string
+	switch r.Severity {
+	case SeverityError:
+		level = "error"
+	case SeverityWarning:
+		level = "warning"
+	case SeverityInfo:
+		level = "note"
+	default:
+		return
+	}
+
+	rule := category(r)
+	if !slices.Contains(printer.rules, rule) {
+		printer.rules = append(printer.rules, rule)
+	}
+	_, message := describe(r)
+	location := sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: r.BaseURL},
+	}
+	if r.Line > 0 {
+		location.Region = &sarifRegion{StartLine: r.Line}
+	}
+	printer.results = append(printer.results, sarifResult{
+		RuleID:    rule,
+		Level:     level,
+		Message:   sarifMessage{Text: message},
+		Locations: []sarifLocation{{PhysicalLocation: location}},
+	})
+}
+
+// Flush prints the log.
+func (printer *SARIFResultPrinter) Flush() error {
+	slices.Sort(printer.rules)
+	rules := make([]sarifRule, len(printer.rules))
+	for i, rule := range printer.rules {
+		rules[i] = sarifRule{ID: rule}
+	}
+
+	results := printer.results
+	if results == nil {
+		// SARIF requires an array, even if it's empty
+		results = []sarifResult{}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifDriver{Name: "linkcheck", Rules: rules},
+			},
+			Results: results,
+		}},
+	}); err != nil {
+		return fmt.Errorf("printing SARIF log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Scope determines which URLs are part of the site (and thus crawled) and
+// which are checked at all.
+type Scope struct {
+	// Hosts are additional hosts which are part of the site, e.g., the
+	// `www.` or apex variant of the base URL's host.
+	Hosts []string
+
+	// Prefixes, if set, restricts the site to URLs whose paths begin with
+	// one of these prefixes, e.g., for a site mounted under `/docs/`.
+	Prefixes []string
+
+	// Include, if set, restricts checking to URLs which match at least one
+	// of these patterns.
+	Include []Pattern
+
+	// Exclude prevents URLs which match any of these patterns from being
+	// checked (or reported).
+	Exclude []Pattern
+
+	// CrawlInclude, if set, restricts crawling to pages which match at
+	// least one of these patterns. Links on other internal pages are not
+	// followed, but the pages themselves are still checked.
+	CrawlInclude []Pattern
+
+	// CrawlExclude prevents links on pages which match any of these
+	// patterns from being followed.
+	CrawlExclude []Pattern
+
+	// MaxDepth is the maximum number of links between the base URL and a
+	// crawled page. Zero means unlimited.
+	MaxDepth int
+
+	// MaxPages is the maximum number of pages crawled. Zero means
+	// unlimited.
+	MaxPages int
+}
+
+// internal returns true if `u` is part of the site whose primary host is
+// `host`.
+func (scope *Scope) internal(host string, u *url.URL) bool {
+	if u.Host != host && !slices.Contains(scope.Hosts, u.Host) {
+		return false
+	}
+	if len(scope.Prefixes) < 1 {
+		return true
+	}
+	path := u.EscapedPath()
+	for _, prefix := range scope.Prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns true if `u` should be checked.
+func (scope *Scope) check(u *url.URL) bool {
+	return matchRules(scope.Include, scope.Exclude, u)
+}
+
+// crawl returns true if the links on the page at `u` should be followed.
+func (scope *Scope) crawl(u *url.URL) bool {
+	return matchRules(scope.CrawlInclude, scope.CrawlExclude, u)
+}
+
+func matchRules(include, exclude []Pattern, u *url.URL) bool {
+	for _, pattern := range exclude {
+		if pattern.Match(u) {
+			return false
+		}
+	}
+	if len(include) < 1 {
+		return true
+	}
+	for _, pattern := range include {
+		if pattern.Match(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pattern matches URLs. See [ParsePattern].
+type Pattern struct {
+	source string
+	regexp *regexp.Regexp
+
+	// path is true if the pattern matches the URL's path rather than the
+	// whole URL.
+	path bool
+}
+
+// ParsePattern parses a URL pattern. Patterns beginning with `re:` are
+// regular expressions which match anywhere in the URL. Otherwise the pattern
+// is a glob in which `*` matches any run of characters and `?` matches any
+// single character; globs beginning with `/` match the URL's path (e.g.,
+// `/api/*`) while others match the whole URL (e.g., `https://*.example.com/*`).
+func ParsePattern(source string) (Pattern, error) {
+	if expr, found := strings.CutPrefix(source, "re:"); found {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return Pattern{}, fmt.Errorf("parsing pattern `%s`: %w", source, err)
+		}
+		return Pattern{source: source, regexp: re}, nil
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range source {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return Pattern{
+		source: source,
+		regexp: regexp.MustCompile(expr.String()),
+		path:   strings.HasPrefix(source, "/"),
+	}, nil
+}
+
+// Match returns true if `u` matches the pattern.
+func (pattern Pattern) Match(u *url.URL) bool {
+	if pattern.path {
+		path := u.EscapedPath()
+		if u.RawQuery != "" {
+			path += "?" + u.RawQuery
+		}
+		return pattern.regexp.MatchString(path)
+	}
+	return pattern.regexp.MatchString(u.String())
+}
+
+func (pattern Pattern) String() string { return pattern.source }
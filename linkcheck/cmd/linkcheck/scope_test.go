@@ -0,0 +1,223 @@
+package main
+
+import "testing"
+
+func TestParsePattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		url     string
+		match   bool
+	}{
+		{pattern: "/api/*", url: "https://example.com/api/users", match: true},
+		{pattern: "/api/*", url: "https://example.com/api/", match: true},
+		{pattern: "/api/*", url: "https://example.com/api", match: false},
+		{pattern: "/api/*", url: "https://example.com/v1/api/x", match: false},
+		{
+			// path globs include the query
+			pattern: "/search",
+			url:     "https://example.com/search?q=x",
+			match:   false,
+		},
+		{
+			pattern: "/search?q=*",
+			url:     "https://example.com/search?q=x",
+			match:   true,
+		},
+		{pattern: "/page?", url: "https://example.com/page1", match: true},
+		{pattern: "/page?", url: "https://example.com/page10", match: false},
+		{
+			// the path is matched escaped
+			pattern: "/a%20b",
+			url:     "https://example.com/a%20b",
+			match:   true,
+		},
+		{
+			// glob metacharacters are otherwise literal
+			pattern: "/v1.0/*",
+			url:     "https://example.com/v1x0/",
+			match:   false,
+		},
+		{
+			pattern: "https://*.example.com/*",
+			url:     "https://docs.example.com/page",
+			match:   true,
+		},
+		{
+			pattern: "https://*.example.com/*",
+			url:     "http://docs.example.com/page",
+			match:   false,
+		},
+		{
+			pattern: "http://localhost*",
+			url:     "http://localhost:8080/",
+			match:   true,
+		},
+		{
+			// regular expressions match anywhere
+			pattern: `re:\.pdf$`,
+			url:     "https://example.com/files/report.pdf",
+			match:   true,
+		},
+		{
+			pattern: `re:\.pdf$`,
+			url:     "https://example.com/report.pdf?download",
+			match:   false,
+		},
+		{
+			pattern: "re:^https://example\\.com/private/",
+			url:     "https://example.com/private/page",
+			match:   true,
+		},
+		{
+			pattern: "re:^https://example\\.com/private/",
+			url:     "https://example.com/public/private/",
+			match:   false,
+		},
+	} {
+		t.Run(tc.pattern+" "+tc.url, func(t *testing.T) {
+			pattern, err := ParsePattern(tc.pattern)
+			if err != nil {
+				t.Fatalf("ParsePattern(): unexpected err: %v", err)
+			}
+			if pattern.String() != tc.pattern {
+				t.Fatalf("wanted String() %q; got %q", tc.pattern, pattern)
+			}
+			if got := pattern.Match(mustParse(tc.url)); got != tc.match {
+				t.Fatalf("wanted match: %t; got %t", tc.match, got)
+			}
+		})
+	}
+
+	if _, err := ParsePattern("re:("); err == nil {
+		t.Fatal("ParsePattern(): wanted an error for an invalid regexp")
+	}
+}
+
+// mustParsePatterns parses `sources`, panicking on error.
+func mustParsePatterns(sources ...string) []Pattern {
+	var patterns []Pattern
+	for _, source := range sources {
+		pattern, err := ParsePattern(source)
+		if err != nil {
+			panic(err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+func TestScope(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		scope    Scope
+		url      string
+		internal bool
+		check    bool
+		crawl    bool
+	}{
+		{
+			name:     "primary host",
+			url:      "https://example.com/page",
+			internal: true,
+			check:    true,
+			crawl:    true,
+		},
+		{
+			name:  "other host",
+			url:   "https://other.com/page",
+			check: true,
+			crawl: true,
+		},
+		{
+			name:     "additional host",
+			scope:    Scope{Hosts: []string{"www.example.com"}},
+			url:      "https://www.example.com/page",
+			internal: true,
+			check:    true,
+			crawl:    true,
+		},
+		{
+			name:     "prefix",
+			scope:    Scope{Prefixes: []string{"/docs/", "/blog/"}},
+			url:      "https://example.com/blog/post",
+			internal: true,
+			check:    true,
+			crawl:    true,
+		},
+		{
+			name:  "outside of the prefixes",
+			scope: Scope{Prefixes: []string{"/docs/"}},
+			url:   "https://example.com/pricing",
+			check: true,
+			crawl: true,
+		},
+		{
+			name:  "prefix on another host",
+			scope: Scope{Prefixes: []string{"/docs/"}},
+			url:   "https://other.com/docs/page",
+			check: true,
+			crawl: true,
+		},
+		{
+			name:     "excluded",
+			scope:    Scope{Exclude: mustParsePatterns("/private/*")},
+			url:      "https://example.com/private/page",
+			internal: true,
+			crawl:    true,
+		},
+		{
+			name:     "not included",
+			scope:    Scope{Include: mustParsePatterns("/docs/*")},
+			url:      "https://example.com/pricing",
+			internal: true,
+			crawl:    true,
+		},
+		{
+			name: "exclude overrides include",
+			scope: Scope{
+				Include: mustParsePatterns("/docs/*"),
+				Exclude: mustParsePatterns("/docs/old/*"),
+			},
+			url:      "https://example.com/docs/old/page",
+			internal: true,
+			crawl:    true,
+		},
+		{
+			name: "one of several includes",
+			scope: Scope{
+				Include: mustParsePatterns("/docs/*", "/blog/*"),
+			},
+			url:      "https://example.com/blog/post",
+			internal: true,
+			check:    true,
+			crawl:    true,
+		},
+		{
+			name:     "crawl excluded",
+			scope:    Scope{CrawlExclude: mustParsePatterns("/archive/*")},
+			url:      "https://example.com/archive/2020",
+			internal: true,
+			check:    true,
+		},
+		{
+			name:     "crawl not included",
+			scope:    Scope{CrawlInclude: mustParsePatterns("/docs/*")},
+			url:      "https://example.com/blog/post",
+			internal: true,
+			check:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u := mustParse(tc.url)
+			if got := tc.scope.internal("example.com", u); got != tc.internal {
+				t.Fatalf("wanted internal: %t; got %t", tc.internal, got)
+			}
+			if got := tc.scope.check(u); got != tc.check {
+				t.Fatalf("wanted check: %t; got %t", tc.check, got)
+			}
+			if got := tc.scope.crawl(u); got != tc.crawl {
+				t.Fatalf("wanted crawl: %t; got %t", tc.crawl, got)
+			}
+		})
+	}
+}
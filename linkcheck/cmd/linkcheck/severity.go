@@ -0,0 +1,152 @@
+package main
+
+import "fmt"
+
+// Severity is how seriously a problematic result is taken. Only errors cause
+// linkcheck to exit with a failure.
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+
+	// SeverityIgnore suppresses results entirely.
+	SeverityIgnore Severity = "ignore"
+)
+
+// AtLeast returns true if `severity` is at least as severe as `min`.
+func (severity Severity) AtLeast(min Severity) bool {
+	return severity.rank() >= min.rank()
+}
+
+func (severity Severity) rank() int {
+	switch severity {
+	case SeverityInfo:
+		return 1
+	case SeverityWarning:
+		return 2
+	case SeverityError:
+		return 3
+	default:
+		return 0
+	}
+}
+
+func (severity *Severity) UnmarshalText(text []byte) error {
+	switch s := Severity(text); s {
+	case SeverityInfo, SeverityWarning, SeverityError, SeverityIgnore:
+		*severity = s
+		return nil
+	default:
+		return fmt.Errorf(
+			"invalid severity `%s`: must be one of error, warning, info, or "+
+				"ignore",
+			text,
+		)
+	}
+}
+
+// Categories of problematic results which aren't failures (and so have no
+// [ErrorCode]). Severities are assigned to these and to error codes.
+const (
+	CategoryRedirectPermanent = "redirect-permanent"
+	CategoryRedirectLong      = "redirect-long"
+	CategoryRedirectDowngrade = "redirect-downgrade"
+	CategorySitemapOrphan     = "sitemap-orphan"
+	CategorySitemapUnlisted   = "sitemap-unlisted"
+	CategoryInsecureMixed     = "insecure-mixed-content"
+	CategoryInsecureLink      = "insecure-link"
+	CategorySoft404           = "soft-404"
+
+	CategoryPageMissingAlt         = "page-" + string(PageMissingAlt)
+	CategoryPageEmptyLinkText      = "page-" + string(PageEmptyLinkText)
+	CategoryPageDuplicateID        = "page-" + string(PageDuplicateID)
+	CategoryPageMissingTitle       = "page-" + string(PageMissingTitle)
+	CategoryPageMissingDescription = "page-" +
+		string(PageMissingDescription)
+	CategoryPageBrokenCanonical = "page-" + string(PageBrokenCanonical)
+
+	// CategoryBaselineFixed is assigned by [BaselineVisitor] rather than
+	// configured.
+	CategoryBaselineFixed = "baseline-fixed"
+)
+
+// DefaultSeverities are the severities of each category unless overridden.
+var DefaultSeverities = map[string]Severity{
+	string(ErrorParse):              SeverityError,
+	string(ErrorDNS):                SeverityError,
+	string(ErrorConnectionRefused):  SeverityError,
+	string(ErrorTLS):                SeverityError,
+	string(ErrorTimeout):            SeverityError,
+	string(ErrorNetwork):            SeverityError,
+	string(ErrorFile):               SeverityError,
+	string(ErrorHTTPStatus):         SeverityError,
+	string(ErrorRedirectLoop):       SeverityError,
+	string(ErrorTooManyRedirects):   SeverityError,
+	string(ErrorFragmentMissing):    SeverityError,
+	string(ErrorUndefinedReference): SeverityError,
+	CategoryRedirectPermanent:       SeverityWarning,
+	CategoryRedirectLong:            SeverityWarning,
+	CategoryRedirectDowngrade:       SeverityWarning,
+	CategorySitemapOrphan:           SeverityWarning,
+	CategorySitemapUnlisted:         SeverityWarning,
+	CategoryInsecureMixed:           SeverityWarning,
+	CategoryInsecureLink:            SeverityWarning,
+	CategorySoft404:                 SeverityWarning,
+	CategoryPageMissingAlt:          SeverityWarning,
+	CategoryPageEmptyLinkText:       SeverityWarning,
+	CategoryPageDuplicateID:         SeverityWarning,
+	CategoryPageMissingTitle:        SeverityWarning,
+	CategoryPageMissingDescription:  SeverityInfo,
+	CategoryPageBrokenCanonical:     SeverityWarning,
+}
+
+// parentCategories lets a severity configured for `network-error` apply to
+// the more specific network failures.
+var parentCategories = map[string]string{
+	string(ErrorDNS):               string(ErrorNetwork),
+	string(ErrorConnectionRefused): string(ErrorNetwork),
+	string(ErrorTLS):               string(ErrorNetwork),
+	string(ErrorTimeout):           string(ErrorNetwork),
+}
+
+// category returns the category of the most serious problem with `r`: its
+// error code if it failed, or the category of its warning if it has one. It
+// returns the empty string if there is no problem.
+func category(r *Result) string {
+	switch {
+	case r.Error != nil:
+		return string(r.Error.Code)
+	case r.SoftNotFound:
+		return CategorySoft404
+	case r.RedirectIssue != "":
+		return "redirect-" + string(r.RedirectIssue)
+	case r.SitemapIssue != "":
+		return "sitemap-" + string(r.SitemapIssue)
+	case r.InsecureIssue != "":
+		return "insecure-" + string(r.InsecureIssue)
+	case r.PageIssue != "":
+		return "page-" + string(r.PageIssue)
+	case r.Baseline == BaselineFixed:
+		return CategoryBaselineFixed
+	default:
+		return ""
+	}
+}
+
+// severity returns the severity of results in `category`.
+func (crawler *Crawler) severity(category string) Severity {
+	if severity, ok := crawler.Severities[category]; ok {
+		return severity
+	}
+	if parent, ok := parentCategories[category]; ok {
+		if severity, ok := crawler.Severities[parent]; ok {
+			return severity
+		}
+	}
+	if severity, ok := DefaultSeverities[category]; ok {
+		return severity
+	}
+	return SeverityError
+}
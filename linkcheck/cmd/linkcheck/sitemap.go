@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// SitemapIssue classifies a discrepancy between the sitemap and the crawl.
+type SitemapIssue string
+
+const (
+	// SitemapOrphan indicates a page which is listed in the sitemap but
+	// which no crawled page links to.
+	SitemapOrphan SitemapIssue = "orphan"
+
+	// SitemapUnlisted indicates an internal page which was found by crawling
+	// but which is missing from the sitemap.
+	SitemapUnlisted SitemapIssue = "unlisted"
+)
+
+// maxSitemapDepth bounds how deeply sitemap indexes may nest.
+const maxSitemapDepth = 4
+
+// sitemap is either a `<urlset>` or a `<sitemapindex>`.
+type sitemap struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapEntry is a page listed in a sitemap.
+type sitemapEntry struct {
+	sitemap *url.URL
+	href    string
+}
+
+// readSitemap fetches the sitemap at `sitemapURL`, following sitemap indexes,
+// and returns the pages it lists. Sitemaps which can't be read are reported
+// (see [Crawler.sitemapError]) rather than aborting the crawl, in which case
+// `complete` is false.
+func (crawler *Crawler) readSitemap(
+	sitemapURL *url.URL,
+) (entries []sitemapEntry, complete bool) {
+	complete = true
+	visited := map[string]struct{}{}
+	var visit func(u *url.URL, index *url.URL, depth int)
+	visit = func(u *url.URL, index *url.URL, depth int) {
+		if _, seen := visited[u.String()]; seen {
+			return
+		}
+		visited[u.String()] = struct{}{}
+
+		sm, err := crawler.fetchSitemap(u)
+		if err != nil {
+			crawler.sitemapError(index, u.String(), err)
+			complete = false
+			return
+		}
+		for _, loc := range sm.URLs {
+			entries = append(entries, sitemapEntry{
+				sitemap: u,
+				href:    strings.TrimSpace(loc.Loc),
+			})
+		}
+
+		if len(sm.Sitemaps) > 0 && depth >= maxSitemapDepth {
+			err := fmt.Errorf(
+				"sitemap index `%s`: nested more than %d deep",
+				u,
+				maxSitemapDepth,
+			)
+			crawler.sitemapError(index, u.String(), parseError(err))
+			complete = false
+			return
+		}
+		for _, loc := range sm.Sitemaps {
+			href := strings.TrimSpace(loc.Loc)
+			child, err := u.Parse(href)
+			if err != nil {
+				crawler.sitemapError(u, href, parseError(err))
+				complete = false
+				continue
+			}
+			visit(child, u, depth+1)
+		}
+	}
+	visit(sitemapURL, nil, 0)
+	return
+}
+
+// sitemapError reports a sitemap which couldn't be read, referenced as `href`
+// by the sitemap index at `index` (or, if `index` is nil, the sitemap passed
+// to `Crawl`).
+func (crawler *Crawler) sitemapError(index *url.URL, href string, err error) {
+	result := Result{BaseURL: href, TargetURL: href, Error: classify(err)}
+	if index != nil {
+		result.BaseURL = index.String()
+		result.Element = "loc"
+	}
+	var statusCode ErrNotOk
+	if errors.As(err, &statusCode) {
+		result.StatusCode = int(statusCode)
+	}
+	crawler.callback(&result)
+}
+
+// seedSitemap links each page listed in the sitemap, so that pages which are
+// only reachable via the sitemap are still crawled.
+func (crawler *Crawler) seedSitemap(queue *workQueue, entries []sitemapEntry) {
+	for _, entry := range entries {
+		targetURL, err := entry.sitemap.Parse(entry.href)
+		if err != nil {
+			crawler.callback(&Result{
+				BaseURL:   entry.sitemap.String(),
+				Element:   "loc",
+				TargetURL: entry.href,
+				Error:     parseError(err),
+			})
+			continue
+		}
+		crawler.link(queue, targetURL, link{
+			base:     entry.sitemap,
+			element:  "loc",
+			href:     entry.href,
+			fragment: targetURL.Fragment,
+			page:     true,
+			sitemap:  true,
+		})
+	}
+}
+
+// fetchSitemap fetches and parses a single sitemap or sitemap index, which
+// may be gzip-compressed.
+func (crawler *Crawler) fetchSitemap(u *url.URL) (sm sitemap, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("fetching sitemap `%s`: %w", u, err)
+		}
+	}()
+
+	var body io.ReadCloser
+	if u.Scheme == "file" {
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return sm, err
+		}
+		body = f
+	} else {
+		req, err := crawler.newRequest(http.MethodGet, u.String())
+		if err != nil {
+			return sm, err
+		}
+
+		rsp, release, _, _, err := crawler.follow(req)
+		defer release()
+		if err != nil {
+			return sm, err
+		}
+		if rsp.StatusCode != http.StatusOK {
+			rsp.Body.Close()
+			return sm, ErrNotOk(rsp.StatusCode)
+		}
+		body = rsp.Body
+	}
+	defer body.Close()
+
+	// sniff for gzip rather than trusting the extension or content type
+	r := bufio.NewReader(body)
+	var content io.Reader = r
+	if magic, _ := r.Peek(2); len(magic) == 2 &&
+		magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return sm, err
+		}
+		defer gz.Close()
+		content = gz
+	}
+
+	err = xml.NewDecoder(content).Decode(&sm)
+	return
+}
+
+// reportSitemap reports pages listed in the sitemap which no crawled page
+// links to, and crawled internal pages which are missing from the sitemap
+// (unless it couldn't all be read, i.e., it isn't `complete`). A URL which
+// redirects is the same page as its final URL, so listing or linking to
+// either counts for both.
+func (crawler *Crawler) reportSitemap(sitemapURL *url.URL, complete bool) {
+	crawler.mutex.Lock()
+	listed := map[string]bool{}
+	linked := map[string]bool{}
+	for _, t := range crawler.seen {
+		key := pageKey(t)
+		listed[key] = listed[key] || t.sitemap != ""
+		linked[key] = linked[key] || t.linked
+	}
+
+	var results []Result
+	for _, t := range crawler.seen {
+		switch key := pageKey(t); {
+		case t.sitemap != "" && !linked[key] && t.done:
+			result := Result{
+				BaseURL:      t.sitemap,
+				Element:      "loc",
+				TargetURL:    t.url.String(),
+				SitemapIssue: SitemapOrphan,
+			}
+			crawler.setStatus(t, &result)
+
+			// a failure is already reported by the sitemap's link to the
+			// target, so this result only concerns the orphan
+			result.Error = nil
+			results = append(results, result)
+		case complete && !listed[key] && t.crawled:
+			results = append(results, Result{
+				BaseURL:      sitemapURL.String(),
+				TargetURL:    t.url.String(),
+				StatusCode:   http.StatusOK,
+				SitemapIssue: SitemapUnlisted,
+			})
+		}
+	}
+	crawler.mutex.Unlock()
+
+	slices.SortFunc(results, func(l, r Result) int {
+		return strings.Compare(l.TargetURL, r.TargetURL)
+	})
+	for i := range results {
+		crawler.callback(&results[i])
+	}
+}
+
+// pageKey identifies the page which `t` is: the final URL of its redirects,
+// if it redirected, or its own URL otherwise.
+func pageKey(t *target) string {
+	if len(t.redirects) > 0 {
+		final, err := url.Parse(t.redirects[len(t.redirects)-1].URL)
+		if err == nil {
+			return stripFragment(final).String()
+		}
+	}
+	return t.url.String()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestSitemapRedirects(t *testing.T) {
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/sitemap.xml": func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<urlset>` +
+				`<url><loc>/</loc></url>` +
+				`<url><loc>/old</loc></url>` +
+				`<url><loc>/target</loc></url>` +
+				`<url><loc>/lonely</loc></url>` +
+				`</urlset>`))
+		},
+		"/": html(`<a href="/old">old</a>` +
+			`<a href="/moved">moved</a>` +
+			`<a href="/unlisted">unlisted</a>`),
+
+		// listed under the URL which redirects to it
+		"/old": redirect("/new"),
+		"/new": html("new"),
+
+		// listed, but only linked via a redirect
+		"/moved":  redirect("/target"),
+		"/target": html("target"),
+
+		"/lonely":   html("lonely"),
+		"/unlisted": html("unlisted"),
+	})
+
+	crawler := newTestCrawler(server).
+		SetSitemap(mustParse(server.URL + "/sitemap.xml"))
+	results, err := crawl(t, crawler, server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var issues []string
+	for _, hrefResults := range results {
+		for _, result := range hrefResults {
+			if result.SitemapIssue != "" {
+				issues = append(
+					issues,
+					string(result.SitemapIssue)+" "+result.TargetURL,
+				)
+			}
+		}
+	}
+	slices.Sort(issues)
+	wanted := []string{
+		"orphan " + server.URL + "/lonely",
+		"unlisted " + server.URL + "/unlisted",
+	}
+	if !slices.Equal(issues, wanted) {
+		t.Fatalf("wanted sitemap issues %q; got %q", wanted, issues)
+	}
+}
+
+func TestSitemapErrors(t *testing.T) {
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/index.xml": func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<sitemapindex>` +
+				`<sitemap><loc>/missing.xml</loc></sitemap>` +
+				`<sitemap><loc>/sitemap.xml</loc></sitemap>` +
+				`</sitemapindex>`))
+		},
+		"/sitemap.xml": func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<urlset><url><loc>/listed</loc></url></urlset>`))
+		},
+		"/":       html(`<a href="/page">page</a>`),
+		"/page":   html("page"),
+		"/listed": html("listed"),
+	})
+
+	for _, tc := range []struct {
+		name        string
+		sitemap     string
+		wantFailure string
+		wantListed  bool
+	}{
+		{name: "missing", sitemap: "/missing.xml", wantFailure: "/missing.xml"},
+		{
+			name:        "index",
+			sitemap:     "/index.xml",
+			wantFailure: "/missing.xml",
+			wantListed:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			crawler := newTestCrawler(server).
+				SetSitemap(mustParse(server.URL + tc.sitemap))
+			results, err := crawl(t, crawler, server.URL+"/")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// the failure is reported, and the crawl continues
+			failure := only(t, results, server.URL+tc.wantFailure)
+			if failure.StatusCode != http.StatusNotFound ||
+				failure.Error == nil {
+				t.Fatalf("wanted a 404 for the sitemap; got %+v", failure)
+			}
+			only(t, results, "/page")
+
+			// pages missing from a sitemap which couldn't be read aren't
+			// reported as unlisted
+			for _, hrefResults := range results {
+				for _, result := range hrefResults {
+					if result.SitemapIssue == SitemapUnlisted {
+						t.Fatalf("unexpected unlisted page: %+v", result)
+					}
+				}
+			}
+			if _, found := results["/listed"]; found != tc.wantListed {
+				t.Fatalf(
+					"wanted the sitemap's page checked: %t; got %t",
+					tc.wantListed,
+					found,
+				)
+			}
+		})
+	}
+}
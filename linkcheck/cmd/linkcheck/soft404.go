@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// soft404Similarity is the similarity of their words beyond which a
+	// page matches a host's "not found" page.
+	soft404Similarity = 0.9
+
+	// soft404TitledSimilarity is the lower similarity beyond which a page
+	// with the same title and length as a host's "not found" page matches it.
+	soft404TitledSimilarity = 0.5
+
+	// soft404LengthTolerance is the fraction by which the length of a page's
+	// text may differ from that of a host's "not found" page for the lengths
+	// to be considered the same.
+	soft404LengthTolerance = 0.1
+)
+
+// fingerprint summarizes a page for comparison with others.
+type fingerprint struct {
+	title  string
+	length int
+	words  map[string]struct{}
+}
+
+// newFingerprint returns the fingerprint of `doc`, which was fetched from
+// `u`: its title and the length and words of its text. Since "not found"
+// pages often repeat the URL which wasn't found, words which are its path
+// (or end with it, e.g., the whole URL) or its last path segment are removed
+// first.
+func newFingerprint(u *url.URL, doc *goquery.Document) *fingerprint {
+	path := strings.Trim(strings.ToLower(u.Path), "/")
+	segment := path[strings.LastIndex(path, "/")+1:]
+	normalize := func(s string) []string {
+		var words []string
+		for _, word := range strings.Fields(strings.ToLower(s)) {
+			// ignore surrounding quotes and punctuation
+			trimmed := strings.TrimFunc(word, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			if path != "" && (trimmed == path || trimmed == segment ||
+				strings.HasSuffix(trimmed, "/"+path)) {
+				continue
+			}
+			words = append(words, word)
+		}
+		return words
+	}
+
+	text := normalize(doc.Find("body").Text())
+	fp := fingerprint{
+		title: strings.Join(normalize(doc.Find("title").Text()), " "),
+		words: make(map[string]struct{}, len(text)),
+	}
+	for _, word := range text {
+		fp.length += len(word)
+		fp.words[word] = struct{}{}
+	}
+	return &fp
+}
+
+// similarity returns the Jaccard similarity of the fingerprints' words, from
+// 0 (no words in common) to 1 (the same words).
+func (fp *fingerprint) similarity(other *fingerprint) float64 {
+	if len(fp.words) == 0 && len(other.words) == 0 {
+		return 1
+	}
+	common := 0
+	for word := range fp.words {
+		if _, ok := other.words[word]; ok {
+			common++
+		}
+	}
+	return float64(common) /
+		float64(len(fp.words)+len(other.words)-common)
+}
+
+// matches returns true if the fingerprints are probably of the same page:
+// their words are nearly the same, or they have the same title, similar
+// lengths, and many words in common.
+func (fp *fingerprint) matches(other *fingerprint) bool {
+	similarity := fp.similarity(other)
+	if similarity >= soft404Similarity {
+		return true
+	}
+	tolerance := soft404LengthTolerance * float64(max(fp.length, other.length))
+	return fp.title == other.title &&
+		float64(abs(fp.length-other.length)) <= tolerance &&
+		similarity >= soft404TitledSimilarity
+}
+
+// soft404Entry is a host's response to a URL which doesn't exist. `ready` is
+// closed once it has been fetched.
+type soft404Entry struct {
+	ready chan struct{}
+
+	// fingerprint is the fingerprint of the response, or nil if the host
+	// responded with an error status (or something other than HTML), as it
+	// should.
+	fingerprint *fingerprint
+
+	// url is the final URL of the response, e.g., the home page for hosts
+	// which redirect unknown URLs there.
+	url *url.URL
+}
+
+// soft404 returns true if `page`, which was fetched from `u` successfully, is
+// probably a "soft 404": a "not found" page served with a 200 status (or a
+// redirect to some other page, e.g., the home page) rather than a 404. Pages
+// are compared with the host's response to a URL which doesn't exist.
+func (crawler *Crawler) soft404(u *url.URL, page *fetched) bool {
+	if page.doc == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	probe := crawler.soft404Probe(u)
+	if probe.fingerprint == nil {
+		return false
+	}
+
+	// a link directly to the page which unknown URLs redirect to (e.g.,
+	// the home page) isn't broken
+	if len(page.redirects) < 1 && page.url.String() == probe.url.String() {
+		return false
+	}
+	return newFingerprint(u, page.doc).matches(probe.fingerprint)
+}
+
+// soft404Probe returns the response of the host of `u` to a URL which doesn't
+// exist, fetching it on first use. Concurrent callers for the same host wait
+// for a single fetch.
+func (crawler *Crawler) soft404Probe(u *url.URL) *soft404Entry {
+	key := u.Scheme + "://" + u.Host
+
+	crawler.mutex.Lock()
+	entry, exists := crawler.soft404Cache[key]
+	if !exists {
+		entry = &soft404Entry{ready: make(chan struct{})}
+		crawler.soft404Cache[key] = entry
+	}
+	crawler.mutex.Unlock()
+
+	if exists {
+		<-entry.ready
+		return entry
+	}
+
+	probeURL := fmt.Sprintf("%s/linkcheck-%016x", key, rand.Uint64())
+	entry.fingerprint, entry.url = crawler.fetchFingerprint(probeURL)
+	close(entry.ready)
+	return entry
+}
+
+// fetchFingerprint fetches `u` and returns the fingerprint of the response
+// and its final URL. The fingerprint is nil if the response isn't successful
+// HTML.
+func (crawler *Crawler) fetchFingerprint(
+	u string,
+) (*fingerprint, *url.URL) {
+	req, err := crawler.newRequest(http.MethodGet, u)
+	if err != nil {
+		return nil, nil
+	}
+	rsp, release, _, _, err := crawler.send(req)
+	defer release()
+	if err != nil {
+		return nil, nil
+	}
+	if rsp.StatusCode != http.StatusOK || !isHTML(rsp) {
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+		return nil, nil
+	}
+	body, _ := crawler.limitBody(rsp.Body)
+	doc, err := readDoc(body)
+	if err != nil {
+		return nil, nil
+	}
+	return newFingerprint(req.URL, doc), rsp.Request.URL
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
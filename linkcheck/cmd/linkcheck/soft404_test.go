@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// mustFingerprint returns the fingerprint of `body`, fetched from `u`.
+func mustFingerprint(t *testing.T, u string, body string) *fingerprint {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("parsing `%s`: %v", u, err)
+	}
+	return newFingerprint(mustParse(u), doc)
+}
+
+func TestFingerprintMatches(t *testing.T) {
+	const notFound = "<title>Not Found</title>" +
+		"<p>Sorry, we couldn't find %s. Try the search page.</p>"
+	for _, tc := range []struct {
+		name    string
+		url     string
+		body    string
+		probe   string
+		matches bool
+	}{
+		{
+			name:    "same page",
+			url:     "https://example.com/a",
+			body:    "<title>A</title><p>one two three</p>",
+			probe:   "<title>A</title><p>one two three</p>",
+			matches: true,
+		},
+		{
+			name:    "echoes the url",
+			url:     "https://example.com/docs/old-page",
+			body:    strings.ReplaceAll(notFound, "%s", "/docs/old-page"),
+			probe:   strings.ReplaceAll(notFound, "%s", "/linkcheck-1"),
+			matches: true,
+		},
+		{
+			name:    "echoes the last segment",
+			url:     "https://example.com/docs/old-page",
+			body:    strings.ReplaceAll(notFound, "%s", "old-page"),
+			probe:   strings.ReplaceAll(notFound, "%s", "linkcheck-1"),
+			matches: true,
+		},
+		{
+			name:  "different pages",
+			url:   "https://example.com/a",
+			body:  "<title>About</title><p>We make widgets in Ohio.</p>",
+			probe: strings.ReplaceAll(notFound, "%s", "/linkcheck-1"),
+		},
+		{
+			name: "same title, length, and many words",
+			url:  "https://example.com/a",
+			body: "<title>Oops</title>" +
+				"<p>alpha beta gamma delta epsilon zeta eta theta</p>",
+			probe: "<title>Oops</title>" +
+				"<p>alpha beta gamma delta epsilon zeta iota kappa</p>",
+			matches: true,
+		},
+		{
+			name: "different titles",
+			url:  "https://example.com/a",
+			body: "<title>Oops</title>" +
+				"<p>alpha beta gamma delta epsilon zeta eta theta</p>",
+			probe: "<title>Missing</title>" +
+				"<p>alpha beta gamma delta epsilon zeta iota kappa</p>",
+		},
+		{
+			name: "different lengths",
+			url:  "https://example.com/a",
+			body: "<title>Oops</title>" +
+				"<p>alpha beta gamma delta epsilon zeta eta theta</p>",
+			probe: "<title>Oops</title>" +
+				"<p>alpha beta gamma delta epsilon zeta eta theta " +
+				"iota kappa lambda mu</p>",
+		},
+		{
+			name:    "empty pages",
+			url:     "https://example.com/a",
+			body:    "<title>Oops</title>",
+			probe:   "<title>Oops</title>",
+			matches: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fp := mustFingerprint(t, tc.url, tc.body)
+			probe := mustFingerprint(
+				t,
+				"https://example.com/linkcheck-1",
+				tc.probe,
+			)
+			if got := fp.matches(probe); got != tc.matches {
+				t.Fatalf(
+					"wanted matches: %t; got %t (similarity %.2f)",
+					tc.matches,
+					got,
+					fp.similarity(probe),
+				)
+			}
+		})
+	}
+}
+
+func TestSoft404(t *testing.T) {
+	notFound := func(w http.ResponseWriter, r *http.Request) {
+		html("<title>Not Found</title><p>Sorry, "+r.URL.Path+
+			" doesn't exist.</p>")(w, r)
+	}
+
+	// a host which serves "not found" pages successfully
+	soft := newSite(t, map[string]http.HandlerFunc{
+		"/": html("<title>Home</title><p>Welcome to our home page, " +
+			"where we sell all sorts of things.</p>"),
+		"/real": html("<title>Real</title><p>A page about something " +
+			"else entirely.</p>"),
+		"/{path...}": notFound,
+	})
+
+	// a host which redirects unknown URLs to its home page
+	redirecting := newSite(t, map[string]http.HandlerFunc{
+		"/":          html("<title>Home</title><p>Welcome home.</p>"),
+		"/{path...}": redirect("/"),
+	})
+
+	// a host which responds to unknown URLs with a 404, as it should
+	hard := newSite(t, map[string]http.HandlerFunc{
+		"/similar": notFound,
+	})
+
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<a href="` + soft.URL + `/">home</a>` +
+			`<a href="` + soft.URL + `/real">real</a>` +
+			`<a href="` + soft.URL + `/gone">gone</a>` +
+			`<a href="` + redirecting.URL + `/">home</a>` +
+			`<a href="` + redirecting.URL + `/old">old</a>` +
+			`<a href="` + hard.URL + `/similar">similar</a>`),
+	})
+
+	for _, detect := range []bool{false, true} {
+		crawler := newTestCrawler(server).SetDetectSoft404(detect)
+		results, err := crawl(t, crawler, server.URL+"/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, tc := range []struct {
+			target       string
+			softNotFound bool
+		}{
+			{target: soft.URL + "/"},
+			{target: soft.URL + "/real"},
+			{target: soft.URL + "/gone", softNotFound: detect},
+
+			// a redirect to the page which unknown URLs redirect to is a
+			// soft 404, but a link directly to it isn't
+			{target: redirecting.URL + "/"},
+			{target: redirecting.URL + "/old", softNotFound: detect},
+			{target: hard.URL + "/similar"},
+		} {
+			result := only(t, results, tc.target)
+			if result.Error != nil {
+				t.Fatalf("`%s`: unexpected error: %v", tc.target, result.Error)
+			}
+			if result.SoftNotFound != tc.softNotFound {
+				t.Fatalf(
+					"detect %t: `%s`: wanted soft 404: %t",
+					detect,
+					tc.target,
+					tc.softNotFound,
+				)
+			}
+		}
+	}
+}
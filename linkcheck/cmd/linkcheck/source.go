@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// DefaultSourceExtensions are the extensions of the source files checked by
+// [Crawler.CheckFiles] unless others are specified.
+var DefaultSourceExtensions = []string{".md"}
+
+// sourceLink is a link in a source file.
+type sourceLink struct {
+	line      int
+	element   string
+	attribute string
+	text      string
+	href      string
+
+	// err, if set, is a problem found while parsing the link (e.g., an
+	// undefined Markdown reference), which is reported instead of checking
+	// the link.
+	err *ResultError
+}
+
+// sourceFile is the links and anchors of a source file.
+type sourceFile struct {
+	links []sourceLink
+
+	// anchors are the fragment identifiers defined by the file, e.g., the
+	// slugs of Markdown headings.
+	anchors map[string]struct{}
+}
+
+// parseSourceFile reads the source file at `path` according to its extension.
+// It returns nil if the extension isn't supported.
+func parseSourceFile(path string) (*sourceFile, error) {
+	var parse func(string) *sourceFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		parse = parseMarkdown
+	case ".rst":
+		parse = parseRST
+	case ".html", ".htm":
+		parse = parseHTMLSource
+	default:
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading source file: %w", err)
+	}
+	return parse(string(data)), nil
+}
+
+// CheckFiles checks the links in source files (e.g., a repository's Markdown
+// documentation) rather than crawling a site. `paths` are files or
+// directories, which are searched recursively (skipping hidden directories)
+// for files with the given `extensions` (`.md`, `.markdown`, `.rst`, `.html`,
+// or `.htm`).
+//
+// Relative links are resolved on the filesystem; links beginning with `/`
+// are relative to the working directory. Fragments are checked against the
+// target file's anchors, e.g., the slugs of its headings. Other URLs are
+// checked like a crawl's external links. Results are passed to `Callback`,
+// with the file's path as the base URL.
+func (crawler *Crawler) CheckFiles(
+	paths []string,
+	extensions []string,
+) error {
+	files, err := findSourceFiles(paths, extensions)
+	if err != nil {
+		return err
+	}
+
+	queue := newWorkQueue()
+	var cancel func()
+	crawler.ctx, cancel = crawler.overallContext()
+	defer cancel()
+
+	checker := sourceChecker{crawler: crawler, files: map[string]*sourceFile{}}
+	for _, path := range files {
+		source, err := checker.file(path)
+		if err != nil {
+			return err
+		}
+		if source == nil {
+			return fmt.Errorf("checking `%s`: unsupported source file", path)
+		}
+		base := &url.URL{Path: filepath.ToSlash(path)}
+		for _, l := range source.links {
+			checker.check(queue, path, base, l)
+		}
+	}
+	crawler.run(queue)
+	return nil
+}
+
+// findSourceFiles returns the files in `paths` with the given extensions.
+// Files named explicitly are included regardless of their extensions.
+func findSourceFiles(paths []string, extensions []string) ([]string, error) {
+	var files []string
+	for _, root := range paths {
+		err := filepath.WalkDir(
+			root,
+			func(path string, entry fs.DirEntry, err error) error {
+				switch {
+				case err != nil:
+					return err
+				case path == root && !entry.IsDir():
+					files = append(files, path)
+				case entry.IsDir():
+					if path != root && strings.HasPrefix(entry.Name(), ".") {
+						return filepath.SkipDir
+					}
+				case slices.Contains(
+					extensions,
+					strings.ToLower(filepath.Ext(path)),
+				):
+					files = append(files, path)
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("finding source files: %w", err)
+		}
+	}
+	return files, nil
+}
+
+// sourceChecker checks the links in source files.
+type sourceChecker struct {
+	crawler *Crawler
+
+	// files are the parsed source files by path, which are only parsed
+	// once no matter how many links point to them. A nil file isn't a
+	// supported source file.
+	files map[string]*sourceFile
+}
+
+// file returns the parsed source file at `path`.
+func (checker *sourceChecker) file(path string) (*sourceFile, error) {
+	path = filepath.Clean(path)
+	if source, exists := checker.files[path]; exists {
+		return source, nil
+	}
+	source, err := parseSourceFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("checking `%s`: %w", path, err)
+	}
+	checker.files[path] = source
+	return source, nil
+}
+
+// check checks `l`, which appears in the file at `path`. Relative links are
+// checked immediately, and others are queued.
+func (checker *sourceChecker) check(
+	queue *workQueue,
+	path string,
+	base *url.URL,
+	l sourceLink,
+) {
+	result := Result{
+		BaseURL:    base.String(),
+		Element:    l.element,
+		Attribute:  l.attribute,
+		Line:       l.line,
+		TargetText: l.text,
+		TargetURL:  l.href,
+	}
+	if l.err != nil {
+		result.Error = l.err
+		checker.crawler.callback(&result)
+		return
+	}
+	targetURL, err := url.Parse(strings.TrimSpace(l.href))
+	if err != nil {
+		result.Error = parseError(err)
+		checker.crawler.callback(&result)
+		return
+	}
+	if targetURL.IsAbs() || targetURL.Host != "" {
+		checker.crawler.link(queue, targetURL, link{
+			base:      base,
+			element:   l.element,
+			attribute: l.attribute,
+			text:      l.text,
+			href:      l.href,
+			fragment:  targetURL.Fragment,
+			page:      l.element == "a",
+			line:      l.line,
+		})
+		return
+	}
+
+	// relative links are resolved on the filesystem
+	target := path
+	if targetURL.Path != "" {
+		dir := filepath.Dir(path)
+		if strings.HasPrefix(targetURL.Path, "/") {
+			dir = "."
+		}
+		target = filepath.Join(dir, filepath.FromSlash(targetURL.Path))
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		result.Error = classify(err)
+		checker.crawler.callback(&result)
+		return
+	}
+	if info.IsDir() {
+		// a directory's fragments are those of its index page, if any
+		index := filepath.Join(target, "index.html")
+		if indexInfo, err := os.Stat(index); err == nil {
+			target, info = index, indexInfo
+		}
+	}
+
+	if !info.IsDir() {
+		source, err := checker.file(target)
+		if err != nil {
+			result.Error = classify(err)
+			checker.crawler.callback(&result)
+			return
+		}
+		var anchors map[string]struct{}
+		if source != nil {
+			anchors = source.anchors
+		}
+		if !hasAnchor(anchors, targetURL.Fragment) {
+			result.MissingFragment = targetURL.Fragment
+			result.Error = &ResultError{
+				Code: ErrorFragmentMissing,
+				Message: fmt.Sprintf(
+					"missing anchor `#%s`",
+					targetURL.Fragment,
+				),
+			}
+		}
+	}
+	checker.crawler.callback(&result)
+}
+
+// parseHTMLSource returns the references in an HTML source file and its
+// anchors. Line numbers aren't available.
+func parseHTMLSource(content string) *sourceFile {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return &sourceFile{}
+	}
+	source := sourceFile{anchors: anchors(doc)}
+	for _, ref := range references(doc) {
+		source.links = append(source.links, sourceLink{
+			element:   ref.element,
+			attribute: ref.attribute,
+			text:      ref.text,
+			href:      ref.href,
+		})
+	}
+	return &source
+}
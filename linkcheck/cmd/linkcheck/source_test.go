@@ -0,0 +1,194 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeFiles writes `files`, keyed by slash-separated path relative to
+// `root`, creating directories as needed.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+// chdir changes the working directory to `dir` until the test finishes.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestSourceCheck(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeFiles(t, ".", map[string]string{
+		"docs/index.md":  "# Here\n",
+		"docs/README.md": "# Usage\n## Install\n",
+		"docs/guide.rst": "Guide\n=====\n",
+		"docs/site/index.html": `<h1 id="top-level">site</h1>` +
+			`<a name="named">named</a>`,
+		"docs/page.html":      `<p id="para">page</p>`,
+		"docs/image.png":      "png",
+		"docs/notes/notes.md": "# Notes\n",
+	})
+
+	for _, tc := range []struct {
+		href string
+		code ErrorCode
+	}{
+		{href: "README.md"},
+		{href: "README.md#usage"},
+		{href: "./README.md#install"},
+		{href: "README.md#nowhere", code: ErrorFragmentMissing},
+		{href: "#here"},
+		{href: "#gone", code: ErrorFragmentMissing},
+		{href: "missing.md", code: ErrorFile},
+		{href: "../docs/guide.rst#guide"},
+		{href: "guide.rst#nowhere", code: ErrorFragmentMissing},
+		{href: "site/#top-level"},
+		{href: "site#named"},
+		{href: "site/#nowhere", code: ErrorFragmentMissing},
+		{href: "site/index.html#top-level"},
+		{href: "page.html#para"},
+		{href: "image.png"},
+		{href: "image.png#anything"},
+		{href: "notes/"},
+		{href: "notes/#anything"},
+		{href: "/docs/README.md#usage"},
+		{href: "/README.md", code: ErrorFile},
+		{href: "%zz", code: ErrorParse},
+	} {
+		t.Run(tc.href, func(t *testing.T) {
+			var results []*Result
+			checker := sourceChecker{
+				crawler: NewCrawler("").SetCallback(func(result *Result) {
+					results = append(results, result)
+				}),
+				files: map[string]*sourceFile{},
+			}
+			checker.check(
+				newWorkQueue(),
+				"docs/index.md",
+				&url.URL{Path: "docs/index.md"},
+				sourceLink{
+					line:      3,
+					element:   "a",
+					attribute: "href",
+					href:      tc.href,
+				},
+			)
+
+			if len(results) != 1 {
+				t.Fatalf("wanted one result; found %d", len(results))
+			}
+			result := results[0]
+			if result.BaseURL != "docs/index.md" || result.Line != 3 {
+				t.Fatalf(
+					"wanted result for `docs/index.md` line 3; found `%s` "+
+						"line %d",
+					result.BaseURL,
+					result.Line,
+				)
+			}
+			var code ErrorCode
+			if result.Error != nil {
+				code = result.Error.Code
+			}
+			if code != tc.code {
+				t.Fatalf("wanted error `%s`; found %v", tc.code, result.Error)
+			}
+			if code == ErrorFragmentMissing {
+				fragment := tc.href[strings.Index(tc.href, "#")+1:]
+				if result.MissingFragment != fragment {
+					t.Fatalf(
+						"wanted missing fragment `%s`; found `%s`",
+						fragment,
+						result.MissingFragment,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/ok": html(`<h1 id="present">ok</h1>`),
+	})
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"README.md": "[ok](" + server.URL + "/ok#present)\n" +
+			"[gone](" + server.URL + "/gone)\n" +
+			"[doc](docs/doc.rst#doc)\n",
+		"docs/doc.rst":      "Doc\n===\n`missing <missing.md>`_\n",
+		"docs/skipped.txt":  "[skipped](skipped.md)\n",
+		".hidden/hidden.md": "[hidden](hidden.md)\n",
+	})
+
+	var (
+		mutex   sync.Mutex
+		results []string
+	)
+	crawler := newTestCrawler(server).SetCallback(func(result *Result) {
+		var code ErrorCode
+		if result.Error != nil {
+			code = result.Error.Code
+		}
+		relative, err := filepath.Rel(
+			root,
+			filepath.FromSlash(result.BaseURL),
+		)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		results = append(results, strings.Join([]string{
+			filepath.ToSlash(relative),
+			strings.TrimPrefix(result.TargetURL, server.URL),
+			string(code),
+		}, " "))
+	})
+	err := crawler.CheckFiles(
+		[]string{root},
+		[]string{".md", ".rst"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sort.Strings(results)
+	wanted := []string{
+		"README.md /gone http-status",
+		"README.md /ok#present ",
+		"README.md docs/doc.rst#doc ",
+		"docs/doc.rst missing.md file-error",
+	}
+	if strings.Join(results, "\n") != strings.Join(wanted, "\n") {
+		t.Fatalf("wanted results %q; found %q", wanted, results)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// cssURL matches a CSS URL token, e.g., `url("a.png")`, capturing the URL
+// (in one of three groups, depending on its quotes).
+const cssURL = `url\(\s*(?:'([^']*)'|"([^"]*)"|([^'"\s)]*))\s*\)`
+
+var (
+	// cssReferencePattern matches an `@import` rule, whose URL is either a
+	// URL token or a string (e.g., `@import "base.css" screen;`), or any
+	// other URL token. The first five groups belong to `@import`s.
+	cssReferencePattern = regexp.MustCompile(
+		`@import\s+(?:` + cssURL + `|'([^']*)'|"([^"]*)")|` + cssURL,
+	)
+
+	cssCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+)
+
+// cssReference is a URL referenced by a stylesheet.
+type cssReference struct {
+	href string
+
+	// imported is true if the URL is another stylesheet, referenced by an
+	// `@import` rule, rather than by `url(...)`.
+	imported bool
+}
+
+// cssReferences returns the URLs referenced by `url(...)` and `@import` in a
+// stylesheet, ignoring comments.
+func cssReferences(css string) []cssReference {
+	css = cssCommentPattern.ReplaceAllString(css, " ")
+
+	var refs []cssReference
+	for _, match := range cssReferencePattern.FindAllStringSubmatch(css, -1) {
+		imported := strings.Join(match[1:6], "")
+		if href := imported + strings.Join(match[6:], ""); href != "" {
+			refs = append(refs, cssReference{
+				href:     href,
+				imported: imported != "",
+			})
+		}
+	}
+	return refs
+}
+
+// stylesheetReferences returns the URLs referenced by a stylesheet which is
+// part of the site. `@import`s are attributed to an `@import` element and
+// other URLs to a `url` element.
+func stylesheetReferences(css string) []reference {
+	var refs []reference
+	for _, ref := range cssReferences(css) {
+		// inline data needn't be checked
+		if len(ref.href) >= 5 && strings.EqualFold(ref.href[:5], "data:") {
+			continue
+		}
+		element := "url"
+		if ref.imported {
+			element = "@import"
+		}
+		refs = append(refs, reference{element: element, href: ref.href})
+	}
+	return refs
+}
+
+// readStylesheet reads and closes `body`.
+func readStylesheet(body io.ReadCloser) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf(
+			"reading stylesheet: %w",
+			errors.Join(err, body.Close()),
+		)
+	}
+	if err := body.Close(); err != nil {
+		return "", fmt.Errorf("reading stylesheet: %w", err)
+	}
+	return string(data), nil
+}
+
+// isCSS returns true if `rsp` declares a CSS body.
+func isCSS(rsp *http.Response) bool {
+	return isCSSType(rsp.Header.Get("Content-Type"))
+}
+
+// isCSSFile returns true if the file at `path` is a stylesheet, according to
+// its extension.
+func isCSSFile(path string) bool {
+	return isCSSType(mime.TypeByExtension(filepath.Ext(path)))
+}
+
+func isCSSType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/css"
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func css(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		w.Write([]byte(body))
+	}
+}
+
+func TestCSSReferences(t *testing.T) {
+	refs := cssReferences(`
+		@import "base.css";
+		@import url('print.css') print;
+		/* url(commented.png) */
+		body { background: url(img/bg.png) }
+		.logo { background-image: url( "logo.svg" ) }
+	`)
+	wanted := []cssReference{
+		{href: "base.css", imported: true},
+		{href: "print.css", imported: true},
+		{href: "img/bg.png"},
+		{href: "logo.svg"},
+	}
+	if !slices.Equal(refs, wanted) {
+		t.Fatalf("wanted %+v; got %+v", wanted, refs)
+	}
+}
+
+func TestStylesheets(t *testing.T) {
+	external := newSite(t, map[string]http.HandlerFunc{
+		"/lib.css": css(`body { background: url(/external.png) }`),
+	})
+	server := newSite(t, map[string]http.HandlerFunc{
+		"/": html(`<link rel="stylesheet" href="/css/site.css">` +
+			`<link rel="stylesheet" href="` + external.URL + `/lib.css">`),
+		"/css/site.css": css(`@import "theme.css";` +
+			`body { background: url(../img/bg.png) }` +
+			`.icon { background: url(data:image/png;base64,AAAA) }`),
+		"/css/theme.css": css(`h1 { background: url(missing.png) }`),
+		"/img/bg.png":    func(http.ResponseWriter, *http.Request) {},
+	})
+
+	crawler := newTestCrawler(server).SetHeadExternal(false)
+	results, err := crawl(t, crawler, server.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// references are resolved relative to the stylesheet, including those
+	// of imported stylesheets
+	imported := only(t, results, "theme.css")
+	if imported.Element != "@import" ||
+		imported.BaseURL != server.URL+"/css/site.css" ||
+		imported.Error != nil {
+		t.Fatalf("unexpected result for the import: %+v", imported)
+	}
+	if result := only(t, results, "../img/bg.png"); result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	missing := only(t, results, "missing.png")
+	if missing.Element != "url" ||
+		missing.BaseURL != server.URL+"/css/theme.css" ||
+		missing.StatusCode != http.StatusNotFound {
+		t.Fatalf("wanted a missing image; got %+v", missing)
+	}
+
+	// external stylesheets aren't parsed
+	if _, found := results["/external.png"]; found {
+		t.Fatal("wanted the external stylesheet's references ignored")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import "sync"
+
+// workQueue is an unbounded queue of targets to fetch. It tracks the number
+// of targets which have been pushed but not yet completed so that workers can
+// tell the difference between a momentarily empty queue and a finished
+// crawl.
+type workQueue struct {
+	mutex   sync.Mutex
+	cond    sync.Cond
+	items   []*target
+	pending int
+}
+
+func newWorkQueue() *workQueue {
+	queue := new(workQueue)
+	queue.cond.L = &queue.mutex
+	return queue
+}
+
+// push adds `t` to the queue.
+func (queue *workQueue) push(t *target) {
+	queue.mutex.Lock()
+	queue.items = append(queue.items, t)
+	queue.pending++
+	queue.mutex.Unlock()
+	queue.cond.Signal()
+}
+
+// pop blocks until a target is available and returns it, or returns false
+// once every pushed target has been completed.
+func (queue *workQueue) pop() (*target, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for len(queue.items) < 1 {
+		if queue.pending < 1 {
+			return nil, false
+		}
+		queue.cond.Wait()
+	}
+
+	t := queue.items[0]
+	queue.items[0] = nil
+	queue.items = queue.items[1:]
+	return t, true
+}
+
+// done marks a popped target as completed. Any targets discovered while
+// processing it must be pushed before calling done.
+func (queue *workQueue) done() {
+	queue.mutex.Lock()
+	queue.pending--
+	finished := queue.pending < 1
+	queue.mutex.Unlock()
+	if finished {
+		queue.cond.Broadcast()
+	}
+}
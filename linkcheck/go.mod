@@ -5,6 +5,7 @@ go 1.22
 require (
 	github.com/PuerkitoBio/goquery v1.8.1
 	golang.org/x/sys v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (